@@ -0,0 +1,124 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package changelog combines the release notes of several releases into a single
+document, either as Markdown or rendered for the terminal.
+*/
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxHeading is the deepest heading level Markdown has.
+const maxHeading = 6
+
+var (
+	reHeading  = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	reListItem = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	reFence    = regexp.MustCompile("^\\s*(```|~~~)")
+
+	// reNotable finds the notes which need attention before upgrading.
+	reNotable = regexp.MustCompile(`(?i)breaking|deprecat`)
+)
+
+// Entry is the release notes of one release.
+type Entry struct {
+	Tag  string
+	Name string
+
+	// Date is when the release was published, if known.
+	Date time.Time
+
+	// Body is the release notes, as Markdown.
+	Body string
+}
+
+// Notable reports whether text mentions a breaking change or a deprecation.
+func Notable(text string) bool {
+	return reNotable.MatchString(text)
+}
+
+// Markdown combines the entries into one document under the title. Each entry
+// becomes a second-level heading, and the headings in its notes are moved down
+// two levels to fit under it.
+func Markdown(title string, entries []Entry) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n", title)
+
+	for i := range entries {
+		fmt.Fprintf(&b, "\n## %s\n\n", entries[i].heading())
+
+		if strings.TrimSpace(entries[i].Body) == "" {
+			b.WriteString("_No release notes._\n")
+
+			continue
+		}
+
+		b.WriteString(demoteHeadings(entries[i].Body, 2)) // lint:allow_raw_number
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// heading is the title of an entry: its tag, along with its name (if it says
+// more than the tag) and its date.
+func (e *Entry) heading() string {
+	heading := e.Tag
+
+	if name := strings.TrimSpace(e.Name); name != "" && name != e.Tag {
+		heading += " – " + name
+	}
+
+	if !e.Date.IsZero() {
+		heading += " (" + e.Date.Format(time.DateOnly) + ")"
+	}
+
+	return heading
+}
+
+// demoteHeadings moves every heading outside of a code block down by levels,
+// as far as the deepest level.
+func demoteHeadings(body string, levels int) string {
+	lines := splitLines(body)
+	inFence := false
+
+	for i, line := range lines {
+		if reFence.MatchString(line) {
+			inFence = !inFence
+
+			continue
+		}
+
+		m := reHeading.FindStringSubmatch(line)
+		if inFence || m == nil {
+			continue
+		}
+
+		level := min(len(m[1])+levels, maxHeading)
+		lines[i] = strings.Repeat("#", level) + " " + m[2]
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func splitLines(body string) []string {
+	return strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,144 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEntries = []Entry{
+	{
+		Tag:  "v1.4.0",
+		Date: time.Date(2024, 10, 17, 12, 0, 0, 0, time.UTC),
+		Body: "## Breaking changes\n\n- Removed `--old`.\n\n### Details\n\nSee the docs.\n\n" +
+			"## Features\n\n* Added **colors**.\n* The `legacy` format is deprecated.\n\n" +
+			"```\n# not a heading\n```",
+	},
+	{
+		Tag:  "v1.3.0",
+		Name: "Autumn release",
+	},
+}
+
+func TestNotable(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Text     string
+		Expected bool
+	}{
+		"breaking":   {Text: "BREAKING: flags were renamed", Expected: true},
+		"deprecated": {Text: "The v1 API is deprecated.", Expected: true},
+		"deprecates": {Text: "This deprecates --old.", Expected: true},
+		"plain":      {Text: "Fixed a crash.", Expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Notable(tc.Text); got != tc.Expected {
+				t.Errorf("got %t; want %t", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	expected := `# Changes
+
+## v1.4.0 (2024-10-17)
+
+#### Breaking changes
+
+- Removed ` + "`--old`" + `.
+
+##### Details
+
+See the docs.
+
+#### Features
+
+* Added **colors**.
+* The ` + "`legacy`" + ` format is deprecated.
+
+` + "```\n# not a heading\n```" + `
+
+## v1.3.0 – Autumn release
+
+_No release notes._
+`
+
+	if got := Markdown("Changes", testEntries); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestRender(t *testing.T) {
+	lines := strings.Split(Render("Changes", testEntries), "\n")
+
+	var tests = map[string]struct { // lint:no_dupe
+		Line    string
+		Notable bool
+	}{
+		"notable-heading": {
+			Line:    "Breaking changes",
+			Notable: true,
+		},
+		"inside-notable-section": {
+			Line:    "• Removed --old.",
+			Notable: true,
+		},
+		"notable-subsection": {
+			Line:    "See the docs.",
+			Notable: true,
+		},
+		"next-section": {
+			Line:    "Features",
+			Notable: false,
+		},
+		"plain-line": {
+			Line:    "• Added colors.",
+			Notable: false,
+		},
+		"notable-line": {
+			Line:    "• The legacy format is deprecated.",
+			Notable: true,
+		},
+		"code-block": {
+			Line:    "# not a heading",
+			Notable: false,
+		},
+		"no-notes": {
+			Line:    "No release notes.",
+			Notable: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			want := tc.Line
+			if tc.Notable {
+				want = notableMarker + tc.Line
+			}
+
+			for _, line := range lines {
+				if line == want {
+					return
+				}
+			}
+
+			t.Errorf("%q not found in:\n%s", want, strings.Join(lines, "\n"))
+		})
+	}
+}
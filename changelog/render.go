@@ -0,0 +1,144 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// notableMarker is put in front of every notable line, so that they stand out
+// even without colors.
+const notableMarker = "▌ "
+
+var (
+	reBold = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	reCode = regexp.MustCompile("`([^`]+)`")
+
+	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99"))
+	entryStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	headingStyle = lipgloss.NewStyle().Bold(true)
+	codeStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	boldStyle    = lipgloss.NewStyle().Bold(true)
+	notableStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("208"))
+	emptyStyle   = lipgloss.NewStyle().Italic(true).Faint(true)
+)
+
+// Render formats the entries for the terminal. Only the most common Markdown
+// (headings, lists, code and bold text) is styled; everything else is shown as
+// it was written.
+//
+// Sections whose heading mentions a breaking change or a deprecation are
+// highlighted as a whole. Elsewhere, only the lines which mention one are.
+func Render(title string, entries []Entry) string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n")
+
+	for i := range entries {
+		b.WriteString("\n")
+		b.WriteString(entryStyle.Render(entries[i].heading()))
+		b.WriteString("\n\n")
+
+		if strings.TrimSpace(entries[i].Body) == "" {
+			b.WriteString(emptyStyle.Render("No release notes."))
+			b.WriteString("\n")
+
+			continue
+		}
+
+		b.WriteString(renderBody(entries[i].Body))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func renderBody(body string) string {
+	var (
+		lines   = splitLines(strings.TrimSpace(body))
+		out     = make([]string, 0, len(lines))
+		inFence bool
+
+		// notableLevel is the level of the notable section which is being
+		// rendered, or 0 outside of one.
+		notableLevel int
+	)
+
+	for _, line := range lines {
+		if reFence.MatchString(line) {
+			inFence = !inFence
+			out = append(out, mark(codeStyle.Render(line), notableLevel > 0))
+
+			continue
+		}
+
+		if inFence {
+			out = append(out, mark(codeStyle.Render(line), notableLevel > 0))
+
+			continue
+		}
+
+		if m := reHeading.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+
+			switch {
+			case Notable(m[2]):
+				notableLevel = level
+			case notableLevel > 0 && level <= notableLevel:
+				notableLevel = 0
+			}
+
+			out = append(out, mark(headingStyle.Render(m[2]), notableLevel > 0))
+
+			continue
+		}
+
+		out = append(out, mark(renderInline(line), notableLevel > 0 || Notable(line)))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// renderInline styles a line of text, turning list markers into bullets.
+func renderInline(line string) string {
+	if m := reListItem.FindStringSubmatch(line); m != nil {
+		line = m[1] + "• " + m[2]
+	}
+
+	line = reBold.ReplaceAllStringFunc(line, func(s string) string {
+		return boldStyle.Render(s[2 : len(s)-2])
+	})
+
+	return reCode.ReplaceAllStringFunc(line, func(s string) string {
+		return codeStyle.Render(s[1 : len(s)-1])
+	})
+}
+
+// mark highlights a notable line.
+func mark(line string, notable bool) string {
+	if !notable {
+		return line
+	}
+
+	if line == "" {
+		return notableStyle.Render(strings.TrimSpace(notableMarker))
+	}
+
+	return notableStyle.Render(notableMarker) + line
+}
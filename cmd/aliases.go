@@ -0,0 +1,274 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	aliasOS   = "os"
+	aliasArch = "arch"
+	aliasLibc = "libc"
+
+	// aliasMapKey is the config table of aliases, which has a table for each
+	// kind.
+	aliasMapKey = "map"
+)
+
+// aliasTable maps a `kind.name` key (e.g., `os.darwin`) to the value which the
+// pattern variable is set to.
+type aliasTable map[string]string
+
+var (
+	// mapAliases are set by --map, flagAliases by the legacy flags (e.g.,
+	// --darwin), and configAliases by the per-repository config.
+	mapAliases    = aliasTable{}
+	flagAliases   = aliasTable{}
+	configAliases = aliasTable{}
+
+	// knownNames are the values of GOOS, GOARCH and the C libraries which can
+	// be mapped. Each is its own alias unless it is mapped to something else.
+	knownNames = map[string][]string{
+		aliasOS: {
+			"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+			"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+		},
+		aliasArch: {
+			"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le",
+			"mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm",
+		},
+		aliasLibc: {
+			"gnu", "musl",
+		},
+	}
+
+	// aliasNames describe each kind of alias in errors.
+	aliasNames = map[string]string{
+		aliasOS:   "operating system",
+		aliasArch: "CPU architecture",
+		aliasLibc: "C library",
+	}
+
+	// legacyAliases are the flags (and per-repository config keys) which set a
+	// single alias. They predate --map, and are kept for compatibility.
+	legacyAliases = []legacyAlias{
+		{flag: "darwin", key: "os.darwin", usage: "When Darwin, set .OS to this value."},
+		{flag: "dragonfly", key: "os.dragonfly", usage: "When Dragonfly, set .OS to this value.", hidden: true},
+		{flag: "freebsd", key: "os.freebsd", usage: "When FreeBSD, set .OS to this value.", hidden: true},
+		{flag: "illumos", key: "os.illumos", usage: "When Illumos, set .OS to this value.", hidden: true},
+		{flag: "linux", key: "os.linux", usage: "When Linux, set .OS to this value."},
+		{flag: "netbsd", key: "os.netbsd", usage: "When NetBSD, set .OS to this value.", hidden: true},
+		{flag: "openbsd", key: "os.openbsd", usage: "When OpenBSD, set .OS to this value.", hidden: true},
+		{flag: "plan9", key: "os.plan9", usage: "When Plan9, set .OS to this value.", hidden: true},
+		{flag: "solaris", key: "os.solaris", usage: "When Solaris, set .OS to this value.", hidden: true},
+		{flag: "windows", key: "os.windows", usage: "When Windows, set .OS to this value."},
+
+		{flag: "arm32", key: "arch.arm", usage: "When 32-bit ARM, set .Arch to this value."},
+		{flag: "arm64", key: "arch.arm64", usage: "When 64-bit ARM, set .Arch to this value."},
+		{flag: "intel32", key: "arch.386", usage: "When 32-bit Intel-compat, set .Arch to this value."},
+		{flag: "intel64", key: "arch.amd64", usage: "When 64-bit Intel-compat, set .Arch to this value."},
+		{flag: "loong64", key: "arch.loong64", usage: "When 64-bit Loongson, set .Arch to this value.", hidden: true},
+		{flag: "mips32", key: "arch.mips", usage: "When 32-bit MIPS, set .Arch to this value.", hidden: true},
+		{
+			flag: "mips32le", configKey: "mips32-le", key: "arch.mipsle",
+			usage: "When 32-bit MIPS (LE), set .Arch to this value.", hidden: true,
+		},
+		{flag: "mips64", key: "arch.mips64", usage: "When 64-bit MIPS, set .Arch to this value.", hidden: true},
+		{
+			flag: "mips64le", configKey: "mips64-le", key: "arch.mips64le",
+			usage: "When 64-bit MIPS (LE), set .Arch to this value.", hidden: true,
+		},
+		{flag: "ppc64", key: "arch.ppc64", usage: "When 64-bit PowerPC, set .Arch to this value.", hidden: true},
+		{flag: "ppc64le", key: "arch.ppc64le", usage: "When 64-bit PowerPC (LE), set .Arch to this value.", hidden: true},
+		{flag: "riscv64", key: "arch.riscv64", usage: "When 64-bit RISC-V, set .Arch to this value.", hidden: true},
+		{flag: "s390x", key: "arch.s390x", usage: "When 64-bit s390x, set .Arch to this value.", hidden: true},
+
+		{flag: "gnu", key: "libc.gnu", usage: "When Linux with glibc, set .Libc to this value."},
+		{flag: "musl", key: "libc.musl", usage: "When Linux with musl, set .Libc to this value."},
+	}
+)
+
+// legacyAlias is a flag which sets one alias (e.g., --darwin sets os.darwin).
+type legacyAlias struct {
+	flag string
+	key  string
+
+	// configKey is the per-repository config key, if it isn't the flag name.
+	configKey string
+
+	usage  string
+	hidden bool
+}
+
+// configName returns the alias's per-repository config key.
+func (a legacyAlias) configName() string {
+	if a.configKey != "" {
+		return a.configKey
+	}
+
+	return a.flag
+}
+
+// aliasFlag is the value of a legacy alias flag, which it stores in
+// flagAliases so that it is looked up along with --map.
+type aliasFlag struct {
+	key string
+}
+
+func (f aliasFlag) String() string {
+	if v, ok := flagAliases[f.key]; ok {
+		return v
+	}
+
+	_, name, _ := strings.Cut(f.key, ".")
+
+	return name
+}
+
+func (f aliasFlag) Set(v string) error {
+	flagAliases[f.key] = v
+
+	return nil
+}
+
+func (f aliasFlag) Type() string {
+	return "string"
+}
+
+// applyConfigAliases reads the aliases from the config which applies to the
+// repository, using either the legacy keys (e.g., `darwin = "macOS"`) or a
+// `map` table. The repository's own table wins over its owner's, which wins
+// over [defaults]. Environment variables for the legacy keys win over all of
+// them.
+func applyConfigAliases(ownerRepo []string) {
+	configAliases = aliasTable{}
+	scopes := repoScopes(ownerRepo)
+
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for j := range legacyAliases {
+			key := scopes[i] + "." + legacyAliases[j].configName()
+
+			if viper.IsSet(key) {
+				configAliases[legacyAliases[j].key] = viper.GetString(key)
+			}
+		}
+
+		for k, v := range configAliasTable(scopes[i] + "." + aliasMapKey) {
+			configAliases[k] = v
+		}
+	}
+
+	// e.g., DOWNLOAD_ASSET_DARWIN wins over `darwin` in any table.
+	for j := range legacyAliases {
+		if v, ok := os.LookupEnv(configEnvName(legacyAliases[j].configName())); ok {
+			configAliases[legacyAliases[j].key] = v
+		}
+	}
+}
+
+// configAliasTable reads a `map` table from the config, which has a table for
+// each kind (e.g., `[map.os]` containing `darwin = "macOS"`).
+func configAliasTable(key string) aliasTable {
+	aliases := aliasTable{}
+
+	for kind := range knownNames {
+		for name, value := range viper.GetStringMapString(key + "." + kind) {
+			aliases[kind+"."+strings.ToLower(name)] = value
+		}
+	}
+
+	return aliases
+}
+
+// mapFlag is the value of --map. Each value is parsed into mapAliases as it is
+// set, so that a mistake is reported along with the other flags.
+type mapFlag []string
+
+func (f *mapFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *mapFlag) Set(v string) error {
+	key, value, err := parseAlias(v)
+	if err != nil {
+		return err
+	}
+
+	mapAliases[key] = value
+	*f = append(*f, v)
+
+	return nil
+}
+
+func (f *mapFlag) Type() string {
+	return "stringArray"
+}
+
+// parseAlias reads a --map value, as `kind.name=value`, and returns its key
+// (e.g., `os.darwin`) and value.
+func parseAlias(v string) (string, string, error) {
+	key, value, ok := strings.Cut(v, "=")
+	kind, name, hasName := strings.Cut(strings.ToLower(strings.TrimSpace(key)), ".")
+
+	if !ok || !hasName || name == "" {
+		return "", "", errors.New("expected kind.name=value (e.g., os.darwin=macOS)")
+	}
+
+	if _, known := knownNames[kind]; !known {
+		return "", "", errors.Errorf("the kind must be one of: %s", aliasKinds())
+	}
+
+	return kind + "." + name, value, nil
+}
+
+// lookupAlias returns the value of a pattern variable for a GOOS, GOARCH or C
+// library. --map wins over the legacy flags, then the repository's config,
+// then the top-level `map` table in the config, and finally the name itself.
+func lookupAlias(kind, name string) (string, error) {
+	key := kind + "." + name
+
+	for _, aliases := range []aliasTable{mapAliases, flagAliases, configAliases, configAliasTable(aliasMapKey)} {
+		if v, ok := aliases[key]; ok {
+			return v, nil
+		}
+	}
+
+	for _, known := range knownNames[kind] {
+		if known == name {
+			return name, nil
+		}
+	}
+
+	return "", errors.Errorf("unknown %s: %s; set its value with --map %s=VALUE", aliasNames[kind], name, key)
+}
+
+// aliasKinds returns the kinds of alias which --map accepts.
+func aliasKinds() string {
+	kinds := make([]string, 0, len(knownNames))
+
+	for kind := range knownNames {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	return strings.Join(kinds, ", ")
+}
@@ -0,0 +1,275 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestParseAlias(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input string
+		Key   string
+		Value string
+		Error bool
+	}{
+		"os": {
+			Input: "os.darwin=macOS",
+			Key:   "os.darwin",
+			Value: "macOS",
+		},
+		"key-lower-case": {
+			Input: " Arch.AMD64 =x86_64",
+			Key:   "arch.amd64",
+			Value: "x86_64",
+		},
+		"value-kept": {
+			Input: "libc.musl= Musl=1",
+			Key:   "libc.musl",
+			Value: " Musl=1",
+		},
+		"empty-value": {
+			Input: "os.linux=",
+			Key:   "os.linux",
+			Value: "",
+		},
+		"unknown-name": {
+			Input: "os.haiku=Haiku",
+			Key:   "os.haiku",
+			Value: "Haiku",
+		},
+		"missing-equals": {
+			Input: "os.darwin",
+			Error: true,
+		},
+		"missing-kind": {
+			Input: "darwin=macOS",
+			Error: true,
+		},
+		"missing-name": {
+			Input: "os.=macOS",
+			Error: true,
+		},
+		"unknown-kind": {
+			Input: "cpu.amd64=x86_64",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, value, err := parseAlias(tc.Input)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q=%q", key, value)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if key != tc.Key || value != tc.Value {
+				t.Errorf("got %q=%q; want %q=%q", key, value, tc.Key, tc.Value)
+			}
+		})
+	}
+}
+
+func TestMapFlag(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Args     []string
+		Expected aliasTable
+		Error    bool
+	}{
+		"repeated": {
+			Args:     []string{"--map", "os.darwin=macOS", "--map", "arch.amd64=x86_64"},
+			Expected: aliasTable{"os.darwin": "macOS", "arch.amd64": "x86_64"},
+		},
+		"later-wins": {
+			Args:     []string{"--map", "os.darwin=macOS", "--map", "os.darwin=Darwin"},
+			Expected: aliasTable{"os.darwin": "Darwin"},
+		},
+		"not-split-on-commas": {
+			Args:     []string{"--map", "os.darwin=mac,OS"},
+			Expected: aliasTable{"os.darwin": "mac,OS"},
+		},
+		"invalid": {
+			Args:  []string{"--map", "os.darwin=macOS", "--map", "darwin=macOS"},
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			restoreAliases(t)
+
+			cmd := &cobra.Command{}
+			handleFlags(cmd)
+
+			err := cmd.ParseFlags(tc.Args)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(mapAliases, tc.Expected) {
+				t.Errorf("got %v; want %v", mapAliases, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLookupAlias(t *testing.T) {
+	// The top-level `map` table applies to every repository.
+	config := map[string]string{
+		"project/download-asset.toml": `
+[map.os]
+darwin  = "top-level"
+freebsd = "top-level"
+`,
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Kind     string
+		Name     string
+		Map      aliasTable
+		Flags    aliasTable
+		Config   aliasTable
+		Expected string
+		Error    bool
+	}{
+		"map-wins": {
+			Kind:     aliasOS,
+			Name:     "darwin",
+			Map:      aliasTable{"os.darwin": "map"},
+			Flags:    aliasTable{"os.darwin": "flag"},
+			Config:   aliasTable{"os.darwin": "config"},
+			Expected: "map",
+		},
+		"flag-wins-over-config": {
+			Kind:     aliasOS,
+			Name:     "darwin",
+			Flags:    aliasTable{"os.darwin": "flag"},
+			Config:   aliasTable{"os.darwin": "config"},
+			Expected: "flag",
+		},
+		"repo-config-wins-over-top-level": {
+			Kind:     aliasOS,
+			Name:     "darwin",
+			Config:   aliasTable{"os.darwin": "config"},
+			Expected: "config",
+		},
+		"top-level": {
+			Kind:     aliasOS,
+			Name:     "freebsd",
+			Expected: "top-level",
+		},
+		"other-key": {
+			Kind:     aliasOS,
+			Name:     "windows",
+			Map:      aliasTable{"os.darwin": "map"},
+			Expected: "windows",
+		},
+		"kind-matters": {
+			Kind:     aliasArch,
+			Name:     "arm64",
+			Map:      aliasTable{"os.arm64": "map"},
+			Expected: "arm64",
+		},
+		"known-name": {
+			Kind:     aliasLibc,
+			Name:     "musl",
+			Expected: "musl",
+		},
+		"unknown-name-mapped": {
+			Kind:     aliasOS,
+			Name:     "haiku",
+			Map:      aliasTable{"os.haiku": "Haiku"},
+			Expected: "Haiku",
+		},
+		"unknown-name": {
+			Kind:  aliasOS,
+			Name:  "haiku",
+			Error: true,
+		},
+		"unknown-libc": {
+			Kind:  aliasLibc,
+			Name:  "bionic",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useConfig(t, config)
+			restoreAliases(t)
+
+			err := readConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for table, aliases := range map[*aliasTable]aliasTable{
+				&mapAliases: tc.Map, &flagAliases: tc.Flags, &configAliases: tc.Config,
+			} {
+				if aliases != nil {
+					*table = aliases
+				}
+			}
+
+			got, err := lookupAlias(tc.Kind, tc.Name)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+// restoreAliases empties the alias tables, and puts them back after the test.
+func restoreAliases(t *testing.T) {
+	t.Helper()
+
+	mapped, flags, config := mapAliases, flagAliases, configAliases
+	mapAliases, flagAliases, configAliases = aliasTable{}, aliasTable{}, aliasTable{}
+
+	t.Cleanup(func() {
+		mapAliases, flagAliases, configAliases = mapped, flags, config
+	})
+}
@@ -0,0 +1,252 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/changelog"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/northwood-labs/golang-utils/exiterrorf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fFrom     string
+	fTo       string
+	fMarkdown bool
+
+	// changelogCmd represents the changelog command
+	changelogCmd = &cobra.Command{
+		Use:   "changelog",
+		Short: "Shows the release notes between two versions of a package",
+		Long: LongHelpText(`
+		Shows the release notes of every release after --from, up to and including --to
+		(which defaults to the latest release), newest first. This is useful for reading
+		up on everything which changed before upgrading a tool.
+
+		--from and --to can be either a tag or a version, in the same way as --tag for
+		'get'. --from does not need to have a release of its own.
+
+		Sections whose heading mentions a breaking change or a deprecation are
+		highlighted, as are other lines which mention one.
+
+		Only stable releases are included, unless --channel is set to prerelease (to
+		also include pre-releases) or any (to also include drafts). --tag-prefix,
+		--tag-regex and --version-scheme work the same as for 'get'.
+
+		Set --markdown to print one combined Markdown document instead, e.g., for a
+		pull request description.`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := readConfig()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			}
+
+			if fFrom == "" {
+				exiterrorf.ExitErrorf(errors.New("missing the version to start from (--from)"))
+			}
+
+			// Apply values from configuration file.
+			err = applyConfigValues(cmd, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			source, err := newProvider(cmd)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create API client"))
+			}
+
+			to := fTo
+
+			if to == "" || to == "latest" {
+				release, err := source.GetLatestRelease(ownerRepo[0], ownerRepo[1])
+				if err != nil {
+					exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
+				}
+
+				to = release.TagName
+			}
+
+			releases, err := listVersions(source, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to list the versions"))
+			}
+
+			between, err := releasesBetween(releases, fFrom, to)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			entries := make([]changelog.Entry, 0, len(between))
+
+			for i := range between {
+				entries = append(entries, changelog.Entry{
+					Tag:  between[i].TagName,
+					Name: between[i].Name,
+					Date: between[i].PublishedAt,
+					Body: between[i].Body,
+				})
+			}
+
+			title := fmt.Sprintf(
+				"Changes to %s from %s to %s",
+				fOwnerRepo,
+				settings.TagFormat.Version(fFrom),
+				settings.TagFormat.Version(to),
+			)
+
+			if fMarkdown {
+				fmt.Print(changelog.Markdown(title, entries))
+
+				return
+			}
+
+			fmt.Print(changelog.Render(title, entries))
+		},
+	}
+)
+
+// releasesBetween returns the releases after from, up to and including to, from
+// a list which is sorted with the highest version first.
+func releasesBetween(releases []*provider.Release, from, to string) ([]*provider.Release, error) {
+	fromIndex := indexOfVersion(releases, from)
+	if fromIndex == -1 {
+		return nil, errors.Errorf("version %s was not found", from)
+	}
+
+	toIndex := indexOfVersion(releases, to)
+	if toIndex == -1 {
+		return nil, errors.Errorf("version %s was not found", to)
+	}
+
+	if toIndex > fromIndex {
+		return nil, errors.Errorf("version %s is older than %s", to, from)
+	}
+
+	return releases[toIndex:fromIndex], nil
+}
+
+// indexOfVersion finds a tag or version in a list of releases, in the same way
+// as a tag given to 'get'. It returns -1 if it is not found.
+func indexOfVersion(releases []*provider.Release, tagOrVersion string) int {
+	for i := range releases {
+		if releases[i].TagName == tagOrVersion {
+			return i
+		}
+	}
+
+	ver := settings.TagFormat.Version(tagOrVersion)
+
+	for i := range releases {
+		if settings.TagFormat.Version(releases[i].TagName) == ver {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+
+	changelogCmd.Flags().StringVarP(
+		&fOwnerRepo,
+		"owner-repo",
+		"r",
+		"",
+		"The owner and repository name in the format of 'owner/repo'.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fEndpoint,
+		"endpoint",
+		"e",
+		defaultEndpoint,
+		"The API domain to use.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fProvider,
+		"provider",
+		"",
+		"",
+		"Where releases are published: github, gitlab or gitea. Inferred from --endpoint if unset.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fFrom,
+		"from",
+		"f",
+		"",
+		"The tag or version to start from (e.g., the one which is installed). Its own notes are not included.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fTo,
+		"to",
+		"t",
+		"latest",
+		"The tag or version to end at, including its notes.",
+	)
+	changelogCmd.Flags().BoolVarP(
+		&fMarkdown,
+		"markdown",
+		"m",
+		false,
+		"Print one combined Markdown document instead of rendering it.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fChannel,
+		"channel",
+		"",
+		"",
+		"Which releases to include: stable, prerelease or any (which includes drafts). Defaults to stable.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fTagPrefix,
+		"tag-prefix",
+		"",
+		"",
+		"Only consider tags with this prefix, which comes before the version (e.g., 'cli/' for 'cli/v2.0.0').",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fTagRegex,
+		"tag-regex",
+		"",
+		"",
+		"Only consider tags matching this regular expression. Use a group named 'version' to capture the version.",
+	)
+	changelogCmd.Flags().StringVarP(
+		&fVersionScheme,
+		"version-scheme",
+		"",
+		"",
+		"How to compare versions: semver, calver, lexical, commit-date or regex. Defaults to semver.",
+	)
+	changelogCmd.Flags().BoolVarP(
+		&fNoWait,
+		"no-wait",
+		"",
+		false,
+		"Fail immediately when the GitHub API rate limit is hit, instead of waiting for it to reset.",
+	)
+
+	handleAuthFlags(changelogCmd)
+}
@@ -0,0 +1,173 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/github"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// resolveAuth decides how to authenticate with GitHub. GitHub App credentials
+// take priority; otherwise a token is looked up for the current endpoint. If
+// neither can be found, requests are made anonymously and a warning is printed.
+func resolveAuth(cmd *cobra.Command) error {
+	app, err := resolveAppAuth(cmd)
+	if err != nil {
+		return err
+	}
+
+	if app != nil {
+		apiApp = app
+		apiTokenSource = fmt.Sprintf("GitHub App %d (installation %d)", app.AppID, app.InstallationID)
+
+		return nil
+	}
+
+	apiToken, apiTokenSource = github.LookupToken(fEndpoint, configHostTokens())
+	warnAnonymous()
+
+	return nil
+}
+
+// warnAnonymous prints a warning if no token could be found.
+func warnAnonymous() {
+	if apiToken != "" {
+		return
+	}
+
+	if providerName == provider.GitHub {
+		fmt.Fprintln(
+			os.Stderr,
+			"No GitHub token found; making anonymous requests, which are limited to 60 per hour. "+
+				"Set GITHUB_TOKEN to raise the limit.",
+		)
+
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "No API token found; making anonymous requests, which may be rate limited.")
+}
+
+// resolveAppAuth reads the GitHub App settings from flags, falling back to the
+// top-level `app-id`, `installation-id` and `private-key-file` keys in the
+// config file. It returns nil if no GitHub App has been configured.
+func resolveAppAuth(cmd *cobra.Command) (*github.AppAuth, error) {
+	appID := fAppID
+	if !cmd.Flags().Changed("app-id") && viper.IsSet("app-id") {
+		appID = viper.GetInt64("app-id")
+	}
+
+	installationID := fInstallationID
+	if !cmd.Flags().Changed("installation-id") && viper.IsSet("installation-id") {
+		installationID = viper.GetInt64("installation-id")
+	}
+
+	privateKeyFile := fPrivateKeyFile
+	if !cmd.Flags().Changed("private-key-file") && viper.IsSet("private-key-file") {
+		privateKeyFile = viper.GetString("private-key-file")
+	}
+
+	if appID == 0 && installationID == 0 && privateKeyFile == "" {
+		return nil, nil
+	}
+
+	if appID == 0 || installationID == 0 || privateKeyFile == "" {
+		return nil, errors.New("GitHub App authentication requires all of app-id, installation-id and private-key-file")
+	}
+
+	privateKey, err := os.ReadFile(os.ExpandEnv(privateKeyFile)) // lint:allow_include_file
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the GitHub App private key")
+	}
+
+	return &github.AppAuth{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+	}, nil
+}
+
+// tokenDescription is what verbose output displays for the token.
+func tokenDescription() string {
+	if apiApp != nil {
+		return apiTokenSource
+	}
+
+	if apiToken == "" {
+		return "(anonymous)"
+	}
+
+	// Registry credentials may be a username and password, which should not be
+	// partially revealed.
+	if username, _, found := strings.Cut(apiToken, ":"); found && providerName == provider.OCI {
+		return username + ":******** from " + apiTokenSource
+	}
+
+	return github.MaskToken(apiToken) + " from " + apiTokenSource
+}
+
+// handleAuthFlags adds the flags for authenticating as a GitHub App.
+func handleAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64VarP(
+		&fAppID,
+		"app-id",
+		"",
+		0,
+		"Authenticate as the GitHub App with this ID.",
+	)
+	cmd.Flags().Int64VarP(
+		&fInstallationID,
+		"installation-id",
+		"",
+		0,
+		"The installation ID of the GitHub App.",
+	)
+	cmd.Flags().StringVarP(
+		&fPrivateKeyFile,
+		"private-key-file",
+		"",
+		"",
+		"The path to the PEM-encoded private key of the GitHub App.",
+	)
+}
+
+// configHostTokens reads per-host tokens from the config file, in the format:
+//
+//	[hosts."github.company.com"]
+//	token = "..."
+func configHostTokens() map[string]string {
+	tokens := map[string]string{}
+	hosts := viper.GetStringMap("hosts")
+
+	for host := range hosts {
+		settings, ok := hosts[host].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if token, ok := settings["token"].(string); ok {
+			tokens[strings.ToLower(host)] = token
+		}
+	}
+
+	return tokens
+}
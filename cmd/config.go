@@ -0,0 +1,443 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/northwood-labs/golang-utils/exiterrorf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	configFileName = "download-asset.toml"
+
+	// defaultsTable is the table whose values apply to every repository.
+	defaultsTable = "defaults"
+
+	// archFallbacksKey is the table of .Arch values to fall back to, by OS or
+	// os/arch.
+	archFallbacksKey = "arch-fallbacks"
+
+	// envPrefix is the prefix of the environment variables which override the
+	// config for the repository (e.g., DOWNLOAD_ASSET_WRITE_TO_BIN).
+	envPrefix = "DOWNLOAD_ASSET_"
+)
+
+var (
+	// configDirs are the directories which are searched for the config file,
+	// in the order they are merged. Later ones win.
+	configDirs = []string{
+		"/etc/download-asset/",   // System.
+		"$HOME/.download-asset/", // Then the user.
+		".",                      // Then the project.
+	}
+
+	// reservedConfigKeys are the top-level keys which aren't an owner's table.
+	reservedConfigKeys = []string{
+		defaultsTable, aliasMapKey, "hosts", "include", "app-id", "installation-id", "private-key-file",
+	}
+
+	// repoConfigTables are the keys for a repository which are tables.
+	repoConfigTables = []string{archFallbacksKey, aliasMapKey}
+
+	// configFiles are the config files which were read, including any they
+	// include, in the order they were merged.
+	configFiles []configFile
+
+	// configCmd represents the config command
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the configuration files",
+	}
+
+	// configShowCmd represents the config show command
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Shows the effective configuration for a repository, and where each value comes from",
+		Long: LongHelpText(`
+		Shows the configuration which applies to a repository, after merging every
+		download-asset.toml file and applying any DOWNLOAD_ASSET_* environment variables,
+		along with where each value comes from.
+
+		Config files are merged from /etc/download-asset/, then $HOME/.download-asset/,
+		then the current directory, with later ones winning. Each one can include other
+		files with include = ["team.toml"], which it wins over.
+
+		For a repository, the [owner.repo] table wins over the [owner] table, which wins
+		over the [defaults] table. Environment variables (e.g., DOWNLOAD_ASSET_TAG or
+		DOWNLOAD_ASSET_DARWIN) win over all of them, and flags which are passed
+		explicitly win over everything. DOWNLOAD_ASSET_ARCH_FALLBACKS takes the same
+		values as --arch-fallback, separated by spaces. The map tables can't be set
+		from the environment; use --map instead.`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := readConfig()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			}
+
+			err = checkRepoScopes(ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+				BorderColumn(true).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("KEY", "VALUE", "SOURCE")
+
+			for _, row := range configRows(ownerRepo) {
+				t.Row(row...)
+			}
+
+			fmt.Println(t.Render())
+		},
+	}
+)
+
+// configFile is a config file which has been read on its own, so that the
+// source of each value can be found.
+type configFile struct {
+	path string
+	v    *viper.Viper
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	configShowCmd.Flags().StringVarP(
+		&fOwnerRepo,
+		"owner-repo",
+		"r",
+		"",
+		"The owner and repository name in the format of 'owner/repo'.",
+	)
+}
+
+// readConfig merges every config file which exists, along with the files they
+// include.
+func readConfig() error {
+	configFiles = nil
+	seen := map[string]bool{}
+
+	for _, dir := range configDirs {
+		path := filepath.Join(os.ExpandEnv(dir), configFileName)
+
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Config file not found; ignore error.
+			continue
+		} else if err != nil {
+			return errors.Wrap(err, "failed to read config file")
+		}
+
+		err = loadConfigFile(path, seen)
+		if err != nil {
+			return err
+		}
+	}
+
+	viper.SetConfigType("toml")
+
+	for i := range configFiles {
+		err := mergeConfigFile(configFiles[i].path)
+		if err != nil {
+			return err
+		}
+	}
+
+	// e.g., DOWNLOAD_ASSET_APP_ID for app-id.
+	viper.SetEnvPrefix(strings.TrimSuffix(envPrefix, "_"))
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	viper.AutomaticEnv()
+
+	return nil
+}
+
+// loadConfigFile reads a config file, after the files it includes so that it
+// wins over them. Include paths are relative to the file which includes them.
+// Each file is only read once, which also stops include loops.
+func loadConfigFile(path string, seen map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve config file %s", path)
+	}
+
+	if seen[abs] {
+		return nil
+	}
+
+	seen[abs] = true
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+
+	err = v.ReadInConfig()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	for _, include := range configStrings(v.Get("include")) {
+		include = os.ExpandEnv(include)
+
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		err = loadConfigFile(include, seen)
+		if err != nil {
+			return errors.Wrapf(err, "failed to include config file from %s", path)
+		}
+	}
+
+	configFiles = append(configFiles, configFile{path: path, v: v})
+
+	return nil
+}
+
+// mergeConfigFile merges a config file into the global config. The file is
+// merged as-is, so that table names with dots in them (e.g., host names) are
+// kept whole.
+func mergeConfigFile(path string) error {
+	f, err := os.Open(path) // lint:allow_include_file
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	defer f.Close()
+
+	err = viper.MergeConfig(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	return nil
+}
+
+// repoScopes returns the tables which apply to a repository, with the most
+// specific first.
+func repoScopes(ownerRepo []string) []string {
+	return []string{strings.Join(ownerRepo, "."), ownerRepo[0], defaultsTable}
+}
+
+// repoConfigKeys returns the keys which can be set for a repository, its owner
+// or [defaults]. Each one is a value, except for the tables in
+// repoConfigTables.
+func repoConfigKeys() []string {
+	keys := []string{"tag", "constraint", "pattern", archFallbacksKey, aliasMapKey}
+
+	for k := range configFlagMap() {
+		keys = append(keys, k)
+	}
+
+	for i := range legacyAliases {
+		keys = append(keys, legacyAliases[i].configName())
+	}
+
+	return keys
+}
+
+// checkRepoScopes returns an error if the config for a repository can't be
+// told apart from other config:
+//
+//   - An owner named like a top-level key (e.g., [map]).
+//   - A repository table named like a value which its owner's table can set
+//     (e.g., [owner.tag] and `tag` in [owner]). This breaks every repository
+//     of the owner, because its `tag` would be the table.
+//   - A repository named like a table which its owner's table can have (e.g.,
+//     [owner.map]).
+func checkRepoScopes(ownerRepo []string) error {
+	owner, repo := strings.ToLower(ownerRepo[0]), strings.ToLower(ownerRepo[1])
+
+	if slices.Contains(reservedConfigKeys, owner) {
+		if path, ok := configFileWith(owner); ok {
+			return errors.Errorf(
+				"the owner %q has the same name as the top-level %q key in %s, so its config can't be told apart; "+
+					"use flags or %s* environment variables instead",
+				ownerRepo[0], owner, path, envPrefix,
+			)
+		}
+	}
+
+	for _, key := range repoConfigKeys() {
+		full := owner + "." + key
+
+		for i := range configFiles {
+			if !configFiles[i].v.IsSet(full) {
+				continue
+			}
+
+			_, isTable := configFiles[i].v.Get(full).(map[string]any)
+
+			switch {
+			case slices.Contains(repoConfigTables, key):
+				if key == repo {
+					return errors.Errorf(
+						"the repository %q has the same name as the [%s] table in %s, so its config can't be told apart; "+
+							"use flags or %s* environment variables instead",
+						ownerRepo[1], full, configFiles[i].path, envPrefix,
+					)
+				}
+			case isTable:
+				return errors.Errorf(
+					"[%s] in %s is a table, so it can't be told apart from the %q key of [%s]; "+
+						"configure %s/%s with flags or %s* environment variables instead",
+					full, configFiles[i].path, key, owner, owner, key, envPrefix,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// configFileWith returns the last config file which sets a key.
+func configFileWith(key string) (string, bool) {
+	for i := len(configFiles) - 1; i >= 0; i-- {
+		if configFiles[i].v.IsSet(key) {
+			return configFiles[i].path, true
+		}
+	}
+
+	return "", false
+}
+
+// repoConfigValue returns the value of a key for a repository, and where it
+// came from. An environment variable wins over the config files.
+func repoConfigValue(ownerRepo []string, key string) (any, string, bool) {
+	name := configEnvName(key)
+
+	if v, ok := os.LookupEnv(name); ok {
+		return v, "$" + name, true
+	}
+
+	return lookupConfig(repoScopes(ownerRepo), key)
+}
+
+// configEnvName returns the environment variable which overrides a config key
+// (e.g., DOWNLOAD_ASSET_WRITE_TO_BIN for write-to-bin).
+func configEnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// lookupConfig returns the value of a key from the first of the tables which
+// sets it, and where it came from. An empty table is the top level.
+func lookupConfig(scopes []string, key string) (any, string, bool) {
+	for _, scope := range scopes {
+		full := strings.TrimPrefix(scope+"."+key, ".")
+
+		for i := len(configFiles) - 1; i >= 0; i-- {
+			if configFiles[i].v.IsSet(full) {
+				table := full[:strings.LastIndex(full, ".")+1]
+
+				return configFiles[i].v.Get(full), fmt.Sprintf(
+					"%s [%s]", configFiles[i].path, strings.TrimSuffix(table, "."),
+				), true
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// configRows returns the key, value and source of every config value which
+// applies to a repository.
+func configRows(ownerRepo []string) [][]string {
+	rows := [][]string{}
+	scopes := repoScopes(ownerRepo)
+
+	keys := []string{"tag", "constraint", "pattern"}
+	for k := range configFlagMap() {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if v, source, ok := repoConfigValue(ownerRepo, key); ok {
+			rows = append(rows, []string{key, strings.Join(configStrings(v), ", "), source})
+		}
+	}
+
+	for i := range legacyAliases {
+		key := legacyAliases[i].configName()
+
+		if v, source, ok := repoConfigValue(ownerRepo, key); ok {
+			rows = append(rows, []string{key, fmt.Sprint(v), source})
+		}
+	}
+
+	if v, ok := os.LookupEnv(configEnvName(archFallbacksKey)); ok {
+		rows = append(rows, []string{archFallbacksKey, v, "$" + configEnvName(archFallbacksKey)})
+	}
+
+	for _, key := range configTableKeys(scopes, archFallbacksKey) {
+		if v, source, ok := lookupConfig(scopes, key); ok {
+			rows = append(rows, []string{key, strings.Join(configStrings(v), ", "), source})
+		}
+	}
+
+	// The top-level `map` table applies to every repository too.
+	for _, kind := range []string{aliasOS, aliasArch, aliasLibc} {
+		for _, key := range configTableKeys(append(scopes, ""), aliasMapKey+"."+kind) {
+			if v, source, ok := lookupConfig(append(scopes, ""), key); ok {
+				rows = append(rows, []string{key, fmt.Sprint(v), source})
+			}
+		}
+	}
+
+	return rows
+}
+
+// configTableKeys returns the keys in a table (e.g., `arch-fallbacks`) for any
+// of the scopes, sorted and prefixed with the table's name.
+func configTableKeys(scopes []string, table string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+
+	for _, scope := range scopes {
+		for k := range viper.GetStringMap(strings.TrimPrefix(scope+"."+table, ".")) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, table+"."+k)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
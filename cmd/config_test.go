@@ -0,0 +1,650 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// repoConfig sets a value in every scope which applies to o/r.
+const repoConfig = `
+[defaults]
+tag          = "v1.0.0"
+pattern      = "defaults"
+write-to-bin = "defaults"
+darwin       = "macOS"
+
+[defaults.arch-fallbacks]
+darwin = ["all"]
+
+[o]
+pattern      = "owner"
+write-to-bin = "owner"
+constraint   = "~> 1.0"
+
+[o.arch-fallbacks]
+"linux/arm" = ["armv6"]
+
+[o.r]
+write-to-bin = "tool"
+
+[o.r.map.arch]
+amd64 = "x86_64"
+
+[map.os]
+linux = "Linux"
+`
+
+// useConfig writes config files under a temporary directory, and searches its
+// etc, home and project directories instead of the real ones.
+func useConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0o755) // lint:allow_raw_number
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0o600) // lint:allow_raw_number
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirs := configDirs
+	configDirs = []string{
+		filepath.Join(root, "etc"),
+		filepath.Join(root, "home"),
+		filepath.Join(root, "project"),
+	}
+
+	t.Cleanup(func() {
+		configDirs = dirs
+		configFiles = nil
+		configAliases = aliasTable{}
+
+		viper.Reset()
+	})
+
+	return root
+}
+
+func TestReadConfig(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Files    map[string]string
+		Key      string
+		Expected string
+		Source   string
+		Count    int
+		Error    bool
+	}{
+		"later-directory-wins": {
+			Files: map[string]string{
+				"etc/download-asset.toml":     "[defaults]\ntag = \"v1\"\n",
+				"home/download-asset.toml":    "[defaults]\ntag = \"v2\"\n",
+				"project/download-asset.toml": "[defaults]\nconstraint = \"~> 1\"\n",
+			},
+			Key:      "defaults.tag",
+			Expected: "v2",
+			Source:   "home/download-asset.toml",
+			Count:    3,
+		},
+		"earlier-directory-kept": {
+			Files: map[string]string{
+				"etc/download-asset.toml":  "[defaults]\ntag = \"v1\"\n",
+				"home/download-asset.toml": "[defaults]\nconstraint = \"~> 1\"\n",
+			},
+			Key:      "defaults.tag",
+			Expected: "v1",
+			Source:   "etc/download-asset.toml",
+			Count:    2,
+		},
+		"include-relative": {
+			Files: map[string]string{
+				"project/download-asset.toml": "include = [\"team/team.toml\"]\n",
+				"project/team/team.toml":      "include = [\"base.toml\"]\n[defaults]\ntag = \"v3\"\n",
+				"project/team/base.toml":      "[defaults]\nconstraint = \"~> 1\"\n",
+			},
+			Key:      "defaults.constraint",
+			Expected: "~> 1",
+			Source:   "project/team/base.toml",
+			Count:    3,
+		},
+		"includer-wins": {
+			Files: map[string]string{
+				"project/download-asset.toml": "include = [\"team.toml\"]\n[defaults]\ntag = \"v2\"\n",
+				"project/team.toml":           "[defaults]\ntag = \"v1\"\n",
+			},
+			Key:      "defaults.tag",
+			Expected: "v2",
+			Source:   "project/download-asset.toml",
+			Count:    2,
+		},
+		"include-loop": {
+			Files: map[string]string{
+				"project/download-asset.toml": "include = [\"a.toml\"]\n",
+				"project/a.toml":              "include = [\"download-asset.toml\"]\n[defaults]\ntag = \"v1\"\n",
+			},
+			Key:      "defaults.tag",
+			Expected: "v1",
+			Source:   "project/a.toml",
+			Count:    2,
+		},
+		"include-missing": {
+			Files: map[string]string{
+				"project/download-asset.toml": "include = [\"missing.toml\"]\n",
+			},
+			Error: true,
+		},
+		"invalid": {
+			Files: map[string]string{
+				"project/download-asset.toml": "[defaults\n",
+			},
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := useConfig(t, tc.Files)
+
+			err := readConfig()
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(configFiles) != tc.Count {
+				t.Errorf("got %d config files; want %d", len(configFiles), tc.Count)
+			}
+
+			if got := viper.GetString(tc.Key); got != tc.Expected {
+				t.Errorf("got merged %q; want %q", got, tc.Expected)
+			}
+
+			v, source, ok := lookupConfig([]string{""}, tc.Key)
+			if !ok {
+				t.Fatalf("expected %s to be set", tc.Key)
+			}
+
+			if v != tc.Expected {
+				t.Errorf("got %q; want %q", v, tc.Expected)
+			}
+
+			if expected := filepath.Join(root, tc.Source) + " [defaults]"; source != expected {
+				t.Errorf("got source %q; want %q", source, expected)
+			}
+		})
+	}
+}
+
+func TestRepoConfigValue(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		OwnerRepo []string
+		Env       map[string]string
+		Key       string
+		Expected  string
+		Source    string
+		Unset     bool
+	}{
+		"repo-wins": {
+			OwnerRepo: []string{"o", "r"},
+			Key:       "write-to-bin",
+			Expected:  "tool",
+			Source:    "[o.r]",
+		},
+		"owner-wins-over-defaults": {
+			OwnerRepo: []string{"o", "r"},
+			Key:       "pattern",
+			Expected:  "owner",
+			Source:    "[o]",
+		},
+		"defaults": {
+			OwnerRepo: []string{"o", "r"},
+			Key:       "tag",
+			Expected:  "v1.0.0",
+			Source:    "[defaults]",
+		},
+		"other-repo": {
+			OwnerRepo: []string{"o", "other"},
+			Key:       "write-to-bin",
+			Expected:  "owner",
+			Source:    "[o]",
+		},
+		"other-owner": {
+			OwnerRepo: []string{"other", "r"},
+			Key:       "write-to-bin",
+			Expected:  "defaults",
+			Source:    "[defaults]",
+		},
+		"env-wins": {
+			OwnerRepo: []string{"o", "r"},
+			Env:       map[string]string{"DOWNLOAD_ASSET_WRITE_TO_BIN": "env"},
+			Key:       "write-to-bin",
+			Expected:  "env",
+			Source:    "$DOWNLOAD_ASSET_WRITE_TO_BIN",
+		},
+		"env-empty": {
+			OwnerRepo: []string{"o", "r"},
+			Env:       map[string]string{"DOWNLOAD_ASSET_TAG": ""},
+			Key:       "tag",
+			Expected:  "",
+			Source:    "$DOWNLOAD_ASSET_TAG",
+		},
+		"unset": {
+			OwnerRepo: []string{"o", "r"},
+			Key:       "url",
+			Unset:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := useConfig(t, map[string]string{"project/download-asset.toml": repoConfig})
+
+			for k, v := range tc.Env {
+				t.Setenv(k, v)
+			}
+
+			err := readConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v, source, ok := repoConfigValue(tc.OwnerRepo, tc.Key)
+			if tc.Unset {
+				if ok {
+					t.Errorf("expected %s to be unset; got %v from %s", tc.Key, v, source)
+				}
+
+				return
+			}
+
+			if !ok {
+				t.Fatalf("expected %s to be set", tc.Key)
+			}
+
+			if v != tc.Expected {
+				t.Errorf("got %q; want %q", v, tc.Expected)
+			}
+
+			expected := tc.Source
+			if expected[0] == '[' {
+				expected = filepath.Join(root, "project", configFileName) + " " + expected
+			}
+
+			if source != expected {
+				t.Errorf("got source %q; want %q", source, expected)
+			}
+		})
+	}
+}
+
+func TestConfigRows(t *testing.T) {
+	root := useConfig(t, map[string]string{"project/download-asset.toml": repoConfig})
+	file := filepath.Join(root, "project", configFileName)
+
+	t.Setenv("DOWNLOAD_ASSET_TAG", "v2.0.0")
+	t.Setenv("DOWNLOAD_ASSET_DARWIN", "Mac")
+	t.Setenv("DOWNLOAD_ASSET_ARCH_FALLBACKS", "linux/arm=armv5")
+
+	err := readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"constraint", "~> 1.0", file + " [o]"},
+		{"pattern", "owner", file + " [o]"},
+		{"tag", "v2.0.0", "$DOWNLOAD_ASSET_TAG"},
+		{"write-to-bin", "tool", file + " [o.r]"},
+		{"darwin", "Mac", "$DOWNLOAD_ASSET_DARWIN"},
+		{"arch-fallbacks", "linux/arm=armv5", "$DOWNLOAD_ASSET_ARCH_FALLBACKS"},
+		{"arch-fallbacks.darwin", "all", file + " [defaults.arch-fallbacks]"},
+		{"arch-fallbacks.linux/arm", "armv6", file + " [o.arch-fallbacks]"},
+		{"map.os.linux", "Linux", file + " [map.os]"},
+		{"map.arch.amd64", "x86_64", file + " [o.r.map.arch]"},
+	}
+
+	if got := configRows([]string{"o", "r"}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q; want %q", got, expected)
+	}
+}
+
+func TestApplyConfigValues(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Env           map[string]string
+		Changed       []string
+		ArchFallbacks []string
+
+		WriteToBin       string
+		Tag              string
+		Constraint       string
+		Darwin           string
+		ExpectedFallback []string
+	}{
+		"config": {
+			WriteToBin:       "tool",
+			Tag:              "v1.0.0",
+			Constraint:       "~> 1.0",
+			Darwin:           "macOS",
+			ExpectedFallback: []string{"darwin=all", "linux/arm=armv6"},
+		},
+		"env": {
+			Env: map[string]string{
+				"DOWNLOAD_ASSET_WRITE_TO_BIN":   "env",
+				"DOWNLOAD_ASSET_DARWIN":         "Mac",
+				"DOWNLOAD_ASSET_ARCH_FALLBACKS": "linux/arm=armv5  darwin=universal",
+			},
+			WriteToBin: "env",
+			Tag:        "v1.0.0",
+			Constraint: "~> 1.0",
+			Darwin:     "Mac",
+			ExpectedFallback: []string{
+				"darwin=all", "linux/arm=armv6", "linux/arm=armv5", "darwin=universal",
+			},
+		},
+		"flags-win": {
+			Env:           map[string]string{"DOWNLOAD_ASSET_WRITE_TO_BIN": "env"},
+			Changed:       []string{"write-to-bin", "tag"},
+			ArchFallbacks: []string{"darwin=flag"},
+			WriteToBin:    "flag",
+			Tag:           "flag",
+			Constraint:    "",
+			Darwin:        "macOS",
+			ExpectedFallback: []string{
+				"darwin=all", "linux/arm=armv6", "darwin=flag",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useConfig(t, map[string]string{"project/download-asset.toml": repoConfig})
+			restoreConfigFlags(t)
+
+			for k, v := range tc.Env {
+				t.Setenv(k, v)
+			}
+
+			err := readConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := &cobra.Command{}
+			cmd.Flags().StringVar(&fWriteToBin, "write-to-bin", "", "")
+			cmd.Flags().StringVar(&fTag, "tag", "", "")
+			cmd.Flags().StringVar(&fConstraint, "constraint", "", "")
+
+			for _, name := range tc.Changed {
+				err = cmd.Flags().Set(name, "flag")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fArchFallbacks = tc.ArchFallbacks
+
+			err = applyConfigValues(cmd, []string{"o", "r"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fWriteToBin != tc.WriteToBin {
+				t.Errorf("got write-to-bin %q; want %q", fWriteToBin, tc.WriteToBin)
+			}
+
+			if fTag != tc.Tag || fConstraint != tc.Constraint {
+				t.Errorf("got tag %q and constraint %q; want %q and %q", fTag, fConstraint, tc.Tag, tc.Constraint)
+			}
+
+			if got := configAliases["os.darwin"]; got != tc.Darwin {
+				t.Errorf("got darwin alias %q; want %q", got, tc.Darwin)
+			}
+
+			if !reflect.DeepEqual(fArchFallbacks, tc.ExpectedFallback) {
+				t.Errorf("got arch fallbacks %q; want %q", fArchFallbacks, tc.ExpectedFallback)
+			}
+		})
+	}
+}
+
+func TestApplyConfigValuesFlagPrecedence(t *testing.T) {
+	const pinned = `
+[o.r]
+tag       = "v1.2.0"
+channel   = "prerelease"
+tag-regex = "^cli/(?P<version>.+)$"
+endpoint  = "github.example.com"
+`
+
+	const constrained = `
+[o.r]
+constraint = "~> 1.5"
+`
+
+	var tests = map[string]struct { // lint:no_dupe
+		Config  string
+		Changed map[string]string
+
+		Tag        string
+		Constraint string
+		Channel    string
+		TagRegex   string
+		Endpoint   string
+	}{
+		"config-wins-over-defaults": {
+			Config:   pinned,
+			Tag:      "v1.2.0",
+			Channel:  "prerelease",
+			TagRegex: "^cli/(?P<version>.+)$",
+			Endpoint: "github.example.com",
+		},
+		"constraint-from-repo": {
+			Config:     constrained,
+			Tag:        "latest",
+			Constraint: "~> 1.5",
+			Endpoint:   defaultEndpoint,
+		},
+		"explicit-flags-win": {
+			Config:   pinned,
+			Changed:  map[string]string{"channel": "stable", "endpoint": "api.github.com"},
+			Tag:      "v1.2.0",
+			Channel:  "stable",
+			TagRegex: "^cli/(?P<version>.+)$",
+			Endpoint: "api.github.com",
+		},
+		"explicit-default-value-wins": {
+			Config:   pinned,
+			Changed:  map[string]string{"tag": "latest"},
+			Tag:      "latest",
+			Channel:  "prerelease",
+			TagRegex: "^cli/(?P<version>.+)$",
+			Endpoint: "github.example.com",
+		},
+		"constraint-flag-replaces-tag": {
+			Config:     pinned,
+			Changed:    map[string]string{"constraint": "~> 2.0"},
+			Tag:        "latest",
+			Constraint: "~> 2.0",
+			Channel:    "prerelease",
+			TagRegex:   "^cli/(?P<version>.+)$",
+			Endpoint:   "github.example.com",
+		},
+		"tag-flag-replaces-constraint": {
+			Config:   constrained,
+			Changed:  map[string]string{"tag": "v1.0.0"},
+			Tag:      "v1.0.0",
+			Endpoint: defaultEndpoint,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useConfig(t, map[string]string{"project/download-asset.toml": tc.Config})
+			restoreConfigFlags(t)
+
+			err := readConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The defaults are the same as for 'get'.
+			cmd := &cobra.Command{}
+			cmd.Flags().StringVar(&fTag, "tag", "latest", "")
+			cmd.Flags().StringVar(&fConstraint, "constraint", "", "")
+			cmd.Flags().StringVar(&fChannel, "channel", "", "")
+			cmd.Flags().StringVar(&fTagRegex, "tag-regex", "", "")
+			cmd.Flags().StringVar(&fEndpoint, "endpoint", defaultEndpoint, "")
+
+			for name, value := range tc.Changed {
+				err = cmd.Flags().Set(name, value)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = applyConfigValues(cmd, []string{"o", "r"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{fTag, fConstraint, fChannel, fTagRegex, fEndpoint}
+			expected := []string{tc.Tag, tc.Constraint, tc.Channel, tc.TagRegex, tc.Endpoint}
+
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("got tag, constraint, channel, tag-regex and endpoint %q; want %q", got, expected)
+			}
+		})
+	}
+}
+
+func TestCheckRepoScopes(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Files     map[string]string
+		OwnerRepo []string
+		Error     bool
+	}{
+		"repo": {
+			Files:     map[string]string{"project/download-asset.toml": repoConfig},
+			OwnerRepo: []string{"o", "r"},
+		},
+		"no-config": {
+			OwnerRepo: []string{"map", "os"},
+		},
+		"reserved-owner-unused": {
+			Files:     map[string]string{"project/download-asset.toml": "[o.r]\ntag = \"v1\"\n"},
+			OwnerRepo: []string{"hosts", "r"},
+		},
+		"reserved-owner": {
+			Files:     map[string]string{"project/download-asset.toml": repoConfig},
+			OwnerRepo: []string{"map", "os"},
+			Error:     true,
+		},
+		"reserved-owner-case": {
+			Files:     map[string]string{"project/download-asset.toml": repoConfig},
+			OwnerRepo: []string{"Defaults", "r"},
+			Error:     true,
+		},
+		"owner-value-named-like-repo": {
+			Files:     map[string]string{"project/download-asset.toml": "[o]\ntag = \"v1\"\n"},
+			OwnerRepo: []string{"o", "tag"},
+		},
+		"repo-table-named-like-key": {
+			Files:     map[string]string{"project/download-asset.toml": "[o.tag]\nwrite-to-bin = \"tag\"\n"},
+			OwnerRepo: []string{"o", "tag"},
+			Error:     true,
+		},
+		"repo-table-named-like-key-breaks-owner": {
+			Files:     map[string]string{"project/download-asset.toml": "[o.darwin]\nwrite-to-bin = \"darwin\"\n"},
+			OwnerRepo: []string{"o", "r"},
+			Error:     true,
+		},
+		"repo-table-across-files": {
+			Files: map[string]string{
+				"home/download-asset.toml":    "[o]\ntag = \"v1\"\n",
+				"project/download-asset.toml": "[o.tag]\nwrite-to-bin = \"tag\"\n",
+			},
+			OwnerRepo: []string{"o", "r"},
+			Error:     true,
+		},
+		"repo-named-like-table": {
+			Files:     map[string]string{"project/download-asset.toml": repoConfig},
+			OwnerRepo: []string{"o", "arch-fallbacks"},
+			Error:     true,
+		},
+		"repo-named-like-unset-table": {
+			Files:     map[string]string{"project/download-asset.toml": repoConfig},
+			OwnerRepo: []string{"o", "map"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useConfig(t, tc.Files)
+
+			err := readConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = checkRepoScopes(tc.OwnerRepo)
+			if tc.Error && err == nil {
+				t.Error("expected an error")
+			} else if !tc.Error && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// restoreConfigFlags puts back the flags which applyConfigValues sets.
+func restoreConfigFlags(t *testing.T) {
+	t.Helper()
+
+	values := map[*string]string{&fTag: fTag, &fConstraint: fConstraint}
+	for _, v := range configFlagMap() {
+		values[v] = *v
+	}
+
+	patterns, fallbacks := fPatterns, fArchFallbacks
+
+	t.Cleanup(func() {
+		for v, value := range values {
+			*v = value
+		}
+
+		fPatterns, fArchFallbacks = patterns, fallbacks
+	})
+}
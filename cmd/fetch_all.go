@@ -0,0 +1,540 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/northwood-labs/download-asset/github"
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/northwood-labs/golang-utils/exiterrorf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// manifestName is the file in --out which lists the digests of every binary.
+const manifestName = "SHA256SUMS"
+
+var (
+	fPlatforms []string
+	fOut       string
+
+	// fetchAllCmd represents the fetch-all command
+	fetchAllCmd = &cobra.Command{
+		Use:   "fetch-all",
+		Short: "Downloads an asset for several platforms at once",
+		Long: LongHelpText(`
+		Downloads the same release for several platforms at once (e.g., to mirror a tool
+		for every platform which a release pipeline supports), and writes each binary to
+		--out/<os>_<arch>/<name>, where <name> is --write-to-bin.
+
+		--platforms is a comma-separated list of platforms, in the same format as
+		--platform for 'get' (e.g., linux/amd64,linux/arm64,darwin/arm64,windows/amd64).
+		Each one goes through the same OS and CPU flags as 'get' (e.g., --linux Linux),
+		so the same --pattern works for all of them. On Windows, .exe is added to
+		<name> if it has no extension.
+
+		Every platform is checked for a matching asset before anything is downloaded,
+		and the command fails if any of them has none. The downloads then run
+		concurrently. Finally, the SHA-256 digests of the binaries are written to
+		--out/SHA256SUMS, which can be checked with 'sha256sum -c'.
+
+		--------------------------------------------------------------------------------
+
+		The same variables as for 'get' are supported (e.g., {{.Ver}}, {{.OS}},
+		{{.Arch}}, {{.Ext}} and {{.Triple}}). These can be used with:
+		    --pattern, --archive-path, --write-to-bin.
+
+		Go itself (--provider golang) can't be downloaded with fetch-all.`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := readConfig()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			}
+
+			// Apply values from configuration file.
+			err = applyConfigValues(cmd, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			platforms, err := parsePlatforms(fPlatforms)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			if fWriteToBin == "" {
+				exiterrorf.ExitErrorf(errors.New("missing write-to-bin"))
+			}
+
+			targets := make([]targetPlatform, len(platforms))
+
+			for i := range platforms {
+				targets[i], err = newTargetPlatform(platforms[i])
+				if err != nil {
+					exiterrorf.ExitErrorf(errors.Wrapf(err, "invalid platform %s", platforms[i]))
+				}
+			}
+
+			// The provider is created for the first platform, and the OCI and
+			// HashiCorp providers are copied for the others.
+			target = targets[0]
+
+			source, err := newProvider(cmd)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create API client"))
+			}
+
+			if providerName == provider.Golang {
+				exiterrorf.ExitErrorf(errors.New("fetch-all does not support the golang provider"))
+			}
+
+			if len(nonEmpty(fPatterns)) == 0 && providerName != provider.HashiCorp && providerName != provider.URL &&
+				providerName != provider.OCI {
+				exiterrorf.ExitErrorf(errors.New("missing pattern"))
+			}
+
+			release, err = discoverRelease(source, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
+			}
+
+			jobs := make([]*fetchJob, 0, len(targets))
+			failed := false
+
+			for i := range targets {
+				job, err := newFetchJob(source, release, targets[i], settings)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", platforms[i], err)
+					failed = true
+
+					continue
+				}
+
+				jobs = append(jobs, job)
+			}
+
+			if failed {
+				exiterrorf.ExitErrorf(errors.Errorf("not every platform has an asset in %s", release.TagName))
+			}
+
+			if err := runFetchJobs(ownerRepo, jobs); err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			manifest, err := writeManifest(jobs)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+				BorderColumn(true).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("PLATFORM", "ASSET", "FILE", "SHA-256")
+
+			for i := range jobs {
+				t.Row(jobs[i].platform.String(), jobs[i].asset, jobs[i].path, jobs[i].digest)
+			}
+
+			fmt.Println(t.Render())
+			fmt.Printf("Downloaded %s for %d platforms; digests are in %s\n",
+				textUnderline.Render(release.TagName),
+				len(jobs),
+				textUnderline.Render(manifest),
+			)
+		},
+	}
+)
+
+// fetchJob downloads the asset for one platform.
+type fetchJob struct {
+	platform platform.Platform
+	source   provider.Provider
+	release  *provider.Release
+
+	// pattern, archivePath and path are resolved for the platform. path is
+	// where the binary is written.
+	pattern     string
+	archivePath string
+	path        string
+
+	// asset and digest are filled in once the binary is downloaded.
+	asset  string
+	digest string
+}
+
+// parsePlatforms reads the platforms to download for, which may be given as
+// several flags or as a comma-separated list. Duplicates are dropped.
+func parsePlatforms(values []string) ([]platform.Platform, error) {
+	platforms := []platform.Platform{}
+	seen := map[platform.Platform]bool{}
+
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+
+			p, err := platform.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+
+			if !seen[p] {
+				seen[p] = true
+				platforms = append(platforms, p)
+			}
+		}
+	}
+
+	if len(platforms) == 0 {
+		return nil, errors.New("missing the platforms to download for (--platforms)")
+	}
+
+	return platforms, nil
+}
+
+// newFetchJob resolves the templates for a platform, and checks that the
+// release has an asset for it. The OCI and HashiCorp providers pick the build
+// for a platform by themselves, so they get a copy for the platform.
+func newFetchJob(
+	source provider.Provider, release *provider.Release, t targetPlatform, s provider.Settings,
+) (*fetchJob, error) {
+	s.Libc = t.libc
+
+	if pp, ok := source.(provider.PlatformProvider); ok {
+		source = pp.ForPlatform(t.OS, t.Arch, t.variant(), s)
+	}
+
+	assetPatterns := resolveAssetPatterns()
+
+	choice, err := choosePattern(assetPatterns, release, t, s)
+	if err != nil {
+		return nil, err
+	}
+
+	patternVars, pattern := choice.Vars, choice.Pattern
+
+	// Pin the asset, so that the provider can't choose a different one when
+	// several match.
+	if len(assetPatterns) > 0 && release.Assets != nil {
+		asset, err := s.MatchAsset(release.Assets, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		pattern = "^" + regexp.QuoteMeta(asset.Name) + "$"
+	}
+
+	archivePath, err := replacePatternVariables(fArchivePath, patternVars)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := replacePatternVariables(fWriteToBin, patternVars)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.OS == "windows" && filepath.Ext(name) == "" {
+		name += ".exe"
+	}
+
+	return &fetchJob{
+		platform:    t.Platform,
+		source:      source,
+		release:     release,
+		pattern:     pattern,
+		archivePath: archivePath,
+		path:        filepath.Join(fOut, strings.ReplaceAll(t.String(), "/", "_"), name),
+	}, nil
+}
+
+// runFetchJobs downloads every platform's asset concurrently. It returns an
+// error which lists every platform that failed.
+func runFetchJobs(ownerRepo []string, jobs []*fetchJob) error {
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(jobs))
+
+	for i := range jobs {
+		wg.Go(func() {
+			errs[i] = jobs[i].run(ownerRepo)
+		})
+	}
+
+	wg.Wait()
+
+	messages := []string{}
+
+	for i := range errs {
+		if errs[i] != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", jobs[i].platform, errs[i]))
+		}
+	}
+
+	if len(messages) > 0 {
+		return errors.Errorf("failed to download every platform:\n%s", strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
+
+func (j *fetchJob) run(ownerRepo []string) error {
+	archiveStream, name, err := j.source.GetAssetStream(ownerRepo, j.release, j.pattern)
+	if err != nil {
+		return err
+	}
+	defer archiveStream.Close()
+
+	j.asset = name
+
+	err = os.MkdirAll(filepath.Dir(j.path), 0o755) // lint:allow_raw_number
+	if err != nil {
+		return errors.Wrap(err, "failed to create the output directory")
+	}
+
+	_, err = github.DecompressTo(archiveStream, name, j.archivePath, j.path)
+	if err != nil {
+		return err
+	}
+
+	j.digest, err = fileDigest(j.path)
+
+	return err
+}
+
+// fileDigest returns the SHA-256 digest of a file, as hex.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path) // lint:allow_include_file
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open the binary")
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the binary")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeManifest writes the digests of the binaries to --out, in the format of
+// `sha256sum`, and returns its path.
+func writeManifest(jobs []*fetchJob) (string, error) {
+	entries := make(map[string]string, len(jobs))
+	paths := make([]string, 0, len(jobs))
+
+	for i := range jobs {
+		rel, err := filepath.Rel(fOut, jobs[i].path)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to find the binary in the output directory")
+		}
+
+		rel = filepath.ToSlash(rel)
+		entries[rel] = jobs[i].digest
+		paths = append(paths, rel)
+	}
+
+	sort.Strings(paths)
+
+	var b strings.Builder
+
+	for _, rel := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", entries[rel], rel)
+	}
+
+	path := filepath.Join(fOut, manifestName)
+
+	err := os.WriteFile(path, []byte(b.String()), 0o644) // lint:allow_raw_number
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write the manifest")
+	}
+
+	return path, nil
+}
+
+func init() {
+	rootCmd.AddCommand(fetchAllCmd)
+
+	fetchAllCmd.Flags().StringVarP(
+		&fOwnerRepo,
+		"owner-repo",
+		"r",
+		"",
+		"The owner and repository name in the format of 'owner/repo'.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fEndpoint,
+		"endpoint",
+		"e",
+		defaultEndpoint,
+		"The API domain to use.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fProvider,
+		"provider",
+		"",
+		"",
+		"Where releases are published: github, gitlab, gitea, url, oci or hashicorp. Inferred from --endpoint, --url, --image or --owner-repo if unset.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fURL,
+		"url",
+		"u",
+		"",
+		"A URL template to download the asset from directly, instead of using a releases API.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fImage,
+		"image",
+		"i",
+		"",
+		"An OCI image or artifact reference template to download the asset from, instead of using a releases API.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fVersionIndexURL,
+		"version-index-url",
+		"",
+		"",
+		"A page which lists the available versions, for use with --url.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fVersionRegex,
+		"version-regex",
+		"",
+		"",
+		"A regular expression which finds the versions on --version-index-url. Use a group named 'version' to capture part of the match.",
+	)
+	fetchAllCmd.Flags().StringSliceVarP(
+		&fPlatforms,
+		"platforms",
+		"",
+		nil,
+		"The platforms to download for, as a comma-separated list of os/arch or os/arch/variant (e.g., linux/amd64,darwin/arm64).",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fOut,
+		"out",
+		"o",
+		"dist",
+		"The directory to write the binaries and the manifest to.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fTag,
+		"tag",
+		"t",
+		"latest",
+		"The Git tag for which to check releases.",
+	)
+	fetchAllCmd.Flags().StringArrayVarP(
+		&fPatterns,
+		"pattern",
+		"p",
+		nil,
+		"The naming pattern of the asset name to match. Can be repeated, to try several patterns in order.",
+	)
+	fetchAllCmd.Flags().StringArrayVarP(
+		&fArchFallbacks,
+		"arch-fallback",
+		"",
+		nil,
+		"The .Arch values to try when no asset matches a platform, as os/arch=value,value or os=value,value (e.g., darwin=universal). Can be repeated.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fArchivePath,
+		"archive-path",
+		"a",
+		"",
+		"The path to the file inside the archive.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fWriteToBin,
+		"write-to-bin",
+		"w",
+		"",
+		"The final name of the binary, inside each platform's directory.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fConstraint,
+		"constraint",
+		"c",
+		"",
+		"Constrain the version to a particular range.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fChannel,
+		"channel",
+		"",
+		"",
+		"Which releases to choose from: stable, prerelease or any (which includes drafts). Defaults to stable.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fTagPrefix,
+		"tag-prefix",
+		"",
+		"",
+		"Only consider tags with this prefix, which comes before the version (e.g., 'cli/' for 'cli/v2.0.0').",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fTagRegex,
+		"tag-regex",
+		"",
+		"",
+		"Only consider tags matching this regular expression. Use a group named 'version' to capture the version.",
+	)
+	fetchAllCmd.Flags().StringVarP(
+		&fVersionScheme,
+		"version-scheme",
+		"",
+		"",
+		"How to compare versions: semver, calver, lexical, commit-date or regex. Defaults to semver.",
+	)
+	fetchAllCmd.Flags().BoolVarP(
+		&fNoWait,
+		"no-wait",
+		"",
+		false,
+		"Fail immediately when the GitHub API rate limit is hit, instead of waiting for it to reset.",
+	)
+
+	handleAuthFlags(fetchAllCmd)
+
+	handleFlags(fetchAllCmd)
+}
@@ -0,0 +1,306 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// platformProvider records the platform which it was copied for.
+type platformProvider struct {
+	provider.Provider
+
+	platform string
+	settings provider.Settings
+}
+
+func (p *platformProvider) ForPlatform(os, arch, variant string, s provider.Settings) provider.Provider {
+	return &platformProvider{platform: platform.Platform{OS: os, Arch: arch, Variant: variant}.String(), settings: s}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    []string
+		Expected []string
+		Error    bool
+	}{
+		"flags": {
+			Input:    []string{"linux/amd64", "darwin/arm64"},
+			Expected: []string{"linux/amd64", "darwin/arm64"},
+		},
+		"comma-separated": {
+			Input:    []string{"linux/amd64, linux/arm/v7,windows/amd64"},
+			Expected: []string{"linux/amd64", "linux/arm/v7", "windows/amd64"},
+		},
+		"duplicates": {
+			Input:    []string{"linux/amd64,LINUX/AMD64", "linux/amd64"},
+			Expected: []string{"linux/amd64"},
+		},
+		"blank-entries": {
+			Input:    []string{",linux/amd64,,"},
+			Expected: []string{"linux/amd64"},
+		},
+		"empty": {
+			Input: []string{},
+			Error: true,
+		},
+		"only-commas": {
+			Input: []string{",,"},
+			Error: true,
+		},
+		"invalid": {
+			Input: []string{"linux/amd64,linux"},
+			Error: true,
+		},
+		"invalid-variant": {
+			Input: []string{"linux/arm/7"},
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			platforms, err := parsePlatforms(tc.Input)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %v", platforms)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, len(platforms))
+			for i := range platforms {
+				got[i] = platforms[i].String()
+			}
+
+			if !reflect.DeepEqual(got, tc.Expected) {
+				t.Errorf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestNewFetchJob(t *testing.T) {
+	out := t.TempDir()
+
+	restoreFlags(t)
+
+	fOut = out
+	fWriteToBin = "tool"
+	fArchivePath = "tool_{{.OS}}_{{.Arch}}/tool"
+	fPatterns = []string{"tool_{{.OS}}_{{.Arch}}.(tar.gz|zip)"}
+
+	assets := []*provider.Asset{
+		{Name: "tool_linux_amd64.tar.gz"},
+		{Name: "tool_linux_riscv64.tar.gz"},
+		{Name: "tool_darwin_universal.tar.gz"},
+		{Name: "tool_windows_amd64.zip"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Platform    platform.Platform
+		Assets      []*provider.Asset
+		Source      provider.Provider
+		Pattern     string
+		ArchivePath string
+		Path        string
+		ForPlatform string
+		Libc        string
+		Error       bool
+	}{
+		"linux": {
+			Platform:    platform.Platform{OS: "linux", Arch: "riscv64"},
+			Assets:      assets,
+			Pattern:     `^tool_linux_riscv64\.tar\.gz$`,
+			ArchivePath: "tool_linux_riscv64/tool",
+			Path:        filepath.Join(out, "linux_riscv64", "tool"),
+		},
+		"windows-exe": {
+			Platform:    platform.Platform{OS: "windows", Arch: "amd64"},
+			Assets:      assets,
+			Pattern:     `^tool_windows_amd64\.zip$`,
+			ArchivePath: "tool_windows_amd64/tool",
+			Path:        filepath.Join(out, "windows_amd64", "tool.exe"),
+		},
+		"arch-fallback": {
+			Platform:    platform.Platform{OS: "darwin", Arch: "arm64"},
+			Assets:      assets,
+			Pattern:     `^tool_darwin_universal\.tar\.gz$`,
+			ArchivePath: "tool_darwin_universal/tool",
+			Path:        filepath.Join(out, "darwin_arm64", "tool"),
+		},
+		"variant": {
+			Platform: platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"},
+			Assets:   []*provider.Asset{{Name: "tool_linux_arm.tar.gz"}},
+			Pattern:  `^tool_linux_arm\.tar\.gz$`,
+			Path:     filepath.Join(out, "linux_arm_v7", "tool"),
+		},
+		"missing-asset": {
+			Platform: platform.Platform{OS: "linux", Arch: "s390x"},
+			Assets:   assets,
+			Error:    true,
+		},
+		"platform-provider": {
+			Platform:    platform.Platform{OS: "linux", Arch: "riscv64"},
+			Source:      &platformProvider{},
+			Pattern:     "tool_linux_riscv64.(tar.gz|zip)",
+			Path:        filepath.Join(out, "linux_riscv64", "tool"),
+			ForPlatform: "linux/riscv64",
+			Libc:        platform.LibcGNU,
+		},
+		"platform-provider-arm-version": {
+			Platform:    platform.Platform{OS: "linux", Arch: "arm"},
+			Source:      &platformProvider{},
+			Pattern:     "tool_linux_arm.(tar.gz|zip)",
+			Path:        filepath.Join(out, "linux_arm", "tool"),
+			ForPlatform: "linux/arm/v7",
+			Libc:        platform.LibcGNU,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, err := newTargetPlatform(tc.Platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rel := &provider.Release{TagName: "v1.0.0", Assets: tc.Assets}
+
+			job, err := newFetchJob(tc.Source, rel, target, provider.Settings{})
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %+v", job)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if job.pattern != tc.Pattern {
+				t.Errorf("got pattern %q; want %q", job.pattern, tc.Pattern)
+			}
+
+			if tc.ArchivePath != "" && job.archivePath != tc.ArchivePath {
+				t.Errorf("got archive path %q; want %q", job.archivePath, tc.ArchivePath)
+			}
+
+			if job.path != tc.Path {
+				t.Errorf("got path %q; want %q", job.path, tc.Path)
+			}
+
+			if job.release != rel {
+				t.Error("expected the job to use the release")
+			}
+
+			if tc.ForPlatform != "" {
+				source, ok := job.source.(*platformProvider)
+				if !ok {
+					t.Fatalf("expected a copy of the provider; got %T", job.source)
+				}
+
+				if source.platform != tc.ForPlatform || source.settings.Libc != tc.Libc {
+					t.Errorf("got provider for %s (%q); want %s (%q)",
+						source.platform, source.settings.Libc, tc.ForPlatform, tc.Libc)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	restoreFlags(t)
+
+	fOut = t.TempDir()
+
+	jobs := []*fetchJob{
+		{path: filepath.Join(fOut, "windows_amd64", "tool.exe"), digest: "ccc"},
+		{path: filepath.Join(fOut, "darwin_arm64", "tool"), digest: "aaa"},
+		{path: filepath.Join(fOut, "linux_arm_v7", "tool"), digest: "bbb"},
+	}
+
+	path, err := writeManifest(jobs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != filepath.Join(fOut, manifestName) {
+		t.Errorf("got path %q; want %q", path, filepath.Join(fOut, manifestName))
+	}
+
+	got, err := os.ReadFile(path) // lint:allow_include_file
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "aaa  darwin_arm64/tool\nbbb  linux_arm_v7/tool\nccc  windows_amd64/tool.exe\n"
+
+	if string(got) != expected {
+		t.Errorf("got %q; want %q", got, expected)
+	}
+}
+
+func TestFileDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+
+	err := os.WriteFile(path, []byte("hello\n"), 0o600) // lint:allow_raw_number
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fileDigest(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// sha256sum of "hello\n".
+	expected := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+	if got != expected {
+		t.Errorf("got %q; want %q", got, expected)
+	}
+
+	_, err = fileDigest(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+// restoreFlags puts back the flags which a test changes.
+func restoreFlags(t *testing.T) {
+	t.Helper()
+
+	out, writeToBin, archivePath, patterns := fOut, fWriteToBin, fArchivePath, fPatterns
+	fallbacks, name := fArchFallbacks, providerName
+
+	t.Cleanup(func() {
+		fOut, fWriteToBin, fArchivePath, fPatterns = out, writeToBin, archivePath, patterns
+		fArchFallbacks, providerName = fallbacks, name
+	})
+}
@@ -15,16 +15,19 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
-	"html/template"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
-	gh "github.com/google/go-github/v60/github"
+	"github.com/hashicorp/go-version"
 	"github.com/northwood-labs/download-asset/github"
+	"github.com/northwood-labs/download-asset/golang"
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
 	"github.com/northwood-labs/golang-utils/exiterrorf"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -40,38 +43,35 @@
 	fVerbose     bool
 	fWriteToBin  string
 	fConstraint  string
-
-	fDarwin    string
-	fDragonfly string
-	fFreeBSD   string
-	fIllumos   string
-	fLinux     string
-	fNetBSD    string
-	fOpenBSD   string
-	fPlan9     string
-	fSolaris   string
-	fWindows   string
-
-	fArm32    string
-	fArm64    string
-	fIntel32  string
-	fIntel64  string
-	fLoong64  string
-	fMIPS32   string
-	fMIPS64   string
-	fMIPS64LE string
-	fMIPS32LE string
-	fPPC64    string
-	fPPC64LE  string
-	fRiscV64  string
-	fS390x    string
-
-	apiToken    = os.Getenv("GITHUB_TOKEN")
-	apiEndpoint = ""
-	release     *gh.RepositoryRelease
-
-	currentOS  string
-	currentCPU string
+	fNoWait      bool
+	fProvider    string
+	fChannel     string
+	fTagPrefix   string
+	fTagRegex    string
+
+	fURL             string
+	fImage           string
+	fVersionIndexURL string
+	fVersionRegex    string
+	fVersionScheme   string
+
+	fAppID          int64
+	fInstallationID int64
+	fPrivateKeyFile string
+
+	fTargetOS   string
+	fTargetArch string
+	fPlatform   string
+
+	apiToken       string
+	apiTokenSource string
+	apiApp         *github.AppAuth
+	apiEndpoint    = ""
+	providerName   string
+	release        *provider.Release
+
+	// target is the platform to download for.
+	target = targetPlatform{Platform: platform.Current()}
 
 	textUnderline = lipgloss.NewStyle().
 			Underline(true)
@@ -89,13 +89,41 @@
 
 		--------------------------------------------------------------------------------
 
-		Supported variables are {{.Ver}}, {{.OS}}, {{.Arch}}, and {{.Ext}}. These can
-		be used with:
+		Supported variables are {{.Ver}}, {{.OS}}, {{.Arch}}, and {{.Ext}}, along with
+		{{.Tag}} (the tag as-is, e.g., v1.2.3), {{.Major}}, {{.Minor}}, {{.Patch}}, and
+		{{.Libc}} (gnu or musl on Linux), {{.Triple}} (e.g., x86_64-unknown-linux-gnu),
+		{{.ArmVersion}} (e.g., 7 for ARMv7), {{.Amd64Level}} (e.g., v3 for x86-64
+		with AVX2), and {{.GOOS}} and {{.GOARCH}} (Go's names, whatever .OS and .Arch
+		are mapped to). These can be used with:
 		    --pattern, --archive-path, --write-to-bin.
 
+		Templates can also use the functions lower, upper, title, replace, trimPrefix,
+		regexQuote, default, and platform (e.g., {{.OS | title}}, or
+		{{platform "darwin" "macOS" "windows/386" "win32" | default .OS}}).
+
+		Assets are chosen for the current platform by default. To download for another
+		one (e.g., when building a multi-arch container image), set --platform (e.g.,
+		linux/arm64 or linux/arm/v7), or --os and --arch. Docker's TARGETPLATFORM,
+		TARGETOS, TARGETARCH and TARGETVARIANT build arguments are used when they are
+		set. The OS and CPU flags below still apply to the chosen platform.
+
 		Set --archive-path to the path of the binary inside of a compressed archive.
 		Leave blank if the release asset is a binary itself.
 
+		By default, "latest" and --constraint only choose stable releases. Use
+		--channel prerelease to also choose pre-releases (e.g., 1.2.0-rc1), or
+		--channel any to also choose draft releases, if the token can see them.
+
+		In a monorepo, set --tag-prefix (e.g., cli/ for cli/v2.0.0) or --tag-regex (e.g.,
+		^service/s3/v(?P<version>.+)$) to only consider one component's tags. --tag
+		can then be just the version, and {{.Ver}} is the version without the prefix.
+
+		Tags are compared as semantic versions by default. Set --version-scheme to
+		calver (e.g., 2024.10.17), lexical, commit-date (for tags like nightly) or regex
+		(which compares every group captured by --tag-regex, e.g., ^r(\d+)$) for
+		projects which do not use semver. --constraint is written in the same scheme
+		(e.g., ">= 2024.10" or "< 2024-10-17").
+
 		Set --write-to-bin to the name of the final binary. Will attempt to save to
 		/usr/local/bin/NAME, but will fall back to $HOME/bin/NAME if /usr/local/bin is
 		not writable.
@@ -103,19 +131,43 @@
 		See https://bit.ly/3P1O9Rt for more information about setting GitHub API endpoints
 		for GitHub Enterprise Server.
 
-		--------------------------------------------------------------------------------
+		Releases published on GitLab can be downloaded with --provider gitlab. This is
+		inferred when the --endpoint contains "gitlab" (e.g., gitlab.company.com).
+		Likewise, Gitea and Forgejo use --provider gitea, which is inferred when the
+		--endpoint contains "gitea", "forgejo" or "codeberg".
+
+		Tools published on a plain download site can be downloaded with --url, which is a
+		URL template using the same variables as --pattern (e.g.,
+		https://example.com/{{.Ver}}/tool_{{.OS}}_{{.Arch}}.zip). To resolve "latest" or a
+		--constraint, also set --version-index-url to a page which lists the versions,
+		and --version-regex to find them on it.
+
+		Tools published to an OCI registry can be downloaded with --image, which is an
+		image or artifact reference template (e.g., ghcr.io/org/tool:{{.Ver}}). The
+		manifest for the current platform is picked from an image index. An artifact
+		with a single file is treated like a release asset; otherwise, the layers are
+		merged into a tarball and --archive-path is extracted from it.
+
+		HashiCorp products (e.g., hashicorp/terraform) can be downloaded from
+		releases.hashicorp.com with --provider hashicorp. Point --endpoint at a mirror
+		to use it instead. --pattern is optional, since the build for the current
+		platform is found automatically, and downloads are checked against SHA256SUMS.
+
+		Go itself (golang/go) is downloaded from the go.dev download index, and checked
+		against its published SHA-256. Unstable releases are skipped unless a
+		--constraint asks for them. Instead of --write-to-bin, the whole toolchain is
+		unpacked into /usr/local/sdk/go<version> (or $HOME/sdk/go<version>), and go and
+		gofmt are linked into /usr/local/bin (or $HOME/bin).
 
-		Less common operating system flags not listed below are:
-		    --dragonfly, --freebsd, --illumos, --netbsd, --openbsd, --plan9, --solaris
+		--------------------------------------------------------------------------------
 
-		Less common CPU architecture flags not listed below are:
+		Set the value of .OS, .Arch or .Libc for any GOOS, GOARCH or C library with
+		--map (e.g., --map os.darwin=macOS --map arch.amd64=x86_64). The flags below,
+		such as --darwin and --intel64, are shorthands for it. Less common ones are:
+		    --dragonfly, --freebsd, --illumos, --netbsd, --openbsd, --plan9, --solaris,
 		    --loong64, --mips32, --mips32le, --mips64, --mips64le, --ppc64, --ppc64le,
-		    --riscv64`),
+		    --riscv64, --s390x`),
 		Run: func(cmd *cobra.Command, args []string) {
-			if apiToken == "" {
-				exiterrorf.ExitErrorf(errors.New("GitHub token not found; set GITHUB_TOKEN environment variable"))
-			}
-
 			err := readConfig()
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
@@ -130,151 +182,139 @@
 				}).
 				Headers("FIELD", "VALUE")
 
-			apiEndpoint, _, _ = github.ParseDomain(fEndpoint)
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			}
 
-			if fVerbose {
-				t.Row("GitHub endpoint", apiEndpoint)
-				t.Row("GitHub token", apiToken[0:8]+".................................")
+			// Apply values from configuration file.
+			err = applyConfigValues(cmd, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
 			}
 
-			client, err := github.NewClient(&github.NewClientInput{
-				Token:    apiToken,
-				Endpoint: fEndpoint,
-			})
+			// The target platform is needed to create some providers.
+			err = handleCurrentOSArch()
 			if err != nil {
-				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create GitHub client"))
+				exiterrorf.ExitErrorf(err)
 			}
 
-			ownerRepo := strings.Split(fOwnerRepo, "/")
-			if len(ownerRepo) != 2 { // lint:allow_raw_number
-				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			source, err := newProvider(cmd)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create API client"))
 			}
 
 			if fVerbose {
+				t.Row("Provider", providerName)
+				t.Row("Channel", settings.Channel.String())
+				t.Row("Version scheme", settings.Scheme.String())
+				t.Row("API endpoint", apiEndpoint)
+				t.Row("API token", tokenDescription())
 				t.Row("Owner", ownerRepo[0])
 				t.Row("Repository", ownerRepo[1])
-				if viper.ConfigFileUsed() != "" {
-					t.Row("Config file", viper.ConfigFileUsed())
-				}
-			}
 
-			// Apply values from configuration file.
-			applyConfigValues(ownerRepo)
-
-			// If we have a constraint, we need to find the latest tag that satisfies it.
-			if fConstraint != "" {
-				ref, err = github.GetLatestTag(client, ownerRepo[0], ownerRepo[1], fConstraint)
-				if err != nil {
-					exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
+				// Later files win over earlier ones.
+				for i := range configFiles {
+					t.Row("Config file", configFiles[i].path)
 				}
-
-				fTag = github.RemoveVFromTag(ref.String())
 			}
 
-			if fTag == "latest" {
-				release, err = github.GetLatestRelease(client, ownerRepo[0], ownerRepo[1])
-				if err != nil {
-					exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
-				}
-
-				if fVerbose {
-					t.Row("Latest release", *release.TagName)
-				}
-			} else {
-				toTry := []string{
-					fTag,
-					github.InvertTag(fTag),
-				}
-
-				for i := range toTry {
-					tag := toTry[i]
-
-					release, err = github.GetReleaseVersion(client, ownerRepo[0], ownerRepo[1], tag)
-					if err != nil {
-						continue // skip to the next loop
-					} else {
-						break // we found a match; break out of loop
-					}
-				}
+			release, err = discoverRelease(source, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
+			}
 
-				if err != nil {
-					exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
-				}
+			if fVerbose && fTag == "latest" && fConstraint == "" {
+				t.Row("Latest release", release.TagName)
 			}
 
-			err = handleCurrentOSArch()
+			assetPatterns := resolveAssetPatterns()
+
+			choice, err := choosePattern(assetPatterns, release, target, settings)
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
 			}
 
-			patternVars := PatternMatches{
-				Ver:  github.RemoveVFromTag(*release.TagName),
-				OS:   currentOS,
-				Arch: currentCPU,
-				Ext: fmt.Sprintf("(%s)", strings.Join(
-					[]string{
-						// "7z",
-						// "bz2",
-						"exe",
-						"gz",
-						"tar.bz2",
-						"tar.gz",
-						// "tar.lz",
-						"tar.xz",
-						// "tar.Z",
-						// "tar",
-						"tbz2",
-						"tgz",
-						// "tlz",
-						"txz",
-						// "xz",
-						"zip",
-					}, "|",
-				)),
-			}
+			patternVars := choice.Vars
 
 			resolvedArchivePath, err := replacePatternVariables(fArchivePath, patternVars)
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
 			}
 
-			resolvedAssetPattern, err := replacePatternVariables(fPattern, patternVars)
+			resolvedWriteToBin, err := replacePatternVariables(fWriteToBin, patternVars)
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
 			}
 
 			if fVerbose {
-				t.Row("Current OS ident", currentOS)
-				t.Row("Current CPU ident", currentCPU)
-				t.Row("Asset pattern", fPattern)
-				t.Row("Resolved pattern", resolvedAssetPattern)
+				t.Row("Target platform", target.String())
+				t.Row("Current OS ident", target.osIdent)
+				t.Row("Current CPU ident", target.cpuIdent)
+				t.Row("Current libc ident", target.libcIdent)
+
+				if target.armVersion != "" {
+					t.Row("Current ARM version", target.armVersion)
+				}
+
+				if target.amd64Level != "" {
+					t.Row("Current x86-64 level", target.amd64Level)
+				}
+
+				if len(assetPatterns) == 1 {
+					t.Row("Asset pattern", assetPatterns[0])
+				}
+
+				if len(assetPatterns) > 1 {
+					for i := range assetPatterns {
+						t.Row(fmt.Sprintf("Asset pattern #%d", i+1), assetPatterns[i])
+					}
+
+					t.Row("Winning pattern", fmt.Sprintf("#%d", choice.Index+1))
+				}
+
+				t.Row("Resolved pattern", choice.Pattern)
 			}
 
-			// Check that we have everything before we trigger downloads
-			if fPattern == "" || fWriteToBin == "" {
+			// Check that we have everything before we trigger downloads. HashiCorp
+			// and Go releases find the build for the current platform by
+			// themselves, and Go is installed as a whole tree instead of a binary.
+			findsPlatform := providerName == provider.HashiCorp || providerName == provider.Golang
+			if (len(assetPatterns) == 0 && !findsPlatform) || (fWriteToBin == "" && providerName != provider.Golang) {
 				exiterrorf.ExitErrorf(errors.New("missing one of pattern or write-to-bin"))
 			}
 
 			// Ready to download the asset
-			archiveStream, name, err := github.GetAssetStream(
-				client,
+			archiveStream, name, err := source.GetAssetStream(
 				ownerRepo,
 				release,
-				resolvedAssetPattern,
+				choice.Pattern,
 			)
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
 			}
 
+			if providerName == provider.Golang {
+				if fVerbose {
+					t.Row("Matched asset name", name)
+
+					fmt.Println(t.Render())
+				}
+
+				installGo(archiveStream, name, release.TagName)
+
+				return
+			}
+
 			if fVerbose {
 				t.Row("Matched asset name", name)
 				t.Row("File inside archive", resolvedArchivePath)
-				t.Row("Binary added to PATH", fWriteToBin)
+				t.Row("Binary added to PATH", resolvedWriteToBin)
 
 				fmt.Println(t.Render())
 			}
 
-			binPath, err := github.DownloadStream(archiveStream, name, resolvedArchivePath, fWriteToBin)
+			binPath, err := github.DownloadStream(archiveStream, name, resolvedArchivePath, resolvedWriteToBin)
 			if err != nil {
 				exiterrorf.ExitErrorf(err)
 			}
@@ -302,11 +342,118 @@
 	}
 )
 
+// installGo unpacks a Go release into its own directory, and links `go` and
+// `gofmt` into the bin directory.
+func installGo(archiveStream io.ReadCloser, name, ver string) {
+	out, err := golang.Install(&golang.InstallInput{
+		Archive:  archiveStream,
+		Filename: name,
+		Version:  ver,
+	})
+	if err != nil {
+		exiterrorf.ExitErrorf(err)
+	}
+
+	err = archiveStream.Close()
+	if err != nil {
+		exiterrorf.ExitErrorf(err)
+	}
+
+	fmt.Printf(
+		"Downloaded %s; installed Go → %s\n",
+		textUnderline.Render(name),
+		textUnderline.Render(out.Root),
+	)
+
+	for _, link := range out.Links {
+		fmt.Printf("Linked %s\n", textUnderline.Render(link))
+	}
+}
+
 type PatternMatches struct {
 	Ver  string
 	OS   string
 	Arch string
 	Ext  string
+
+	// Tag is the tag as-is, including any prefix or `v`.
+	Tag string
+
+	// Major, Minor and Patch are the first three parts of the version, or empty
+	// if it is not a version.
+	Major string
+	Minor string
+	Patch string
+
+	// Libc is the C library of the current system (e.g., `gnu` or `musl`), or
+	// empty if it is not Linux.
+	Libc string
+
+	// Triple is the Rust/LLVM-style target triple of the current platform
+	// (e.g., `x86_64-unknown-linux-gnu` or `aarch64-apple-darwin`).
+	Triple string
+
+	// ArmVersion is the ARM architecture version (e.g., `7` for ARMv7), and
+	// Amd64Level is the x86-64 microarchitecture level (e.g., `v3`). Each is
+	// empty on other architectures.
+	ArmVersion string
+	Amd64Level string
+
+	// GOOS and GOARCH are Go's names for the platform (e.g., `darwin` and
+	// `arm64`), whatever .OS and .Arch are mapped to.
+	GOOS   string
+	GOARCH string
+}
+
+// newPatternMatches returns the values of the pattern variables for a release
+// on a platform.
+func newPatternMatches(tagName string, t targetPlatform, s provider.Settings) PatternMatches {
+	ver := s.TagFormat.Version(tagName)
+	major, minor, patch := versionParts(ver)
+
+	vars := t.patternMatches()
+	vars.Ver = ver
+	vars.Tag = tagName
+	vars.Major = major
+	vars.Minor = minor
+	vars.Patch = patch
+	vars.Ext = fmt.Sprintf("(%s)", strings.Join(
+		[]string{
+			// "7z",
+			// "bz2",
+			"exe",
+			"gz",
+			"tar.bz2",
+			"tar.gz",
+			// "tar.lz",
+			"tar.xz",
+			// "tar.Z",
+			"tar",
+			"tbz2",
+			"tgz",
+			// "tlz",
+			"txz",
+			// "xz",
+			"zip",
+		}, "|",
+	))
+
+	return vars
+}
+
+// versionParts splits a version into its major, minor and patch numbers. Missing
+// parts are zero (e.g., `1.22` is `1`, `22`, `0`).
+func versionParts(ver string) (major, minor, patch string) { // lint:allow_named_returns
+	v, err := version.NewVersion(ver)
+	if err != nil {
+		return "", "", ""
+	}
+
+	segments := v.Segments64()
+
+	return strconv.FormatInt(segments[0], 10),
+		strconv.FormatInt(segments[1], 10),
+		strconv.FormatInt(segments[2], 10)
 }
 
 func init() {
@@ -324,8 +471,43 @@ func init() {
 		&fEndpoint,
 		"endpoint",
 		"e",
-		"https://api.github.com",
-		"The GitHub API domain to use.",
+		defaultEndpoint,
+		"The API domain to use.",
+	)
+	getCmd.Flags().StringVarP(
+		&fProvider,
+		"provider",
+		"",
+		"",
+		"Where releases are published: github, gitlab, gitea, url, oci, hashicorp or golang. Inferred from --endpoint, --url, --image or --owner-repo if unset.",
+	)
+	getCmd.Flags().StringVarP(
+		&fURL,
+		"url",
+		"u",
+		"",
+		"A URL template to download the asset from directly, instead of using a releases API.",
+	)
+	getCmd.Flags().StringVarP(
+		&fImage,
+		"image",
+		"i",
+		"",
+		"An OCI image or artifact reference template to download the asset from, instead of using a releases API.",
+	)
+	getCmd.Flags().StringVarP(
+		&fVersionIndexURL,
+		"version-index-url",
+		"",
+		"",
+		"A page which lists the available versions, for use with --url.",
+	)
+	getCmd.Flags().StringVarP(
+		&fVersionRegex,
+		"version-regex",
+		"",
+		"",
+		"A regular expression which finds the versions on --version-index-url. Use a group named 'version' to capture part of the match.",
 	)
 	getCmd.Flags().StringVarP(
 		&fTag,
@@ -334,12 +516,19 @@ func init() {
 		"latest",
 		"The Git tag for which to check releases.",
 	)
-	getCmd.Flags().StringVarP(
-		&fPattern,
+	getCmd.Flags().StringArrayVarP(
+		&fPatterns,
 		"pattern",
 		"p",
+		nil,
+		"The naming pattern of the asset name to match. Can be repeated, to try several patterns in order.",
+	)
+	getCmd.Flags().StringArrayVarP(
+		&fArchFallbacks,
+		"arch-fallback",
 		"",
-		"The naming pattern of the asset name to match.",
+		nil,
+		"The .Arch values to try when no asset matches a platform, as os/arch=value,value or os=value,value (e.g., darwin=universal). Can be repeated.",
 	)
 	getCmd.Flags().BoolVarP(
 		&fVerbose,
@@ -369,90 +558,120 @@ func init() {
 		"",
 		"Constrain the version to a particular range.",
 	)
+	getCmd.Flags().StringVarP(
+		&fChannel,
+		"channel",
+		"",
+		"",
+		"Which releases to choose from: stable, prerelease or any (which includes drafts). Defaults to stable.",
+	)
+	getCmd.Flags().StringVarP(
+		&fTagPrefix,
+		"tag-prefix",
+		"",
+		"",
+		"Only consider tags with this prefix, which comes before the version (e.g., 'cli/' for 'cli/v2.0.0').",
+	)
+	getCmd.Flags().StringVarP(
+		&fTagRegex,
+		"tag-regex",
+		"",
+		"",
+		"Only consider tags matching this regular expression. Use a group named 'version' to capture the version.",
+	)
+	getCmd.Flags().StringVarP(
+		&fVersionScheme,
+		"version-scheme",
+		"",
+		"",
+		"How to compare versions: semver, calver, lexical, commit-date or regex. Defaults to semver.",
+	)
+	getCmd.Flags().BoolVarP(
+		&fNoWait,
+		"no-wait",
+		"",
+		false,
+		"Fail immediately when the GitHub API rate limit is hit, instead of waiting for it to reset.",
+	)
+
+	handleAuthFlags(getCmd)
 
+	handleTargetFlags(getCmd)
 	handleFlags(getCmd)
 }
 
-func replacePatternVariables(pattern string, patternVars PatternMatches) (string, error) {
-	tmpl, err := template.New("test").Parse(pattern)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to parse pattern: %s", pattern)
+// configFlagMap returns the flags which can be set in the config file, by
+// their key.
+func configFlagMap() map[string]*string {
+	return map[string]*string{
+		// Config
+		"endpoint":     &fEndpoint,
+		"provider":     &fProvider,
+		"channel":      &fChannel,
+		"tag-prefix":   &fTagPrefix,
+		"tag-regex":    &fTagRegex,
+		"url":          &fURL,
+		"image":        &fImage,
+		"archive-path": &fArchivePath,
+		"write-to-bin": &fWriteToBin,
+
+		"version-index-url": &fVersionIndexURL,
+		"version-regex":     &fVersionRegex,
+		"version-scheme":    &fVersionScheme,
 	}
+}
 
-	buf := new(bytes.Buffer)
-
-	err = tmpl.Execute(buf, patternVars)
+// applyConfigValues sets the flags from the config which applies to the
+// repository: its own table, then its owner's, then [defaults], with
+// DOWNLOAD_ASSET_* environment variables winning over all of them. A flag which
+// was passed explicitly wins over the config.
+func applyConfigValues(cmd *cobra.Command, ownerRepo []string) error {
+	err := checkRepoScopes(ownerRepo)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to apply values to pattern: %v", patternVars)
+		return err
 	}
 
-	return buf.String(), nil
-}
+	flagMap := configFlagMap()
 
-func readConfig() error {
-	viper.SetConfigName("download-asset")
-	viper.SetConfigType("toml")
+	// A pinned tag and a constraint both choose the release, so passing either
+	// one replaces both from the config.
+	if !cmd.Flags().Changed("tag") && !cmd.Flags().Changed("constraint") {
+		flagMap["tag"] = &fTag
+		flagMap["constraint"] = &fConstraint
+	}
 
-	viper.AddConfigPath(".")                      // Current directory first.
-	viper.AddConfigPath("$HOME/.download-asset/") // Then the HOME directory.
-	viper.AddConfigPath("/etc/download-asset/")   // Then the system directory.
+	if v, _, ok := repoConfigValue(ownerRepo, "pattern"); ok && !cmd.Flags().Changed("pattern") {
+		fPatterns = configStrings(v)
+	}
 
-	errConfigFileNotFoundError := viper.ConfigFileNotFoundError{}
+	// e.g., "darwin/arm64" = ["all", "universal"]. These come before any from
+	// --arch-fallback, so that the flag wins for the same platform, and the
+	// more specific tables come last so that they win.
+	fallbacks := []string{}
+	scopes := repoScopes(ownerRepo)
 
-	if err := viper.ReadInConfig(); err != nil {
-		if ok := errors.As(err, &errConfigFileNotFoundError); ok {
-			// Config file not found; ignore error.
-		} else {
-			return errors.Wrap(err, "failed to read config file")
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for k, v := range viper.GetStringMapStringSlice(scopes[i] + "." + archFallbacksKey) {
+			fallbacks = append(fallbacks, k+"="+strings.Join(v, ","))
 		}
 	}
 
-	return nil
-}
+	// e.g., DOWNLOAD_ASSET_ARCH_FALLBACKS="darwin=all,universal linux/arm=armv6".
+	if v, ok := os.LookupEnv(configEnvName(archFallbacksKey)); ok {
+		fallbacks = append(fallbacks, strings.Fields(v)...)
+	}
 
-func applyConfigValues(ownerRepo []string) {
-	if viper.IsSet(strings.Join(ownerRepo, ".")) {
-		flagMap := map[string]*string{
-			// Config
-			"endpoint":     &fEndpoint,
-			"pattern":      &fPattern,
-			"archive-path": &fArchivePath,
-			"write-to-bin": &fWriteToBin,
-
-			// OS
-			"darwin":    &fDarwin,
-			"dragonfly": &fDragonfly,
-			"freebsd":   &fFreeBSD,
-			"illumos":   &fIllumos,
-			"linux":     &fLinux,
-			"netbsd":    &fNetBSD,
-			"openbsd":   &fOpenBSD,
-			"plan9":     &fPlan9,
-			"solaris":   &fSolaris,
-			"windows":   &fWindows,
-
-			// CPU Architectures
-			"arm32":     &fArm32,
-			"arm64":     &fArm64,
-			"intel32":   &fIntel32,
-			"intel64":   &fIntel64,
-			"loong64":   &fLoong64,
-			"mips32":    &fMIPS32,
-			"mips32-le": &fMIPS32LE,
-			"mips64":    &fMIPS64,
-			"mips64-le": &fMIPS64LE,
-			"ppc64":     &fPPC64,
-			"ppc64le":   &fPPC64LE,
-			"riscv64":   &fRiscV64,
-			"s390x":     &fS390x,
-		}
+	fArchFallbacks = append(fallbacks, fArchFallbacks...)
 
-		for k := range flagMap {
-			v := flagMap[k]
+	applyConfigAliases(ownerRepo)
 
-			if viper.IsSet(strings.Join(ownerRepo, ".") + "." + k) {
-				*v = viper.GetString(strings.Join(ownerRepo, ".") + "." + k)
-			}
+	for k := range flagMap {
+		v := flagMap[k]
+
+		if value, _, ok := repoConfigValue(ownerRepo, k); ok && !cmd.Flags().Changed(k) {
+			*v = fmt.Sprint(value)
 		}
 	}
+
+	return nil
 }
@@ -1,253 +1,204 @@
 package cmd
 
 import (
+	"os"
 	"runtime"
+	"strings"
 
+	"github.com/northwood-labs/download-asset/platform"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
-func handleFlags(cmd *cobra.Command) {
-	// OS-specific options.
-	cmd.Flags().StringVarP(
-		&fDarwin,
-		"darwin",
-		"",
-		"darwin",
-		"When Darwin, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fDragonfly,
-		"dragonfly",
-		"",
-		"dragonfly",
-		"When Dragonfly, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fFreeBSD,
-		"freebsd",
-		"",
-		"freebsd",
-		"When FreeBSD, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fIllumos,
-		"illumos",
-		"",
-		"illumos",
-		"When Illumos, set .OS to this value.",
-	)
+// handleTargetFlags adds the flags which choose another platform to download
+// for.
+func handleTargetFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(
-		&fLinux,
-		"linux",
+		&fTargetOS,
+		"os",
 		"",
-		"linux",
-		"When Linux, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fNetBSD,
-		"netbsd",
 		"",
-		"netbsd",
-		"When NetBSD, set .OS to this value.",
+		"The OS to download for, using Go's names (e.g., linux). Defaults to the current OS.",
 	)
 	cmd.Flags().StringVarP(
-		&fOpenBSD,
-		"openbsd",
+		&fTargetArch,
+		"arch",
 		"",
-		"openbsd",
-		"When OpenBSD, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fPlan9,
-		"plan9",
 		"",
-		"plan9",
-		"When Plan9, set .OS to this value.",
+		"The CPU architecture to download for, using Go's names (e.g., arm64). Defaults to the current one.",
 	)
 	cmd.Flags().StringVarP(
-		&fSolaris,
-		"solaris",
+		&fPlatform,
+		"platform",
 		"",
-		"solaris",
-		"When Solaris, set .OS to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fWindows,
-		"windows",
 		"",
-		"windows",
-		"When Windows, set .OS to this value.",
+		"The platform to download for, as os/arch or os/arch/variant (e.g., linux/arm/v7). Defaults to $TARGETPLATFORM, then the current platform.",
 	)
+}
 
-	_ = cmd.Flags().MarkHidden("dragonfly") // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("freebsd")   // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("illumos")   // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("netbsd")    // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("openbsd")   // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("plan9")     // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("solaris")   // lint:allow_unhandled
+// handleFlags adds --map, and the legacy flags which each set one alias
+// (e.g., --darwin).
+func handleFlags(cmd *cobra.Command) {
+	for i := range legacyAliases {
+		a := legacyAliases[i]
 
-	// CPU Arch-specific options.
-	cmd.Flags().StringVarP(
-		&fArm32,
-		"arm32",
-		"",
-		"arm",
-		"When 32-bit ARM, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fArm64,
-		"arm64",
-		"",
-		"arm64",
-		"When 64-bit ARM, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fIntel32,
-		"intel32",
-		"",
-		"386",
-		"When 32-bit Intel-compat, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fIntel64,
-		"intel64",
-		"",
-		"amd64",
-		"When 64-bit Intel-compat, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fLoong64,
-		"loong64",
-		"",
-		"loong64",
-		"When 64-bit Loongson, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fMIPS32,
-		"mips32",
-		"",
-		"mips",
-		"When 32-bit MIPS, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fMIPS32LE,
-		"mips32le",
-		"",
-		"mipsle",
-		"When 32-bit MIPS (LE), set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fMIPS64,
-		"mips64",
-		"",
-		"mips64",
-		"When 64-bit MIPS, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fMIPS64LE,
-		"mips64le",
-		"",
-		"mips64le",
-		"When 64-bit MIPS (LE), set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fPPC64,
-		"ppc64",
-		"",
-		"ppc64",
-		"When 64-bit PowerPC, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fPPC64LE,
-		"ppc64le",
-		"",
-		"ppc64le",
-		"When 64-bit PowerPC (LE), set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fRiscV64,
-		"riscv64",
-		"",
-		"riscv64",
-		"When 64-bit RISC-V, set .Arch to this value.",
-	)
-	cmd.Flags().StringVarP(
-		&fS390x,
-		"s390x",
-		"",
-		"s390x",
-		"When 64-bit s390x, set .Arch to this value.",
+		cmd.Flags().Var(aliasFlag{key: a.key}, a.flag, a.usage)
+
+		if a.hidden {
+			_ = cmd.Flags().MarkHidden(a.flag) // lint:allow_unhandled
+		}
+	}
+
+	cmd.Flags().Var(
+		&mapFlag{},
+		"map",
+		"Set the value of .OS, .Arch or .Libc for a platform, as kind.name=value (e.g., os.darwin=macOS or arch.amd64=x86_64). Can be repeated.",
 	)
+}
+
+// targetPlatform is a platform to download for, along with the values of the
+// OS and CPU pattern variables for it.
+type targetPlatform struct {
+	platform.Platform
+
+	// libc is the platform's C library, before it is mapped by --gnu or
+	// --musl.
+	libc string
+
+	// osIdent, cpuIdent and libcIdent are the values of .OS, .Arch and .Libc,
+	// after any aliases.
+	osIdent   string
+	cpuIdent  string
+	libcIdent string
+
+	armVersion string
+	amd64Level string
+}
+
+// resolveTarget works out which platform to download for. Docker's build
+// arguments (TARGETPLATFORM, then TARGETOS, TARGETARCH and TARGETVARIANT) are
+// overridden by --platform, which is overridden by --os and --arch. Anything
+// left unset is the current platform.
+func resolveTarget() (platform.Platform, error) {
+	layers := []platform.Platform{}
+
+	if env := os.Getenv("TARGETPLATFORM"); env != "" {
+		p, err := platform.Parse(env)
+		if err != nil {
+			return platform.Platform{}, errors.Wrap(err, "failed to read TARGETPLATFORM")
+		}
+
+		layers = append(layers, p)
+	}
+
+	layers = append(layers, platform.Platform{
+		OS:      strings.ToLower(os.Getenv("TARGETOS")),
+		Arch:    strings.ToLower(os.Getenv("TARGETARCH")),
+		Variant: strings.ToLower(os.Getenv("TARGETVARIANT")),
+	})
+
+	if fPlatform != "" {
+		p, err := platform.Parse(fPlatform)
+		if err != nil {
+			return platform.Platform{}, err
+		}
+
+		layers = append(layers, p)
+	}
 
-	_ = cmd.Flags().MarkHidden("loong64")  // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("mips32")   // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("mips32le") // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("mips64")   // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("mips64le") // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("ppc64")    // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("ppc64le")  // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("riscv64")  // lint:allow_unhandled
-	_ = cmd.Flags().MarkHidden("s390x")    // lint:allow_unhandled
+	layers = append(layers, platform.Platform{
+		OS:   strings.ToLower(fTargetOS),
+		Arch: strings.ToLower(fTargetArch),
+	})
+
+	t := platform.Current()
+	for i := range layers {
+		t = t.Override(layers[i])
+	}
+
+	return t, nil
 }
 
+// handleCurrentOSArch resolves the platform to download for, and sets the
+// values of the OS and CPU pattern variables for it.
 func handleCurrentOSArch() error {
-	switch runtime.GOOS {
-	case "darwin":
-		currentOS = fDarwin
-	case "dragonfly":
-		currentOS = fDragonfly
-	case "freebsd":
-		currentOS = fFreeBSD
-	case "illumos":
-		currentOS = fIllumos
-	case "linux":
-		currentOS = fLinux
-	case "netbsd":
-		currentOS = fNetBSD
-	case "openbsd":
-		currentOS = fOpenBSD
-	case "plan9":
-		currentOS = fPlan9
-	case "solaris":
-		currentOS = fSolaris
-	case "windows":
-		currentOS = fWindows
-	default:
-		return errors.New("unknown operating system")
+	p, err := resolveTarget()
+	if err != nil {
+		return err
 	}
 
-	switch runtime.GOARCH {
-	case "arm":
-		currentCPU = fArm32
-	case "arm64":
-		currentCPU = fArm64
-	case "386":
-		currentCPU = fIntel32
-	case "amd64":
-		currentCPU = fIntel64
-	case "loong64":
-		currentCPU = fLoong64
-	case "mips":
-		currentCPU = fMIPS32
-	case "mips64":
-		currentCPU = fMIPS64
-	case "mips64le":
-		currentCPU = fMIPS64LE
-	case "mipsle":
-		currentCPU = fMIPS32LE
-	case "ppc64":
-		currentCPU = fPPC64
-	case "ppc64le":
-		currentCPU = fPPC64LE
-	case "riscv64":
-		currentCPU = fRiscV64
-	case "s390x":
-		currentCPU = fS390x
-	default:
-		return errors.New("unknown CPU architecture")
+	target, err = newTargetPlatform(p)
+
+	return err
+}
+
+// newTargetPlatform returns the values of the OS and CPU pattern variables for
+// a platform, using the OS, CPU and libc aliases. The libc can only be detected
+// on the current OS. Elsewhere, Linux is assumed to use glibc.
+func newTargetPlatform(p platform.Platform) (targetPlatform, error) {
+	var err error
+
+	t := targetPlatform{Platform: p}
+
+	switch {
+	case p.OS == runtime.GOOS:
+		t.libc = platform.Libc()
+	case p.OS == "linux":
+		t.libc = platform.LibcGNU
+	}
+
+	t.osIdent, err = lookupAlias(aliasOS, p.OS)
+	if err != nil {
+		return t, err
 	}
 
-	return nil
+	t.cpuIdent, err = lookupAlias(aliasArch, p.Arch)
+	if err != nil {
+		return t, err
+	}
+
+	// The CPU can only be detected when it's the one being downloaded for.
+	t.armVersion = p.ArmVersion()
+	t.amd64Level = p.Amd64Level()
+
+	if p.IsCurrent() && p.Variant == "" {
+		t.armVersion = platform.ArmVersion()
+		t.amd64Level = platform.Amd64Level()
+	}
+
+	if t.libc != "" {
+		t.libcIdent, err = lookupAlias(aliasLibc, t.libc)
+		if err != nil {
+			return t, err
+		}
+	}
+
+	return t, nil
+}
+
+// variant returns the platform's variant. For 32-bit ARM without one, it is the
+// ARM version which was detected (e.g., `v6`), so that providers which choose
+// between images by variant don't pick one the CPU can't run.
+func (t targetPlatform) variant() string {
+	if t.Variant == "" && t.Arch == "arm" && t.armVersion != "" {
+		return "v" + t.armVersion
+	}
+
+	return t.Variant
+}
+
+// patternMatches returns the values of the pattern variables which depend on
+// the platform.
+func (t targetPlatform) patternMatches() PatternMatches {
+	return PatternMatches{
+		OS:     t.osIdent,
+		Arch:   t.cpuIdent,
+		Libc:   t.libcIdent,
+		Triple: platform.Triple(t.OS, t.Arch, t.armVersion, t.libc),
+		GOOS:   t.OS,
+		GOARCH: t.Arch,
+
+		ArmVersion: t.armVersion,
+		Amd64Level: t.amd64Level,
+	}
 }
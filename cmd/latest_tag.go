@@ -20,8 +20,6 @@
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
-	"github.com/hashicorp/go-version"
-	"github.com/northwood-labs/download-asset/github"
 	"github.com/northwood-labs/golang-utils/exiterrorf"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -31,7 +29,6 @@
 	fStrip      bool
 	fSkipToTags bool
 
-	ref *version.Version
 	tag string
 
 	// latestTagCmd represents the latestTag command
@@ -45,13 +42,38 @@
 		If you would prefer the latest tag over the latest release, use the
 		--skip-to-tags flag.
 
+		Only stable releases and tags are chosen, unless --channel is set to
+		prerelease (to also choose pre-releases) or any (to also choose drafts).
+
+		In a monorepo, set --tag-prefix or --tag-regex to only consider one component's
+		tags. The version is printed without the prefix.
+
+		Tags are compared as semantic versions by default. Set --version-scheme to
+		calver, lexical, commit-date or regex (which compares every group captured by
+		--tag-regex) for projects which do not use semver.
+
 		--------------------------------------------------------------------------------
 
 		See https://bit.ly/3P1O9Rt for more information about setting GitHub API endpoints
-		for GitHub Enterprise Server.`),
+		for GitHub Enterprise Server.
+
+		Releases published on GitLab can be checked with --provider gitlab. This is
+		inferred when the --endpoint contains "gitlab" (e.g., gitlab.company.com).
+		Likewise, Gitea and Forgejo use --provider gitea, which is inferred when the
+		--endpoint contains "gitea", "forgejo" or "codeberg".
+
+		Tools published on a plain download site can be checked with --version-index-url
+		(a page which lists the versions) and --version-regex (which finds the versions
+		on it). Nothing is downloaded, so there is no --url to infer the provider from;
+		pass --provider url as well. Tags in an OCI registry can be checked with --image.
+
+		HashiCorp products (e.g., hashicorp/terraform) can be checked against
+		releases.hashicorp.com (or a mirror set with --endpoint) with --provider hashicorp.
+		Go itself (golang/go) is checked against the go.dev download index.`),
 		Run: func(cmd *cobra.Command, args []string) {
-			if apiToken == "" {
-				exiterrorf.ExitErrorf(errors.New("GitHub token not found; set GITHUB_TOKEN environment variable"))
+			err := readConfig()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
 			}
 
 			t := table.New().
@@ -63,48 +85,49 @@
 				}).
 				Headers("FIELD", "VALUE")
 
-			apiEndpoint, _, _ = github.ParseDomain(fEndpoint)
-
-			if fVerbose {
-				t.Row("GitHub endpoint", apiEndpoint)
-				t.Row("GitHub token", apiToken[0:8]+".................................")
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
 			}
 
-			client, err := github.NewClient(&github.NewClientInput{
-				Token:    apiToken,
-				Endpoint: fEndpoint,
-			})
+			// Apply values from configuration file.
+			err = applyConfigValues(cmd, ownerRepo)
 			if err != nil {
-				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create GitHub client"))
+				exiterrorf.ExitErrorf(err)
 			}
 
-			ownerRepo := strings.Split(fOwnerRepo, "/")
-			if len(ownerRepo) != 2 { // lint:allow_raw_number
-				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			source, err := newProvider(cmd)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create API client"))
 			}
 
 			if fVerbose {
+				t.Row("Provider", providerName)
+				t.Row("Channel", settings.Channel.String())
+				t.Row("Version scheme", settings.Scheme.String())
+				t.Row("API endpoint", apiEndpoint)
+				t.Row("API token", tokenDescription())
 				t.Row("Owner", ownerRepo[0])
 				t.Row("Repository", ownerRepo[1])
 			}
 
 			if fSkipToTags || fConstraint != "" {
-				ref, err = github.GetLatestTag(client, ownerRepo[0], ownerRepo[1], fConstraint)
+				tag, err = source.GetLatestTag(ownerRepo[0], ownerRepo[1], fConstraint)
 				if err != nil {
 					exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
 				}
 
-				tag = github.RemoveVFromTag(ref.String())
+				tag = settings.TagFormat.Version(tag)
 			} else {
-				release, err = github.GetLatestRelease(client, ownerRepo[0], ownerRepo[1])
+				release, err = source.GetLatestRelease(ownerRepo[0], ownerRepo[1])
 				if err != nil {
-					ref, err = github.GetLatestTag(client, ownerRepo[0], ownerRepo[1], "")
+					tag, err = source.GetLatestTag(ownerRepo[0], ownerRepo[1], "")
 					if err != nil {
 						exiterrorf.ExitErrorf(errors.Wrap(err, "failed to discover the release"))
 					}
-					tag = github.RemoveVFromTag(ref.String())
+					tag = settings.TagFormat.Version(tag)
 				} else {
-					tag = github.RemoveVFromTag(*release.TagName)
+					tag = settings.TagFormat.Version(release.TagName)
 				}
 			}
 
@@ -138,8 +161,36 @@ func init() {
 		&fEndpoint,
 		"endpoint",
 		"e",
-		"https://api.github.com",
-		"The GitHub API domain to use.",
+		defaultEndpoint,
+		"The API domain to use.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fProvider,
+		"provider",
+		"",
+		"",
+		"Where releases are published: github, gitlab, gitea, url, oci, hashicorp or golang. Inferred from --endpoint, --url, --image or --owner-repo if unset.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fImage,
+		"image",
+		"i",
+		"",
+		"An OCI image or artifact reference whose tags should be checked.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fVersionIndexURL,
+		"version-index-url",
+		"",
+		"",
+		"A page which lists the available versions, for use with --provider url.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fVersionRegex,
+		"version-regex",
+		"",
+		"",
+		"A regular expression which finds the versions on --version-index-url. Use a group named 'version' to capture part of the match.",
 	)
 	latestTagCmd.Flags().BoolVarP(
 		&fVerbose,
@@ -169,4 +220,41 @@ func init() {
 		"",
 		"Constrain the version to a particular range. Implies --skip-to-tags.",
 	)
+	latestTagCmd.Flags().StringVarP(
+		&fChannel,
+		"channel",
+		"",
+		"",
+		"Which releases to choose from: stable, prerelease or any (which includes drafts). Defaults to stable.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fTagPrefix,
+		"tag-prefix",
+		"",
+		"",
+		"Only consider tags with this prefix, which comes before the version (e.g., 'cli/' for 'cli/v2.0.0').",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fTagRegex,
+		"tag-regex",
+		"",
+		"",
+		"Only consider tags matching this regular expression. Use a group named 'version' to capture the version.",
+	)
+	latestTagCmd.Flags().StringVarP(
+		&fVersionScheme,
+		"version-scheme",
+		"",
+		"",
+		"How to compare versions: semver, calver, lexical, commit-date or regex. Defaults to semver.",
+	)
+	latestTagCmd.Flags().BoolVarP(
+		&fNoWait,
+		"no-wait",
+		"",
+		false,
+		"Fail immediately when the GitHub API rate limit is hit, instead of waiting for it to reset.",
+	)
+
+	handleAuthFlags(latestTagCmd)
 }
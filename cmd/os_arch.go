@@ -31,31 +31,43 @@
 	Stripped-down functionality of the 'get' command that only returns the OS and
 	CPU architecture values in a normalized format. This is useful for scripting.
 
+	Set --platform (e.g., linux/arm/v7), or --os and --arch, to show the values for
+	another platform. Docker's TARGETPLATFORM, TARGETOS, TARGETARCH and
+	TARGETVARIANT build arguments are used when they are set.
+
 	--------------------------------------------------------------------------------
 
-	Supported variables are {{.Ver}}, {{.OS}}, {{.Arch}}, and {{.Ext}}. These can
-	be used with:
+	Supported variables are {{.Ver}}, {{.OS}}, {{.Arch}}, {{.Ext}}, {{.Libc}},
+	{{.Triple}}, {{.ArmVersion}}, {{.Amd64Level}}, {{.GOOS}}, and {{.GOARCH}}.
+	These can be used with:
 		--pattern.
 
-	--------------------------------------------------------------------------------
+	Patterns can also use the functions lower, upper, title, replace, trimPrefix,
+	regexQuote, default, and platform (e.g., {{.OS | title}}, or
+	{{platform "darwin" "macOS" | default .OS}}).
 
-	Less common operating system flags not listed below are:
-		--dragonfly, --freebsd, --illumos, --netbsd, --openbsd, --plan9, --solaris
+	--------------------------------------------------------------------------------
 
-	Less common CPU architecture flags not listed below are:
+	Set the value of .OS, .Arch or .Libc for any GOOS, GOARCH or C library with
+	--map (e.g., --map os.darwin=macOS --map arch.amd64=x86_64). The flags below,
+	such as --darwin and --intel64, are shorthands for it. Less common ones are:
+		--dragonfly, --freebsd, --illumos, --netbsd, --openbsd, --plan9, --solaris,
 		--loong64, --mips32, --mips32le, --mips64, --mips64le, --ppc64, --ppc64le,
-		--riscv64`),
+		--riscv64, --s390x`),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := handleCurrentOSArch()
+		// The config file can set global aliases with a `map` table.
+		err := readConfig()
 		if err != nil {
 			exiterrorf.ExitErrorf(err)
 		}
 
-		patternVars := PatternMatches{
-			OS:   currentOS,
-			Arch: currentCPU,
+		err = handleCurrentOSArch()
+		if err != nil {
+			exiterrorf.ExitErrorf(err)
 		}
 
+		patternVars := target.patternMatches()
+
 		resolvedAssetPattern, err := replacePatternVariables(fPattern, patternVars)
 		if err != nil {
 			exiterrorf.ExitErrorf(err)
@@ -71,8 +83,10 @@
 			Headers("FIELD", "VALUE")
 
 		if fVerbose {
-			t.Row("Current OS ident", currentOS)
-			t.Row("Current CPU ident", currentCPU)
+			t.Row("Target platform", target.String())
+			t.Row("Current OS ident", target.osIdent)
+			t.Row("Current CPU ident", target.cpuIdent)
+			t.Row("Current libc ident", target.libcIdent)
 			t.Row("Raw pattern", fPattern)
 			t.Row("Resolved pattern", resolvedAssetPattern)
 
@@ -101,5 +115,6 @@ func init() {
 		"Display verbose output.",
 	)
 
+	handleTargetFlags(osArchCmd)
 	handleFlags(osArchCmd)
 }
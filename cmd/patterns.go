@@ -0,0 +1,198 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+var (
+	fPatterns      []string
+	fArchFallbacks []string
+
+	// defaultArchFallbacks are the .Arch values which are tried when no asset
+	// matches the platform's own, by OS or os/arch. A universal macOS binary
+	// runs on every Mac.
+	defaultArchFallbacks = map[string][]string{
+		"darwin": {"all", "universal"},
+	}
+)
+
+// patternChoice is the pattern which matched an asset, along with the values it
+// was resolved with.
+type patternChoice struct {
+	// Vars are the values of the pattern variables, which the other templates
+	// use too.
+	Vars PatternMatches
+
+	// Pattern is the resolved pattern, and Index is its position in the list.
+	Pattern string
+	Index   int
+}
+
+// resolveAssetPatterns returns the templates which select the asset, in the
+// order they are tried. The URL and OCI providers have no list of assets to
+// match against, so the "pattern" is the URL or image reference itself.
+func resolveAssetPatterns() []string {
+	switch providerName {
+	case provider.URL:
+		return nonEmpty([]string{fURL})
+	case provider.OCI:
+		return nonEmpty([]string{fImage})
+	default:
+		return nonEmpty(fPatterns)
+	}
+}
+
+// choosePattern resolves the patterns for a release on a platform, and returns
+// the first one which matches an asset. Falling back in order:
+//
+//   - The .Arch values from --arch-fallback are tried after the platform's own
+//     (e.g., `all` or `universal` for a Mac).
+//   - Each pattern is tried in the order it was given.
+//   - If a pattern uses {{.ArmVersion}} or {{.Amd64Level}}, the newest version
+//     or level which the CPU can run is tried first, then older ones. On
+//     32-bit ARM, {{.Triple}} follows {{.ArmVersion}}.
+//
+// Releases without a list of assets can't be checked, so the first pattern is
+// used. If nothing matches, the first pattern is returned, so that the error
+// mentions it.
+func choosePattern(
+	patterns []string, release *provider.Release, t targetPlatform, s provider.Settings,
+) (patternChoice, error) {
+	if len(patterns) == 0 {
+		patterns = []string{""}
+	}
+
+	base := newPatternMatches(release.TagName, t, s)
+
+	fallbacks, err := archFallbacks(t.Platform)
+	if err != nil {
+		return patternChoice{Vars: base}, err
+	}
+
+	var (
+		first patternChoice
+		tried = map[string]bool{}
+	)
+
+	for _, arch := range append([]string{base.Arch}, fallbacks...) {
+		for i, pattern := range patterns {
+			for _, armVersion := range platform.ArmVersions(base.ArmVersion) {
+				for _, amd64Level := range platform.Amd64Levels(base.Amd64Level) {
+					choice := patternChoice{Vars: base, Index: i}
+					choice.Vars.Arch = arch
+					choice.Vars.ArmVersion = armVersion
+					choice.Vars.Amd64Level = amd64Level
+					choice.Vars.Triple = platform.Triple(t.OS, t.Arch, armVersion, t.libc)
+
+					choice.Pattern, err = replacePatternVariables(pattern, choice.Vars)
+					if err != nil {
+						return choice, err
+					}
+
+					if tried[choice.Pattern] {
+						continue
+					}
+
+					if len(tried) == 0 {
+						first = choice
+					}
+
+					tried[choice.Pattern] = true
+
+					if release.Assets == nil {
+						return first, nil
+					}
+
+					if _, err := s.MatchAsset(release.Assets, choice.Pattern); err == nil {
+						return choice, nil
+					}
+				}
+			}
+		}
+	}
+
+	return first, nil
+}
+
+// archFallbacks returns the .Arch values to try for a platform when its own
+// doesn't match. --arch-fallback takes `os/arch=value,value` or
+// `os=value,value`, and a more specific entry wins.
+func archFallbacks(p platform.Platform) ([]string, error) {
+	fallbacks := map[string][]string{}
+
+	for k, v := range defaultArchFallbacks {
+		fallbacks[k] = v
+	}
+
+	for _, entry := range fArchFallbacks {
+		key, values, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, errors.Errorf("invalid arch fallback %q; expected os/arch=value,value", entry)
+		}
+
+		fallbacks[strings.ToLower(strings.TrimSpace(key))] = nonEmpty(strings.Split(values, ","))
+	}
+
+	if values, ok := fallbacks[p.OS+"/"+p.Arch]; ok {
+		return values, nil
+	}
+
+	return fallbacks[p.OS], nil
+}
+
+// configStrings reads a config value which is either a string or a list of
+// strings. Any other value is read as a string.
+func configStrings(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+
+		for i := range v {
+			if s, ok := v[i].(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// nonEmpty returns the values which are not blank, trimmed.
+func nonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,369 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+func TestChoosePattern(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Platform      platform.Platform
+		Patterns      []string
+		ArchFallbacks []string
+		Assets        []string
+		NoAssets      bool
+
+		Pattern    string
+		Index      int
+		Arch       string
+		ArmVersion string
+		Amd64Level string
+		Error      bool
+	}{
+		"first-pattern": {
+			Platform: platform.Platform{OS: "linux", Arch: "riscv64"},
+			Patterns: []string{"a_{{.OS}}_{{.Arch}}", "b_{{.OS}}_{{.Arch}}"},
+			Assets:   []string{"b_linux_riscv64", "a_linux_riscv64"},
+			Pattern:  "a_linux_riscv64",
+			Index:    0,
+			Arch:     "riscv64",
+		},
+		"second-pattern": {
+			Platform: platform.Platform{OS: "linux", Arch: "riscv64"},
+			Patterns: []string{"a_{{.OS}}_{{.Arch}}", "b_{{.OS}}_{{.Arch}}"},
+			Assets:   []string{"b_linux_riscv64"},
+			Pattern:  "b_linux_riscv64",
+			Index:    1,
+			Arch:     "riscv64",
+		},
+		"no-match-returns-first": {
+			Platform:   platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:   []string{"a_{{.OS}}_{{.Arch}}", "b_{{.OS}}_{{.Arch}}"},
+			Assets:     []string{"a_linux_amd64"},
+			Pattern:    "a_darwin_arm64",
+			Index:      0,
+			Arch:       "arm64",
+			ArmVersion: "8",
+		},
+		"no-assets-returns-first": {
+			Platform:   platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:   []string{"a_{{.OS}}_{{.Arch}}", "b_{{.OS}}_{{.Arch}}"},
+			NoAssets:   true,
+			Pattern:    "a_darwin_arm64",
+			Index:      0,
+			Arch:       "arm64",
+			ArmVersion: "8",
+		},
+		"no-patterns": {
+			Platform: platform.Platform{OS: "linux", Arch: "riscv64"},
+			Assets:   []string{"a_linux_riscv64"},
+			Pattern:  "",
+			Index:    0,
+			Arch:     "riscv64",
+		},
+		"own-arch-before-fallback": {
+			Platform:   platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:   []string{"a_{{.OS}}_{{.Arch}}"},
+			Assets:     []string{"a_darwin_universal", "a_darwin_arm64"},
+			Pattern:    "a_darwin_arm64",
+			Index:      0,
+			Arch:       "arm64",
+			ArmVersion: "8",
+		},
+		"own-arch-with-later-pattern-before-fallback": {
+			Platform:   platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:   []string{"a_{{.OS}}_{{.Arch}}", "b_{{.OS}}_{{.Arch}}"},
+			Assets:     []string{"a_darwin_universal", "b_darwin_arm64"},
+			Pattern:    "b_darwin_arm64",
+			Index:      1,
+			Arch:       "arm64",
+			ArmVersion: "8",
+		},
+		"fallbacks-in-order": {
+			Platform:   platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:   []string{"a_{{.OS}}_{{.Arch}}"},
+			Assets:     []string{"a_darwin_universal", "a_darwin_all"},
+			Pattern:    "a_darwin_all",
+			Index:      0,
+			Arch:       "all",
+			ArmVersion: "8",
+		},
+		"fallback-from-flag": {
+			Platform:      platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"},
+			Patterns:      []string{"a_{{.OS}}_{{.Arch}}$"},
+			ArchFallbacks: []string{"linux/arm=armhf,armel"},
+			Assets:        []string{"a_linux_armel"},
+			Pattern:       "a_linux_armel$",
+			Index:         0,
+			Arch:          "armel",
+			ArmVersion:    "7",
+		},
+		"fallback-same-as-own-arch": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			Patterns:      []string{"a_{{.OS}}_{{.Arch}}"},
+			ArchFallbacks: []string{"darwin=arm64,universal"},
+			Assets:        []string{"a_darwin_universal"},
+			Pattern:       "a_darwin_universal",
+			Index:         0,
+			Arch:          "universal",
+			ArmVersion:    "8",
+		},
+		"duplicate-patterns": {
+			Platform: platform.Platform{OS: "linux", Arch: "riscv64"},
+			Patterns: []string{"a_{{.OS}}", "a_{{.OS}}", "b_{{.OS}}"},
+			Assets:   []string{"b_linux"},
+			Pattern:  "b_linux",
+			Index:    2,
+			Arch:     "riscv64",
+		},
+		"arm-version-newest-first": {
+			Platform:   platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"},
+			Patterns:   []string{"a_armv{{.ArmVersion}}"},
+			Assets:     []string{"a_armv6", "a_armv7"},
+			Pattern:    "a_armv7",
+			Index:      0,
+			Arch:       "arm",
+			ArmVersion: "7",
+		},
+		"arm-version-older": {
+			Platform:   platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"},
+			Patterns:   []string{"a_armv{{.ArmVersion}}"},
+			Assets:     []string{"a_armv5", "a_armv6"},
+			Pattern:    "a_armv6",
+			Index:      0,
+			Arch:       "arm",
+			ArmVersion: "6",
+		},
+		"arm-version-no-match-returns-newest": {
+			Platform:   platform.Platform{OS: "linux", Arch: "arm", Variant: "v6"},
+			Patterns:   []string{"a_armv{{.ArmVersion}}"},
+			Assets:     []string{"a_armv7"},
+			Pattern:    "a_armv6",
+			Index:      0,
+			Arch:       "arm",
+			ArmVersion: "6",
+		},
+		"triple-follows-arm-version": {
+			Platform:   platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"},
+			Patterns:   []string{"a-{{.Triple}}$"},
+			Assets:     []string{"a-armv5te-unknown-linux-gnueabi", "a-arm-unknown-linux-gnueabihf"},
+			Pattern:    "a-arm-unknown-linux-gnueabihf$",
+			Index:      0,
+			Arch:       "arm",
+			ArmVersion: "6",
+		},
+		"amd64-level-older": {
+			Platform:   platform.Platform{OS: "linux", Arch: "amd64", Variant: "v3"},
+			Patterns:   []string{"a_{{.Arch}}_{{.Amd64Level}}"},
+			Assets:     []string{"a_amd64_v1", "a_amd64_v2", "a_amd64_v4"},
+			Pattern:    "a_amd64_v2",
+			Index:      0,
+			Arch:       "amd64",
+			Amd64Level: "v2",
+		},
+		"template-error": {
+			Platform: platform.Platform{OS: "linux", Arch: "riscv64"},
+			Patterns: []string{"{{.Nope}}"},
+			Assets:   []string{"a"},
+			Error:    true,
+		},
+		"invalid-fallback": {
+			Platform:      platform.Platform{OS: "linux", Arch: "riscv64"},
+			Patterns:      []string{"a"},
+			ArchFallbacks: []string{"linux"},
+			Assets:        []string{"a"},
+			Error:         true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			restoreFlags(t)
+
+			fArchFallbacks = tc.ArchFallbacks
+
+			target, err := newTargetPlatform(tc.Platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rel := &provider.Release{TagName: "v1.2.3"}
+
+			if !tc.NoAssets {
+				rel.Assets = []*provider.Asset{}
+
+				for _, asset := range tc.Assets {
+					rel.Assets = append(rel.Assets, &provider.Asset{Name: asset})
+				}
+			}
+
+			choice, err := choosePattern(tc.Patterns, rel, target, provider.Settings{})
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %+v", choice)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if choice.Pattern != tc.Pattern || choice.Index != tc.Index {
+				t.Errorf("got pattern %d %q; want %d %q", choice.Index, choice.Pattern, tc.Index, tc.Pattern)
+			}
+
+			if choice.Vars.Arch != tc.Arch {
+				t.Errorf("got .Arch %q; want %q", choice.Vars.Arch, tc.Arch)
+			}
+
+			if choice.Vars.ArmVersion != tc.ArmVersion || choice.Vars.Amd64Level != tc.Amd64Level {
+				t.Errorf("got .ArmVersion %q and .Amd64Level %q; want %q and %q",
+					choice.Vars.ArmVersion, choice.Vars.Amd64Level, tc.ArmVersion, tc.Amd64Level)
+			}
+
+			if choice.Vars.Ver != "1.2.3" || choice.Vars.GOOS != tc.Platform.OS {
+				t.Errorf("got .Ver %q and .GOOS %q for the chosen pattern", choice.Vars.Ver, choice.Vars.GOOS)
+			}
+		})
+	}
+}
+
+func TestArchFallbacks(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Platform      platform.Platform
+		ArchFallbacks []string
+		Expected      []string
+		Error         bool
+	}{
+		"default": {
+			Platform: platform.Platform{OS: "darwin", Arch: "arm64"},
+			Expected: []string{"all", "universal"},
+		},
+		"none": {
+			Platform: platform.Platform{OS: "linux", Arch: "amd64"},
+			Expected: nil,
+		},
+		"os-replaces-default": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			ArchFallbacks: []string{"darwin=universal"},
+			Expected:      []string{"universal"},
+		},
+		"os-arch-wins-over-os": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			ArchFallbacks: []string{"darwin/arm64=universal", "darwin=all"},
+			Expected:      []string{"universal"},
+		},
+		"os-for-other-arch": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "amd64"},
+			ArchFallbacks: []string{"darwin/arm64=universal", "darwin=all"},
+			Expected:      []string{"all"},
+		},
+		"later-wins": {
+			Platform:      platform.Platform{OS: "linux", Arch: "arm"},
+			ArchFallbacks: []string{"linux/arm=armhf", "linux/arm=armel"},
+			Expected:      []string{"armel"},
+		},
+		"case-and-spaces": {
+			Platform:      platform.Platform{OS: "linux", Arch: "arm"},
+			ArchFallbacks: []string{" Linux/ARM = armhf, ,armel "},
+			Expected:      []string{"armhf", "armel"},
+		},
+		"empty-disables-default": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			ArchFallbacks: []string{"darwin="},
+			Expected:      nil,
+		},
+		"missing-equals": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			ArchFallbacks: []string{"darwin"},
+			Error:         true,
+		},
+		"missing-key": {
+			Platform:      platform.Platform{OS: "darwin", Arch: "arm64"},
+			ArchFallbacks: []string{" =universal"},
+			Error:         true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			restoreFlags(t)
+
+			fArchFallbacks = tc.ArchFallbacks
+
+			got, err := archFallbacks(tc.Platform)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(got, tc.Expected) {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestExtPattern(t *testing.T) {
+	target, err := newTargetPlatform(platform.Platform{OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pattern, err := replacePatternVariables(`^tool\.{{.Ext}}$`, newPatternMatches("v1.0.0", target, provider.Settings{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re := regexp.MustCompile(pattern)
+
+	var tests = map[string]struct { // lint:no_dupe
+		Name     string
+		Expected bool
+	}{
+		"tar":       {Name: "tool.tar", Expected: true},
+		"tar-gz":    {Name: "tool.tar.gz", Expected: true},
+		"tgz":       {Name: "tool.tgz", Expected: true},
+		"tar-xz":    {Name: "tool.tar.xz", Expected: true},
+		"zip":       {Name: "tool.zip", Expected: true},
+		"exe":       {Name: "tool.exe", Expected: true},
+		"signature": {Name: "tool.tar.sig", Expected: false},
+		"7z":        {Name: "tool.7z", Expected: false},
+		"deb":       {Name: "tool.deb", Expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := re.MatchString(tc.Name); got != tc.Expected {
+				t.Errorf("%s matching %q: got %t; want %t", pattern, tc.Name, got, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/northwood-labs/download-asset/direct"
+	"github.com/northwood-labs/download-asset/gitea"
+	"github.com/northwood-labs/download-asset/github"
+	"github.com/northwood-labs/download-asset/gitlab"
+	"github.com/northwood-labs/download-asset/golang"
+	"github.com/northwood-labs/download-asset/hashicorp"
+	"github.com/northwood-labs/download-asset/oci"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const defaultEndpoint = "https://api.github.com"
+
+var (
+	// settings control how the provider chooses between versions.
+	settings provider.Settings
+
+	// defaultTagPrefixes are used for repositories whose tags are known not to
+	// be plain versions, unless a tag prefix or regex is set.
+	defaultTagPrefixes = map[string]string{
+		"golang/go": "go",
+	}
+)
+
+// newProvider creates the provider for the current repository, authenticating
+// in whichever way is appropriate for it.
+func newProvider(cmd *cobra.Command) (provider.Provider, error) {
+	providerName = resolveProviderName()
+
+	channel, err := provider.ParseChannel(fChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	tagPrefix := fTagPrefix
+	if tagPrefix == "" && fTagRegex == "" && providerName == provider.GitHub {
+		tagPrefix = defaultTagPrefixes[fOwnerRepo]
+	}
+
+	tagFormat, err := provider.NewTagFormat(tagPrefix, fTagRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, err := provider.ParseScheme(fVersionScheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme == provider.SchemeRegex && tagFormat.Regex == nil {
+		return nil, errors.New("the regex version scheme requires a tag-regex to capture the parts to compare")
+	}
+
+	settings = provider.Settings{
+		Channel:   channel,
+		TagFormat: tagFormat,
+		Scheme:    scheme,
+		Libc:      target.libc,
+	}
+
+	switch providerName {
+	case provider.GitHub:
+		apiEndpoint, _, _ = github.ParseDomain(fEndpoint)
+
+		err = resolveAuth(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := github.NewClient(&github.NewClientInput{
+			Token:    apiToken,
+			App:      apiApp,
+			Endpoint: fEndpoint,
+			NoWait:   fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create GitHub client")
+		}
+
+		return github.NewProvider(client, settings, fNoWait), nil
+
+	case provider.GitLab:
+		endpoint := fEndpoint
+		if endpoint == defaultEndpoint {
+			endpoint = gitlab.DefaultEndpoint
+		}
+
+		apiEndpoint = gitlab.APIEndpoint(endpoint)
+		apiToken, apiTokenSource = gitlab.LookupToken(endpoint, configHostTokens())
+		warnAnonymous()
+
+		client, err := gitlab.NewClient(&gitlab.NewClientInput{
+			Endpoint: endpoint,
+			Token:    apiToken,
+			JobToken: apiTokenSource == gitlab.JobTokenSource,
+			Settings: settings,
+			NoWait:   fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create GitLab client")
+		}
+
+		return client, nil
+
+	case provider.Gitea, "forgejo":
+		providerName = provider.Gitea
+
+		endpoint := fEndpoint
+		if endpoint == defaultEndpoint {
+			endpoint = gitea.DefaultEndpoint
+		}
+
+		apiEndpoint = gitea.APIEndpoint(endpoint)
+		apiToken, apiTokenSource = gitea.LookupToken(endpoint, configHostTokens())
+		warnAnonymous()
+
+		client, err := gitea.NewClient(&gitea.NewClientInput{
+			Endpoint: endpoint,
+			Token:    apiToken,
+			Settings: settings,
+			NoWait:   fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Gitea client")
+		}
+
+		return client, nil
+
+	case provider.URL:
+		apiEndpoint = fVersionIndexURL
+
+		client, err := direct.NewClient(&direct.NewClientInput{
+			VersionIndexURL: fVersionIndexURL,
+			VersionRegex:    fVersionRegex,
+			Settings:        settings,
+			NoWait:          fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create URL client")
+		}
+
+		return client, nil
+
+	case provider.OCI:
+		apiToken, apiTokenSource = oci.LookupToken(fImage, configHostTokens())
+		warnAnonymous()
+
+		client, err := oci.NewClient(&oci.NewClientInput{
+			Reference: fImage,
+			Token:     apiToken,
+			OS:        target.OS,
+			Arch:      target.Arch,
+			Variant:   target.variant(),
+			Settings:  settings,
+			NoWait:    fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create OCI client")
+		}
+
+		apiEndpoint = client.RegistryURL()
+
+		return client, nil
+
+	case provider.HashiCorp:
+		endpoint := fEndpoint
+		if endpoint == defaultEndpoint {
+			endpoint = hashicorp.DefaultEndpoint
+		}
+
+		client, err := hashicorp.NewClient(&hashicorp.NewClientInput{
+			Endpoint: endpoint,
+			OS:       target.OS,
+			Arch:     target.Arch,
+			Settings: settings,
+			NoWait:   fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create HashiCorp releases client")
+		}
+
+		apiEndpoint = client.BaseURL()
+
+		return client, nil
+
+	case provider.Golang:
+		endpoint := fEndpoint
+		if endpoint == defaultEndpoint {
+			endpoint = golang.DefaultEndpoint
+		}
+
+		client, err := golang.NewClient(&golang.NewClientInput{
+			Endpoint: endpoint,
+			OS:       target.OS,
+			Arch:     target.Arch,
+			Settings: settings,
+			NoWait:   fNoWait,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Go download client")
+		}
+
+		apiEndpoint = client.BaseURL()
+
+		return client, nil
+
+	default:
+		return nil, errors.Errorf("unknown provider: %s", providerName)
+	}
+}
+
+// resolveProviderName returns the provider set with --provider (or in the config
+// file), or infers one from the URL template, the image, the endpoint or the
+// repository.
+func resolveProviderName() string {
+	if fProvider != "" {
+		return strings.ToLower(fProvider)
+	}
+
+	if fURL != "" {
+		return provider.URL
+	}
+
+	if fImage != "" {
+		return provider.OCI
+	}
+
+	endpoint := strings.ToLower(fEndpoint)
+
+	switch {
+	case strings.Contains(endpoint, "gitlab"):
+		return provider.GitLab
+	case strings.Contains(endpoint, "gitea"),
+		strings.Contains(endpoint, "forgejo"),
+		strings.Contains(endpoint, "codeberg"):
+		return provider.Gitea
+	case strings.Contains(endpoint, "releases.hashicorp.com"):
+		return provider.HashiCorp
+	case strings.Contains(endpoint, "go.dev/dl"):
+		return provider.Golang
+	}
+
+	if fEndpoint == defaultEndpoint && fOwnerRepo == golang.Owner+"/"+golang.Repo {
+		return provider.Golang
+	}
+
+	return provider.GitHub
+}
+
+// discoverRelease finds the release which --tag asks for, or the newest one
+// which satisfies --constraint.
+func discoverRelease(source provider.Provider, ownerRepo []string) (*provider.Release, error) {
+	tag := fTag
+
+	if fConstraint != "" {
+		var err error
+
+		tag, err = source.GetLatestTag(ownerRepo[0], ownerRepo[1], fConstraint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tag == "latest" {
+		return source.GetLatestRelease(ownerRepo[0], ownerRepo[1])
+	}
+
+	return findRelease(source, ownerRepo, tag)
+}
+
+// findRelease returns the release for a tag or version, trying each tag which
+// the tag format allows (e.g., with and without a `v`). With a tag regex, a
+// version can only be turned back into a tag by finding it among the tags.
+func findRelease(source provider.Provider, ownerRepo []string, tagOrVersion string) (*provider.Release, error) {
+	candidates := settings.TagFormat.Candidates(tagOrVersion)
+
+	if len(candidates) == 0 {
+		tags, err := source.ListTags(ownerRepo[0], ownerRepo[1])
+		if err != nil {
+			return nil, err
+		}
+
+		tag, ok := settings.TagFormat.Find(tags, tagOrVersion)
+		if !ok {
+			return nil, errors.Errorf("no tag matching the tag regex has the version %s", tagOrVersion)
+		}
+
+		candidates = []string{tag}
+	}
+
+	var err error
+
+	for _, tag := range candidates {
+		var rel *provider.Release
+
+		rel, err = source.GetReleaseVersion(ownerRepo[0], ownerRepo[1], tag)
+		if err == nil {
+			return rel, nil
+		}
+	}
+
+	return nil, err
+}
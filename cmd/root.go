@@ -26,13 +26,13 @@
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "download-asset",
-	Short: "Downloads release assets from GitHub.",
+	Short: "Downloads release assets from GitHub, GitLab or Gitea.",
 	Long: LongHelpText(`
 	download-asset
 
-	Downloads release assets from GitHub.
+	Downloads release assets from GitHub, GitLab or Gitea.
 
-	Simplifies the process of downloading release assets from GitHub for the current
+	Simplifies the process of downloading release assets from GitHub, GitLab or Gitea for the current
 	operating system and current CPU architecture.`),
 }
 
@@ -0,0 +1,116 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// replacePatternVariables resolves a pattern, URL, image, archive path or
+// binary name template with the values of the pattern variables.
+func replacePatternVariables(pattern string, patternVars PatternMatches) (string, error) {
+	tmpl, err := template.New("pattern").
+		Funcs(templateFuncs(patternVars)).
+		Parse(pattern)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse pattern: %s", pattern)
+	}
+
+	buf := new(bytes.Buffer)
+
+	err = tmpl.Execute(buf, patternVars)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to apply values to pattern: %v", patternVars)
+	}
+
+	return buf.String(), nil
+}
+
+// templateFuncs returns the functions which templates can use. The arguments
+// are ordered so that the value being changed comes last, which lets them be
+// used in pipelines (e.g., `{{.OS | title}}`).
+func templateFuncs(patternVars PatternMatches) template.FuncMap {
+	return template.FuncMap{
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      title,
+		"regexQuote": regexp.QuoteMeta,
+		"replace": func(old, replacement, s string) string {
+			return strings.ReplaceAll(s, old, replacement)
+		},
+		"trimPrefix": func(prefix, s string) string {
+			return strings.TrimPrefix(s, prefix)
+		},
+		"default": func(fallback, s string) string {
+			if s == "" {
+				return fallback
+			}
+
+			return s
+		},
+		"platform": func(pairs ...string) (string, error) {
+			return lookupPlatform(patternVars.GOOS, patternVars.GOARCH, pairs)
+		},
+	}
+}
+
+// title upper-cases the first letter of each word, where anything other than a
+// letter or digit separates words (e.g., `darwin` becomes `Darwin`, and
+// `x86_64` becomes `X86_64`).
+func title(s string) string {
+	runes := []rune(s)
+	start := true
+
+	for i, r := range runes {
+		if start {
+			runes[i] = unicode.ToUpper(r)
+		}
+
+		start = !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+
+	return string(runes)
+}
+
+// lookupPlatform returns the value for the target platform from key/value
+// pairs, where a key is an os/arch, an OS or an arch using Go's names (e.g.,
+// `{{platform "darwin" "macOS" "windows/386" "win32"}}`). The most specific key
+// wins, and nothing matching returns an empty string, so it can be followed by
+// `default`.
+func lookupPlatform(goos, goarch string, pairs []string) (string, error) {
+	if len(pairs)%2 != 0 { // lint:allow_raw_number
+		return "", errors.Errorf("platform needs pairs of keys and values, but got %d arguments", len(pairs))
+	}
+
+	values := make(map[string]string, len(pairs)/2) // lint:allow_raw_number
+
+	for i := 0; i < len(pairs); i += 2 { // lint:allow_raw_number
+		values[strings.ToLower(pairs[i])] = pairs[i+1]
+	}
+
+	for _, key := range []string{goos + "/" + goarch, goos, goarch} {
+		if value, ok := values[key]; ok {
+			return value, nil
+		}
+	}
+
+	return "", nil
+}
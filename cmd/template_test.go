@@ -0,0 +1,205 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestReplacePatternVariables(t *testing.T) {
+	vars := PatternMatches{
+		Ver:    "1.2.3+build",
+		Tag:    "v1.2.3+build",
+		OS:     "linux",
+		Arch:   "amd64",
+		GOOS:   "linux",
+		GOARCH: "amd64",
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Pattern  string
+		Expected string
+		Error    bool
+	}{
+		"not-html-escaped": {
+			Pattern:  "tool-{{.Ver}}&{{.OS}}<x>",
+			Expected: "tool-1.2.3+build&linux<x>",
+		},
+		"lower": {
+			Pattern:  `{{"Linux" | lower}}`,
+			Expected: "linux",
+		},
+		"upper": {
+			Pattern:  "{{.Arch | upper}}",
+			Expected: "AMD64",
+		},
+		"title": {
+			Pattern:  "{{.OS | title}}",
+			Expected: "Linux",
+		},
+		"replace": {
+			Pattern:  `{{replace "amd64" "x86_64" .Arch}}`,
+			Expected: "x86_64",
+		},
+		"trim-prefix": {
+			Pattern:  `{{trimPrefix "v" .Tag}}`,
+			Expected: "1.2.3+build",
+		},
+		"regex-quote": {
+			Pattern:  "{{.Ver | regexQuote}}",
+			Expected: `1\.2\.3\+build`,
+		},
+		"default-empty": {
+			Pattern:  `{{.Libc | default "none"}}`,
+			Expected: "none",
+		},
+		"default-set": {
+			Pattern:  `{{.OS | default "none"}}`,
+			Expected: "linux",
+		},
+		"platform-os": {
+			Pattern:  `{{platform "darwin" "macOS" "linux" "Linux"}}`,
+			Expected: "Linux",
+		},
+		"platform-no-match-default": {
+			Pattern:  `{{platform "darwin" "macOS" | default .OS}}`,
+			Expected: "linux",
+		},
+		"platform-odd-pairs": {
+			Pattern: `{{platform "darwin"}}`,
+			Error:   true,
+		},
+		"unknown-function": {
+			Pattern: "{{.OS | nope}}",
+			Error:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := replacePatternVariables(tc.Pattern, vars)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestTitle(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected string
+	}{
+		"word":       {Input: "darwin", Expected: "Darwin"},
+		"words":      {Input: "apple darwin", Expected: "Apple Darwin"},
+		"underscore": {Input: "x86_64", Expected: "X86_64"},
+		"dash":       {Input: "linux-gnu", Expected: "Linux-Gnu"},
+		"digit":      {Input: "386", Expected: "386"},
+		"empty":      {Input: "", Expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := title(tc.Input); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLookupPlatform(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		GOOS     string
+		GOARCH   string
+		Pairs    []string
+		Expected string
+		Error    bool
+	}{
+		"os-arch-wins": {
+			GOOS:     "windows",
+			GOARCH:   "386",
+			Pairs:    []string{"386", "x86", "windows", "win64", "windows/386", "win32"},
+			Expected: "win32",
+		},
+		"os-wins-over-arch": {
+			GOOS:     "windows",
+			GOARCH:   "386",
+			Pairs:    []string{"386", "x86", "windows", "win64"},
+			Expected: "win64",
+		},
+		"arch": {
+			GOOS:     "linux",
+			GOARCH:   "386",
+			Pairs:    []string{"386", "x86", "windows", "win64"},
+			Expected: "x86",
+		},
+		"case-insensitive-keys": {
+			GOOS:     "darwin",
+			GOARCH:   "arm64",
+			Pairs:    []string{"Darwin", "macOS"},
+			Expected: "macOS",
+		},
+		"no-match": {
+			GOOS:     "linux",
+			GOARCH:   "amd64",
+			Pairs:    []string{"darwin", "macOS"},
+			Expected: "",
+		},
+		"no-pairs": {
+			GOOS:     "linux",
+			GOARCH:   "amd64",
+			Expected: "",
+		},
+		"odd-pairs": {
+			GOOS:   "linux",
+			GOARCH: "amd64",
+			Pairs:  []string{"darwin", "macOS", "linux"},
+			Error:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := lookupPlatform(tc.GOOS, tc.GOARCH, tc.Pairs)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
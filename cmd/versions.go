@@ -0,0 +1,390 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/northwood-labs/golang-utils/exiterrorf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	statusRelease    = "release"
+	statusPrerelease = "prerelease"
+	statusDraft      = "draft"
+	statusTag        = "tag"
+)
+
+var (
+	fLimit int
+	fJSON  bool
+
+	// versionsCmd represents the versions command
+	versionsCmd = &cobra.Command{
+		Use:   "versions",
+		Short: "Lists the releases and tags of a package, newest first",
+		Long: LongHelpText(`
+		Lists the releases and tags of a repository, with the highest version first.
+		Tags without a release are included, but have no assets.
+
+		For each one, shows when it was published, whether it is a pre-release or a
+		draft, how many assets it has, and which asset (if any) matches --pattern on
+		the current platform. This helps with choosing a --constraint, or finding the
+		last version that had a build for a platform.
+
+		Only stable releases and tags are listed, unless --channel is set to prerelease
+		(to also list pre-releases) or any (to also list drafts). --constraint,
+		--tag-prefix, --tag-regex and --version-scheme work the same as for 'get'.
+
+		--------------------------------------------------------------------------------
+
+		The same variables as for 'get' are supported (e.g., {{.Ver}}, {{.OS}},
+		{{.Arch}}, {{.Ext}}, {{.Triple}} and {{.Amd64Level}}). These can be used with:
+		    --pattern.
+
+		Set --json to print the list as JSON instead of a table.`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := readConfig()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			ownerRepo := strings.Split(fOwnerRepo, "/")
+			if len(ownerRepo) != 2 { // lint:allow_raw_number
+				exiterrorf.ExitErrorf(errors.New("invalid owner/repo"))
+			}
+
+			// Apply values from configuration file.
+			err = applyConfigValues(cmd, ownerRepo)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			// The target platform is needed to create some providers.
+			err = handleCurrentOSArch()
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			source, err := newProvider(cmd)
+			if err != nil {
+				exiterrorf.ExitErrorf(errors.Wrap(err, "failed to create API client"))
+			}
+
+			versions, err := listVersionInfo(source, ownerRepo, fLimit)
+			if err != nil {
+				exiterrorf.ExitErrorf(err)
+			}
+
+			if fJSON {
+				err = writeVersionsJSON(os.Stdout, versions)
+				if err != nil {
+					exiterrorf.ExitErrorf(err)
+				}
+
+				return
+			}
+
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+				BorderColumn(true).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("TAG", "PUBLISHED", "STATUS", "ASSETS", "MATCHING ASSET")
+
+			for i := range versions {
+				v := versions[i]
+
+				published, assets, match := "-", "-", "-"
+
+				if v.Published != nil {
+					published = v.Published.Format(time.DateOnly)
+				}
+
+				if v.Assets != nil {
+					assets = strconv.Itoa(*v.Assets)
+				}
+
+				if v.MatchingAsset != "" {
+					match = v.MatchingAsset
+				}
+
+				t.Row(v.Tag, published, v.Status, assets, match)
+			}
+
+			fmt.Println(t.Render())
+		},
+	}
+)
+
+// versionInfo describes one release or tag in the output of the versions command.
+type versionInfo struct {
+	Tag       string     `json:"tag"`
+	Version   string     `json:"version"`
+	Status    string     `json:"status"`
+	Published *time.Time `json:"published,omitempty"`
+
+	// Assets is nil if the provider can't list them.
+	Assets        *int   `json:"assets,omitempty"`
+	MatchingAsset string `json:"matching_asset,omitempty"`
+}
+
+// listVersionInfo describes the releases and tags which listVersions returns,
+// up to the limit. A limit of 0 means all of them.
+func listVersionInfo(source provider.Provider, ownerRepo []string, limit int) ([]versionInfo, error) {
+	releases, err := listVersions(source, ownerRepo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the versions")
+	}
+
+	if limit > 0 && len(releases) > limit {
+		releases = releases[:limit]
+	}
+
+	versions := make([]versionInfo, 0, len(releases))
+
+	for i := range releases {
+		info, err := newVersionInfo(releases[i])
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, info)
+	}
+
+	return versions, nil
+}
+
+// writeVersionsJSON prints the versions as an indented JSON array.
+func writeVersionsJSON(w io.Writer, versions []versionInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(versions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the versions as JSON")
+	}
+
+	return nil
+}
+
+// listVersions returns the releases, along with the tags which have no release,
+// in the channel and constraint, with the highest version first.
+func listVersions(source provider.Provider, ownerRepo []string) ([]*provider.Release, error) {
+	releases, err := source.ListReleases(ownerRepo[0], ownerRepo[1])
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := source.ListTags(ownerRepo[0], ownerRepo[1])
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(releases))
+	for i := range releases {
+		seen[releases[i].TagName] = true
+	}
+
+	for i := range tags {
+		if !seen[tags[i].Name] {
+			releases = append(releases, &provider.Release{
+				TagName:     tags[i].Name,
+				PublishedAt: tags[i].Date,
+			})
+		}
+	}
+
+	return settings.SortReleases(releases, fConstraint)
+}
+
+func newVersionInfo(release *provider.Release) (versionInfo, error) {
+	info := versionInfo{
+		Tag:     release.TagName,
+		Version: settings.TagFormat.Version(release.TagName),
+		Status:  statusRelease,
+	}
+
+	switch {
+	case release.Draft:
+		info.Status = statusDraft
+	case release.Prerelease || settings.TagFormat.IsPrerelease(release.TagName):
+		info.Status = statusPrerelease
+	case release.Assets == nil:
+		info.Status = statusTag
+	}
+
+	if !release.PublishedAt.IsZero() {
+		info.Published = &release.PublishedAt
+	}
+
+	if release.Assets == nil {
+		return info, nil
+	}
+
+	count := len(release.Assets)
+	info.Assets = &count
+
+	patterns := nonEmpty(fPatterns)
+	if len(patterns) == 0 {
+		return info, nil
+	}
+
+	choice, err := choosePattern(patterns, release, target, settings)
+	if err != nil {
+		return info, err
+	}
+
+	if asset, err := settings.MatchAsset(release.Assets, choice.Pattern); err == nil {
+		info.MatchingAsset = asset.Name
+	}
+
+	return info, nil
+}
+
+func init() {
+	rootCmd.AddCommand(versionsCmd)
+
+	versionsCmd.Flags().StringVarP(
+		&fOwnerRepo,
+		"owner-repo",
+		"r",
+		"",
+		"The owner and repository name in the format of 'owner/repo'.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fEndpoint,
+		"endpoint",
+		"e",
+		defaultEndpoint,
+		"The API domain to use.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fProvider,
+		"provider",
+		"",
+		"",
+		"Where releases are published: github, gitlab, gitea, url, oci, hashicorp or golang. Inferred from --endpoint, --url, --image or --owner-repo if unset.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fImage,
+		"image",
+		"i",
+		"",
+		"An OCI image or artifact reference whose tags should be listed.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fVersionIndexURL,
+		"version-index-url",
+		"",
+		"",
+		"A page which lists the available versions, for use with --provider url.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fVersionRegex,
+		"version-regex",
+		"",
+		"",
+		"A regular expression which finds the versions on --version-index-url. Use a group named 'version' to capture part of the match.",
+	)
+	versionsCmd.Flags().StringArrayVarP(
+		&fPatterns,
+		"pattern",
+		"p",
+		nil,
+		"The naming pattern of the asset to look for in each release. Can be repeated, to try several patterns in order.",
+	)
+	versionsCmd.Flags().StringArrayVarP(
+		&fArchFallbacks,
+		"arch-fallback",
+		"",
+		nil,
+		"The .Arch values to try when no asset matches a platform, as os/arch=value,value or os=value,value (e.g., darwin=universal). Can be repeated.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fConstraint,
+		"constraint",
+		"c",
+		"",
+		"Only list versions in a particular range.",
+	)
+	versionsCmd.Flags().IntVarP(
+		&fLimit,
+		"limit",
+		"n",
+		0,
+		"The most versions to list. Defaults to all of them.",
+	)
+	versionsCmd.Flags().BoolVarP(
+		&fJSON,
+		"json",
+		"",
+		false,
+		"Print the versions as JSON.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fChannel,
+		"channel",
+		"",
+		"",
+		"Which releases to list: stable, prerelease or any (which includes drafts). Defaults to stable.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fTagPrefix,
+		"tag-prefix",
+		"",
+		"",
+		"Only list tags with this prefix, which comes before the version (e.g., 'cli/' for 'cli/v2.0.0').",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fTagRegex,
+		"tag-regex",
+		"",
+		"",
+		"Only list tags matching this regular expression. Use a group named 'version' to capture the version.",
+	)
+	versionsCmd.Flags().StringVarP(
+		&fVersionScheme,
+		"version-scheme",
+		"",
+		"",
+		"How to compare versions: semver, calver, lexical, commit-date or regex. Defaults to semver.",
+	)
+	versionsCmd.Flags().BoolVarP(
+		&fNoWait,
+		"no-wait",
+		"",
+		false,
+		"Fail immediately when the GitHub API rate limit is hit, instead of waiting for it to reset.",
+	)
+
+	handleAuthFlags(versionsCmd)
+
+	handleTargetFlags(versionsCmd)
+	handleFlags(versionsCmd)
+}
@@ -0,0 +1,339 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// versionsProvider lists a fixed set of releases and tags.
+type versionsProvider struct {
+	provider.Provider
+
+	releases []*provider.Release
+	tags     []provider.Tag
+}
+
+func (p *versionsProvider) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	return slices.Clone(p.releases), nil
+}
+
+func (p *versionsProvider) ListTags(owner, repo string) ([]provider.Tag, error) {
+	return p.tags, nil
+}
+
+func TestListVersionInfo(t *testing.T) {
+	source := &versionsProvider{
+		releases: []*provider.Release{
+			{TagName: "v1.0.0", Assets: []*provider.Asset{}},
+			{TagName: "v1.2.0", Assets: []*provider.Asset{}},
+			{TagName: "v1.3.0-rc.1", Assets: []*provider.Asset{}},
+			{TagName: "v2.0.0", Draft: true, Assets: []*provider.Asset{}},
+		},
+		tags: []provider.Tag{
+			{Name: "v1.0.0"},
+			{Name: "v1.1.0"},
+			{Name: "v1.2.0"},
+			{Name: "nightly"},
+		},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Channel    provider.Channel
+		Constraint string
+		Limit      int
+		Expected   []string
+		Error      bool
+	}{
+		"stable": {
+			Expected: []string{"v1.2.0:release", "v1.1.0:tag", "v1.0.0:release"},
+		},
+		"prerelease": {
+			Channel:  provider.ChannelPrerelease,
+			Expected: []string{"v1.3.0-rc.1:prerelease", "v1.2.0:release", "v1.1.0:tag", "v1.0.0:release"},
+		},
+		"any": {
+			Channel:  provider.ChannelAny,
+			Expected: []string{"v2.0.0:draft", "v1.3.0-rc.1:prerelease", "v1.2.0:release", "v1.1.0:tag", "v1.0.0:release"},
+		},
+		"constraint": {
+			Constraint: "< 1.2",
+			Expected:   []string{"v1.1.0:tag", "v1.0.0:release"},
+		},
+		"limit": {
+			Limit:    2,
+			Expected: []string{"v1.2.0:release", "v1.1.0:tag"},
+		},
+		"limit-above-count": {
+			Limit:    10,
+			Expected: []string{"v1.2.0:release", "v1.1.0:tag", "v1.0.0:release"},
+		},
+		"invalid-constraint": {
+			Constraint: "not a constraint",
+			Error:      true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			restoreVersions(t)
+
+			settings = provider.Settings{Channel: tc.Channel}
+			fConstraint = tc.Constraint
+
+			versions, err := listVersionInfo(source, []string{"owner", "repo"}, tc.Limit)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %v", versions)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, len(versions))
+			for i := range versions {
+				got[i] = versions[i].Tag + ":" + versions[i].Status
+			}
+
+			if !slices.Equal(got, tc.Expected) {
+				t.Errorf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestNewVersionInfo(t *testing.T) {
+	published := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC) // lint:allow_raw_number
+
+	assets := []*provider.Asset{
+		{Name: "tool_linux_arm64.tar.gz"},
+		{Name: "tool_linux_amd64.tar.gz"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Release   provider.Release
+		Patterns  []string
+		TagPrefix string
+
+		Version       string
+		Status        string
+		Published     bool
+		Assets        int
+		NoAssets      bool
+		MatchingAsset string
+	}{
+		"release": {
+			Release:   provider.Release{TagName: "v1.2.0", PublishedAt: published, Assets: assets},
+			Version:   "1.2.0",
+			Status:    statusRelease,
+			Published: true,
+			Assets:    2,
+		},
+		"draft-wins-over-prerelease": {
+			Release: provider.Release{TagName: "v1.3.0-rc.1", Draft: true, Prerelease: true, Assets: assets},
+			Version: "1.3.0-rc.1",
+			Status:  statusDraft,
+			Assets:  2,
+		},
+		"prerelease-flag": {
+			Release: provider.Release{TagName: "v1.3.0", Prerelease: true, Assets: []*provider.Asset{}},
+			Version: "1.3.0",
+			Status:  statusPrerelease,
+			Assets:  0,
+		},
+		"prerelease-tag-wins-over-tag": {
+			Release:  provider.Release{TagName: "v1.3.0-rc.1"},
+			Version:  "1.3.0-rc.1",
+			Status:   statusPrerelease,
+			NoAssets: true,
+		},
+		"prefixed-prerelease-tag": {
+			Release:   provider.Release{TagName: "cli/v2.0.0-rc.1"},
+			TagPrefix: "cli/",
+			Version:   "2.0.0-rc.1",
+			Status:    statusPrerelease,
+			NoAssets:  true,
+		},
+		"tag": {
+			Release:   provider.Release{TagName: "v1.1.0", PublishedAt: published},
+			Version:   "1.1.0",
+			Status:    statusTag,
+			Published: true,
+			NoAssets:  true,
+		},
+		"tag-ignores-patterns": {
+			Release:  provider.Release{TagName: "v1.1.0"},
+			Patterns: []string{"tool_{{.OS}}_{{.Arch}}.tar.gz"},
+			Version:  "1.1.0",
+			Status:   statusTag,
+			NoAssets: true,
+		},
+		"matching-asset": {
+			Release:       provider.Release{TagName: "v1.2.0", Assets: assets},
+			Patterns:      []string{"tool_{{.OS}}_{{.Arch}}.tar.gz"},
+			Version:       "1.2.0",
+			Status:        statusRelease,
+			Assets:        2,
+			MatchingAsset: "tool_linux_amd64.tar.gz",
+		},
+		"second-pattern": {
+			Release:       provider.Release{TagName: "v1.2.0", Assets: assets},
+			Patterns:      []string{"tool-{{.OS}}-{{.Arch}}.zip", "tool_{{.OS}}_{{.Arch}}.tar.gz"},
+			Version:       "1.2.0",
+			Status:        statusRelease,
+			Assets:        2,
+			MatchingAsset: "tool_linux_amd64.tar.gz",
+		},
+		"no-matching-asset": {
+			Release:  provider.Release{TagName: "v1.2.0", Assets: assets},
+			Patterns: []string{"tool_{{.OS}}_riscv64.tar.gz"},
+			Version:  "1.2.0",
+			Status:   statusRelease,
+			Assets:   2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			restoreVersions(t)
+			restoreFlags(t)
+
+			format, err := provider.NewTagFormat(tc.TagPrefix, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			settings = provider.Settings{TagFormat: format}
+			fPatterns = tc.Patterns
+
+			target, err = newTargetPlatform(platform.Platform{OS: "linux", Arch: "amd64"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := newVersionInfo(&tc.Release)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if info.Tag != tc.Release.TagName || info.Version != tc.Version {
+				t.Errorf("got %q (%q); want %q (%q)", info.Tag, info.Version, tc.Release.TagName, tc.Version)
+			}
+
+			if info.Status != tc.Status {
+				t.Errorf("status: got %q; want %q", info.Status, tc.Status)
+			}
+
+			if (info.Published != nil) != tc.Published {
+				t.Errorf("published: got %v; want it set: %t", info.Published, tc.Published)
+			}
+
+			switch {
+			case tc.NoAssets && info.Assets != nil:
+				t.Errorf("assets: got %d; want none", *info.Assets)
+			case !tc.NoAssets && (info.Assets == nil || *info.Assets != tc.Assets):
+				t.Errorf("assets: got %v; want %d", info.Assets, tc.Assets)
+			}
+
+			if info.MatchingAsset != tc.MatchingAsset {
+				t.Errorf("matching asset: got %q; want %q", info.MatchingAsset, tc.MatchingAsset)
+			}
+		})
+	}
+}
+
+func TestWriteVersionsJSON(t *testing.T) {
+	published := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC) // lint:allow_raw_number
+	count := 2
+
+	var tests = map[string]struct { // lint:no_dupe
+		Versions []versionInfo
+		Expected string
+	}{
+		"release": {
+			Versions: []versionInfo{{
+				Tag:           "v1.2.0",
+				Version:       "1.2.0",
+				Status:        statusRelease,
+				Published:     &published,
+				Assets:        &count,
+				MatchingAsset: "tool_linux_amd64.tar.gz",
+			}},
+			Expected: `[
+  {
+    "tag": "v1.2.0",
+    "version": "1.2.0",
+    "status": "release",
+    "published": "2024-03-01T12:00:00Z",
+    "assets": 2,
+    "matching_asset": "tool_linux_amd64.tar.gz"
+  }
+]
+`,
+		},
+		"tag-omits-empty-fields": {
+			Versions: []versionInfo{{Tag: "v1.1.0", Version: "1.1.0", Status: statusTag}},
+			Expected: `[
+  {
+    "tag": "v1.1.0",
+    "version": "1.1.0",
+    "status": "tag"
+  }
+]
+`,
+		},
+		"empty": {
+			Versions: []versionInfo{},
+			Expected: "[]\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			err := writeVersionsJSON(&b, tc.Versions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b.String() != tc.Expected {
+				t.Errorf("got %s; want %s", b.String(), tc.Expected)
+			}
+		})
+	}
+}
+
+// restoreVersions puts back the settings, constraint and target platform after
+// the test.
+func restoreVersions(t *testing.T) {
+	t.Helper()
+
+	s, constraint, p := settings, fConstraint, target
+
+	t.Cleanup(func() {
+		settings, fConstraint, target = s, constraint, p
+	})
+}
@@ -0,0 +1,236 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package direct provides a library for downloading assets straight from a URL,
+for projects which publish binaries on a plain download site instead of using a
+forge with a releases API.
+
+There are no release objects to look up, so the "pattern" passed to
+GetAssetStream is the (already resolved) URL of the asset itself. Versions can
+optionally be discovered by scraping an index page with a regular expression.
+*/
+package direct
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+// VersionGroup is the name of the capture group in the version regex which
+// holds the version. If the regex has no such group, the first capture group
+// is used instead, and if it has no groups at all, the whole match is used.
+const VersionGroup = provider.VersionGroup
+
+type (
+	NewClientInput struct {
+		// VersionIndexURL is a page which lists the available versions (e.g., a
+		// directory listing). Optional.
+		VersionIndexURL string
+
+		// VersionRegex finds the versions on the VersionIndexURL page. Required
+		// when VersionIndexURL is set.
+		VersionRegex string
+
+		// Settings choose between the versions found with VersionRegex.
+		Settings provider.Settings
+
+		// NoWait gives up when the download site answers with a 429, instead of
+		// waiting for its Retry-After.
+		NoWait bool
+	}
+
+	// Client downloads assets from URLs, and implements provider.Provider.
+	Client struct {
+		versionIndexURL string
+		versionRegex    *regexp.Regexp
+		httpClient      *http.Client
+		settings        provider.Settings
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	_ provider.Provider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	c := &Client{
+		versionIndexURL: input.VersionIndexURL,
+		settings:        input.Settings,
+		httpClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		},
+	}
+
+	if input.VersionIndexURL != "" {
+		if input.VersionRegex == "" {
+			return nil, errors.New("a version-regex is required with a version-index-url")
+		}
+
+		re, err := regexp.Compile(input.VersionRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version-regex: %s", input.VersionRegex)
+		}
+
+		c.versionRegex = re
+	}
+
+	return c, nil
+}
+
+// GetLatestRelease returns the highest version found on the version index.
+func (c *Client) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	tag, err := c.GetLatestTag(owner, repo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider.Release{TagName: tag}, nil
+}
+
+// GetReleaseVersion returns a release for the tag. There is nothing to look up,
+// so whether it exists is only known once the asset is downloaded.
+func (c *Client) GetReleaseVersion(owner, repo, tagName string) (*provider.Release, error) {
+	return &provider.Release{TagName: tagName}, nil
+}
+
+// ListReleases returns no releases, since there are only versions.
+func (c *Client) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	return []*provider.Release{}, nil
+}
+
+// GetLatestTag returns the highest version found on the version index which
+// satisfies the constraint.
+func (c *Client) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+// ListTags returns the versions found on the version index.
+func (c *Client) ListTags(owner, repo string) ([]provider.Tag, error) {
+	versions, err := c.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.NewTags(versions), nil
+}
+
+// ListVersions downloads the version index and returns every version found on
+// it, in the order they appear.
+func (c *Client) ListVersions() ([]string, error) {
+	if c.versionIndexURL == "" {
+		return nil, errors.New(
+			"a version-index-url and version-regex are required to discover versions; set a specific tag instead",
+		)
+	}
+
+	resp, err := c.get(c.versionIndexURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the version index")
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the version index")
+	}
+
+	return findVersions(c.versionRegex, string(b)), nil
+}
+
+// GetAssetStream downloads the URL passed as the pattern. The name of the asset
+// is the last segment of the URL's path.
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	u, err := url.Parse(pattern)
+	if err != nil || u.Host == "" {
+		return nil, "", errors.Errorf("invalid asset URL: %s", pattern)
+	}
+
+	name = path.Base(u.Path)
+
+	resp, err := c.get(u.String())
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to download %s", name)
+	}
+
+	return resp.Body, name, nil
+}
+
+// findVersions returns the unique versions matched by re, in the order they
+// first appear.
+func findVersions(re *regexp.Regexp, body string) []string {
+	group := 0
+
+	if re.NumSubexp() > 0 {
+		group = 1
+
+		if i := re.SubexpIndex(VersionGroup); i > 0 {
+			group = i
+		}
+	}
+
+	seen := map[string]bool{}
+	versions := []string{}
+
+	for _, m := range re.FindAllStringSubmatch(body, -1) {
+		v := m[group]
+
+		if v == "" || seen[v] {
+			continue
+		}
+
+		seen[v] = true
+		versions = append(versions, v)
+	}
+
+	return versions
+}
+
+func (c *Client) get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+
+		return nil, errors.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+const indexPage = `<html><body>
+<a href="tool-1.9.0/">tool-1.9.0/</a>
+<a href="tool-1.10.2/">tool-1.10.2/</a>
+<a href="tool-2.0.0-rc1/">tool-2.0.0-rc1/</a>
+<a href="tool-1.10.2/">tool-1.10.2/</a>
+</body></html>`
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /releases/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, indexPage)
+	})
+
+	mux.HandleFunc("GET /releases/{ver}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ver") != "1.10.2" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		fmt.Fprint(w, "contents:"+r.PathValue("file"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGetLatestTag(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Regex      string
+		Constraint string
+		Channel    provider.Channel
+		Expected   string
+		Error      bool
+	}{
+		"named-group": {
+			Regex:    `tool-(?P<version>[0-9.]+)/`,
+			Expected: "1.10.2",
+		},
+		"first-group": {
+			Regex:    `href="tool-([^/]+)/`,
+			Expected: "1.10.2",
+		},
+		"prerelease-channel": {
+			Regex:    `href="tool-([^/]+)/`,
+			Channel:  provider.ChannelPrerelease,
+			Expected: "2.0.0-rc1",
+		},
+		"constraint": {
+			Regex:      `tool-(?P<version>[0-9.]+)/`,
+			Constraint: "< 1.10",
+			Expected:   "1.9.0",
+		},
+		"no-matches": {
+			Regex: `other-([0-9.]+)/`,
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				VersionIndexURL: server.URL + "/releases/",
+				VersionRegex:    tc.Regex,
+				Settings:        provider.Settings{Channel: tc.Channel},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := client.GetLatestTag("", "", tc.Constraint)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetLatestReleaseWithoutIndex(t *testing.T) {
+	client, err := NewClient(&NewClientInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.GetLatestRelease("", "")
+	if err == nil {
+		t.Error("expected an error without a version index")
+	}
+}
+
+func TestGetAssetStream(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		URL      string
+		Name     string
+		Expected string
+		Error    bool
+	}{
+		"found": {
+			URL:      server.URL + "/releases/1.10.2/tool_linux_amd64.zip",
+			Name:     "tool_linux_amd64.zip",
+			Expected: "contents:tool_linux_amd64.zip",
+		},
+		"not-found": {
+			URL:   server.URL + "/releases/0.0.1/tool_linux_amd64.zip",
+			Error: true,
+		},
+		"not-a-url": {
+			URL:   "tool_linux_amd64.zip",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			release, err := client.GetReleaseVersion("", "", "1.10.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream, assetName, err := client.GetAssetStream(nil, release, tc.URL)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stream.Close()
+
+			b, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assetName != tc.Name || string(b) != tc.Expected {
+				t.Errorf("got %q (%q); want %q (%q)", assetName, string(b), tc.Name, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestFindVersions(t *testing.T) {
+	actual := findVersions(regexp.MustCompile(`tool-(?P<version>[^/]+)/`), indexPage)
+	expected := []string{"1.9.0", "1.10.2", "2.0.0-rc1"}
+
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("got %v; want %v", actual, expected)
+	}
+}
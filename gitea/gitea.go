@@ -0,0 +1,343 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package gitea provides a library for downloading release assets from Gitea and
+Forgejo instances (including Codeberg).
+*/
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEndpoint is used when no endpoint has been provided.
+	DefaultEndpoint = "https://codeberg.org"
+
+	apiPath = "/api/v1"
+	perPage = "50"
+)
+
+type (
+	NewClientInput struct {
+		Endpoint string
+		Token    string
+
+		// Settings choose between the repository's releases and tags.
+		Settings provider.Settings
+
+		// NoWait gives up on a 429 from the Gitea or Forgejo instance.
+		NoWait bool
+	}
+
+	// Client talks to the Gitea (or Forgejo) REST API, and implements
+	// provider.Provider.
+	Client struct {
+		apiEndpoint string
+		host        string
+		token       string
+		httpClient  *http.Client
+		settings    provider.Settings
+	}
+
+	release struct {
+		TagName     string    `json:"tag_name"`
+		Name        string    `json:"name"`
+		Body        string    `json:"body"`
+		Draft       bool      `json:"draft"`
+		Prerelease  bool      `json:"prerelease"`
+		PublishedAt time.Time `json:"published_at"`
+		Assets      []struct {
+			ID                 int64  `json:"id"`
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+
+	tag struct {
+		Name   string `json:"name"`
+		Commit struct {
+			Created time.Time `json:"created"`
+		} `json:"commit"`
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	_ provider.Provider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	apiEndpoint := APIEndpoint(input.Endpoint)
+
+	u, err := url.Parse(apiEndpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid Gitea endpoint: %s", input.Endpoint)
+	}
+
+	return &Client{
+		apiEndpoint: apiEndpoint,
+		host:        u.Host,
+		token:       input.Token,
+		settings:    input.Settings,
+		httpClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		},
+	}, nil
+}
+
+// APIEndpoint normalizes an endpoint (e.g., `git.company.com`) into the URL of
+// its REST API (e.g., `https://git.company.com/api/v1`).
+func APIEndpoint(endpoint string) string {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	if !strings.HasSuffix(endpoint, apiPath) {
+		endpoint += apiPath
+	}
+
+	return endpoint
+}
+
+// GetLatestRelease returns the release which Gitea considers to be the latest.
+// If the channel allows pre-releases, or only some tags hold versions, it
+// instead returns the release in the channel with the highest version.
+func (c *Client) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	if !c.settings.LatestByVersion() {
+		r := release{}
+
+		_, err := c.getJSON(c.repoURL(owner, repo, "releases/latest"), &r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get latest release")
+		}
+
+		return toRelease(&r), nil
+	}
+
+	releases, err := c.listReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.settings.LatestRelease(releases)
+}
+
+// GetReleaseVersion returns the release for a tag. Draft releases cannot be
+// looked up by tag, so they are found in the list of releases instead.
+func (c *Client) GetReleaseVersion(owner, repo, tagName string) (*provider.Release, error) {
+	r := release{}
+
+	_, err := c.getJSON(c.repoURL(owner, repo, "releases/tags/"+url.PathEscape(tagName)), &r)
+	if err == nil {
+		return toRelease(&r), nil
+	}
+
+	err = errors.Wrap(err, "failed to get release by tag")
+
+	if !c.settings.Channel.AllowsDrafts() {
+		return nil, err
+	}
+
+	releases, listErr := c.listReleases(owner, repo)
+	if listErr != nil {
+		return nil, err
+	}
+
+	for i := range releases {
+		if releases[i].Draft && releases[i].TagName == tagName {
+			return releases[i], nil
+		}
+	}
+
+	return nil, err
+}
+
+// ListReleases returns every release in the repository. Drafts are included
+// when the token is allowed to see them.
+func (c *Client) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	return c.listReleases(owner, repo)
+}
+
+func (c *Client) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+func (c *Client) ListTags(owner, repo string) ([]provider.Tag, error) {
+	tags := []provider.Tag{}
+	next := c.repoURL(owner, repo, "tags") + "?limit=" + perPage
+
+	for next != "" {
+		page := []tag{}
+
+		resp, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
+		}
+
+		for i := range page {
+			tags = append(tags, provider.Tag{Name: page[i].Name, Date: page[i].Commit.Created})
+		}
+
+		next = ""
+
+		if len(page) > 0 {
+			next = provider.NextLink(resp.Header.Get("Link"))
+		}
+	}
+
+	return tags, nil
+}
+
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	asset, err := c.settings.MatchAsset(release.Assets, pattern)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.get(asset.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to download %s", asset.Name)
+	}
+
+	return resp.Body, asset.Name, nil
+}
+
+// listReleases returns every release in the repository, including pre-releases.
+// Drafts are included when the token is allowed to see them.
+func (c *Client) listReleases(owner, repo string) ([]*provider.Release, error) {
+	releases := []*provider.Release{}
+	next := c.repoURL(owner, repo, "releases") + "?limit=" + perPage
+
+	for next != "" {
+		page := []release{}
+
+		resp, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list releases")
+		}
+
+		for i := range page {
+			releases = append(releases, toRelease(&page[i]))
+		}
+
+		next = ""
+
+		if len(page) > 0 {
+			next = provider.NextLink(resp.Header.Get("Link"))
+		}
+	}
+
+	return releases, nil
+}
+
+// toRelease converts a Gitea release into the provider-agnostic type.
+func toRelease(r *release) *provider.Release {
+	rel := &provider.Release{
+		TagName:     r.TagName,
+		Name:        r.Name,
+		Body:        r.Body,
+		Prerelease:  r.Prerelease,
+		Draft:       r.Draft,
+		PublishedAt: r.PublishedAt,
+		Assets:      make([]*provider.Asset, 0, len(r.Assets)),
+	}
+
+	for i := range r.Assets {
+		rel.Assets = append(rel.Assets, &provider.Asset{
+			ID:   r.Assets[i].ID,
+			Name: r.Assets[i].Name,
+			URL:  r.Assets[i].BrowserDownloadURL,
+		})
+	}
+
+	return rel
+}
+
+func (c *Client) repoURL(owner, repo, path string) string {
+	return c.apiEndpoint + "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo) + "/" + path
+}
+
+func (c *Client) getJSON(rawURL string, v any) (*http.Response, error) {
+	resp, err := c.get(rawURL)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to decode the Gitea API response")
+	}
+
+	return resp, nil
+}
+
+// get performs a GET request. Credentials are only sent to the Gitea host
+// itself, never to third-party URLs.
+func (c *Client) get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	if c.token != "" && req.URL.Host == c.host {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+
+		message := struct {
+			Message string `json:"message"`
+		}{}
+
+		_ = json.NewDecoder(resp.Body).Decode(&message) // lint:allow_unhandled
+
+		return nil, errors.Errorf("GET %s: %s (%s)", req.URL.Redacted(), resp.Status, message.Message)
+	}
+
+	return resp, nil
+}
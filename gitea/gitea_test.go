@@ -0,0 +1,214 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitea
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+const testToken = "gitea-test"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	release := func(tag string) string {
+		return fmt.Sprintf(`{
+			"tag_name": %[1]q,
+			"name": "Release %[1]s",
+			"published_at": "2024-03-01T00:00:00Z",
+			"assets": [
+				{"id": 1, "name": "tool_linux_amd64.tar.gz", "browser_download_url": "%[2]s/attachments/1"},
+				{"id": 2, "name": "tool_linux_amd64.tar.gz.sha256", "browser_download_url": "%[2]s/attachments/2"}
+			]
+		}`, tag, server.URL)
+	}
+
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, release("v1.2.0"))
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("tag") == "v9.9.9" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "The target couldn't be found."}`)
+
+			return
+		}
+
+		fmt.Fprint(w, release(r.PathValue("tag")))
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/tags", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Set("Link", fmt.Sprintf(
+				`<%[1]s%[2]s?limit=50&page=2>; rel="next",<%[1]s%[2]s?limit=50&page=2>; rel="last"`,
+				server.URL, r.URL.Path,
+			))
+			fmt.Fprint(w, `[{"name": "v1.0.0"}, {"name": "v1.1.0"}, {"name": "nightly", "commit": {"created": "2024-10-18T01:00:00Z"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"name": "v2.0.0", "commit": {"created": "2024-10-17T01:00:00Z"}}, {"name": "v1.2.0"}]`)
+		}
+	})
+
+	mux.HandleFunc("GET /attachments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token "+testToken {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		fmt.Fprint(w, "attachment:"+r.PathValue("id"))
+	})
+
+	return server
+}
+
+func TestGetReleaseVersion(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Tag   string
+		Error bool
+	}{
+		"found": {
+			Tag: "v1.1.0",
+		},
+		"not-found": {
+			Tag:   "v9.9.9",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			release, err := client.GetReleaseVersion("team", "tool", tc.Tag)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if release.TagName != tc.Tag {
+				t.Errorf("got %q; want %q", release.TagName, tc.Tag)
+			}
+
+			if len(release.Assets) != 2 { // lint:allow_raw_number
+				t.Errorf("got %d assets; want 2", len(release.Assets))
+			}
+		})
+	}
+}
+
+func TestGetLatestTag(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Scheme     provider.Scheme
+		Constraint string
+		Expected   string
+	}{
+		"latest": {
+			Constraint: "",
+			Expected:   "v2.0.0",
+		},
+		"constrained": {
+			Constraint: "< 2.0",
+			Expected:   "v1.2.0",
+		},
+		"commit-date": {
+			Scheme:   provider.SchemeCommitDate,
+			Expected: "nightly",
+		},
+		"commit-date-constrained": {
+			Scheme:     provider.SchemeCommitDate,
+			Constraint: "< 2024-10-18",
+			Expected:   "v2.0.0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				Endpoint: server.URL,
+				Settings: provider.Settings{Scheme: tc.Scheme},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := client.GetLatestTag("team", "tool", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetAssetStream(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL, Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := client.GetLatestRelease("team", "tool")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream, name, err := client.GetAssetStream([]string{"team", "tool"}, release, `linux_amd64\.tar\.gz$`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	b, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "tool_linux_amd64.tar.gz" || string(b) != "attachment:1" {
+		t.Errorf("got %q (%q); want %q (%q)", name, string(b), "tool_linux_amd64.tar.gz", "attachment:1")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitea
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// LookupToken finds a token for the Gitea or Forgejo instance behind endpoint.
+// It checks GITEA_TOKEN and FORGEJO_TOKEN, then hostTokens, then ~/.netrc.
+func LookupToken(endpoint string, hostTokens map[string]string) (token, source string) { // lint:allow_named_returns
+	for _, envVar := range []string{"GITEA_TOKEN", "FORGEJO_TOKEN"} {
+		if v := os.Getenv(envVar); v != "" {
+			return v, "$" + envVar
+		}
+	}
+
+	u, err := url.Parse(APIEndpoint(endpoint))
+	if err != nil {
+		return "", ""
+	}
+
+	host := u.Hostname()
+
+	if v := hostTokens[host]; v != "" {
+		return v, "config file (" + host + ")"
+	}
+
+	if v, path := provider.NetrcToken(host); v != "" {
+		return v, path
+	}
+
+	return "", ""
+}
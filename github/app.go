@@ -0,0 +1,169 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// GitHub rejects JWTs which are valid for longer than 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+
+	// Allow for the local clock being slightly ahead of GitHub's.
+	appJWTClockSkew = 60 * time.Second
+
+	// Installation tokens last an hour. Refresh them well before they expire so
+	// that a long download doesn't start with a token that is about to lapse.
+	installationTokenRefresh = 5 * time.Minute
+)
+
+// AppAuth holds the credentials for authenticating as a GitHub App installation.
+type AppAuth struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     []byte
+}
+
+// appTokenSource mints a JWT for the GitHub App, and exchanges it for an
+// installation access token.
+type appTokenSource struct {
+	auth     *AppAuth
+	key      *rsa.PrivateKey
+	endpoint string
+	base     http.RoundTripper
+}
+
+// NewAppTokenSource returns an oauth2.TokenSource which yields installation
+// access tokens for the GitHub App, refreshing them before they expire.
+func NewAppTokenSource(auth *AppAuth, endpoint string, base http.RoundTripper) (oauth2.TokenSource, error) {
+	if auth.AppID == 0 || auth.InstallationID == 0 {
+		return nil, errors.New("a GitHub App requires both an app ID and an installation ID")
+	}
+
+	key, err := parsePrivateKey(auth.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &appTokenSource{
+		auth:     auth,
+		key:      key,
+		endpoint: endpoint,
+		base:     base,
+	}
+
+	return oauth2.ReuseTokenSourceWithExpiry(nil, src, installationTokenRefresh), nil
+}
+
+// Token implements oauth2.TokenSource.
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newGitHubClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: jwt,
+				TokenType:   "Bearer",
+			}),
+			Base: s.base,
+		},
+	}, s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	installationToken, _, err := client.Apps.CreateInstallationToken(ctx, s.auth.InstallationID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GitHub App installation token")
+	}
+
+	return &oauth2.Token{
+		AccessToken: installationToken.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      installationToken.GetExpiresAt().Time,
+	}, nil
+}
+
+// jwt creates an RS256-signed JSON Web Token which identifies the GitHub App.
+func (s *appTokenSource) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode JWT header")
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(s.auth.AppID, 10),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode JWT claims")
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign JWT")
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey reads a PEM-encoded RSA private key, in either PKCS #1 (what
+// GitHub generates) or PKCS #8 format.
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("failed to decode the GitHub App private key as PEM")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the GitHub App private key")
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the GitHub App private key is not an RSA key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAppAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	var exchanges atomic.Int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+
+		if err := verifyTestJWT(r.Header.Get("Authorization"), &key.PublicKey); err != nil {
+			t.Errorf("invalid JWT: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(
+			w,
+			`{"token": "ghs_installation", "expires_at": %q}`,
+			time.Now().Add(time.Hour).Format(time.RFC3339),
+		)
+	})
+
+	mux.HandleFunc("GET /api/v3/repos/octocat/hello/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghs_installation" {
+			t.Errorf("unexpected Authorization header: %q", r.Header.Get("Authorization"))
+		}
+
+		fmt.Fprint(w, `{"tag_name": "v1.2.3"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{
+		Endpoint: server.URL,
+		App: &AppAuth{
+			AppID:          1234,
+			InstallationID: 42,
+			PrivateKey:     privateKey,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 3 {
+		release, err := GetLatestRelease(client, "octocat", "hello")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if release.GetTagName() != "v1.2.3" {
+			t.Errorf("got %q; want %q", release.GetTagName(), "v1.2.3")
+		}
+	}
+
+	// The installation token should be reused until it is close to expiring.
+	if exchanges.Load() != 1 {
+		t.Errorf("token exchanges: got %d; want 1", exchanges.Load())
+	}
+}
+
+func TestAppAuthenticationRequiresIDs(t *testing.T) {
+	_, err := NewClient(&NewClientInput{
+		App: &AppAuth{AppID: 1234},
+	})
+	if err == nil {
+		t.Error("expected an error when the installation ID is missing")
+	}
+}
+
+func verifyTestJWT(authorization string, key *rsa.PublicKey) error {
+	jwt, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return fmt.Errorf("not a bearer token: %q", authorization)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 parts, got %d", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+
+	claims := struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+
+	if claims.Iss != "1234" {
+		return fmt.Errorf("unexpected issuer %q", claims.Iss)
+	}
+
+	if claims.Exp-claims.Iat > int64((10 * time.Minute).Seconds()) {
+		return fmt.Errorf("JWT is valid for too long")
+	}
+
+	return nil
+}
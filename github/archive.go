@@ -22,7 +22,6 @@
 	"compress/gzip"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -32,7 +31,50 @@
 	"github.com/ulikunitz/xz"
 )
 
+// createFunc creates the file which a binary is written to, and returns its
+// path.
+type createFunc func() (*os.File, string, error)
+
 func Decompress(archiveStream io.ReadCloser, filename, findPattern, writeToBin string) (string, error) {
+	return decompress(archiveStream, filename, findPattern, binCreator(writeToBin))
+}
+
+// DecompressTo works like Decompress, but writes the binary to path instead of
+// a bin directory.
+func DecompressTo(archiveStream io.ReadCloser, filename, findPattern, path string) (string, error) {
+	return decompress(archiveStream, filename, findPattern, func() (*os.File, string, error) {
+		f, err := os.Create(path) // lint:allow_include_file
+		if err != nil {
+			return nil, path, errors.Wrap(err, "failed to create file")
+		}
+
+		return f, path, nil
+	})
+}
+
+// binCreator creates writeToBin in /usr/local/bin, falling back to $HOME/bin if
+// /usr/local/bin is not writable.
+func binCreator(writeToBin string) createFunc {
+	return func() (*os.File, string, error) {
+		// /usr/local/bin
+		binPath := "/" + filepath.Join("usr", "local", "bin", writeToBin)
+
+		f, err := os.Create(binPath) // lint:allow_include_file
+		if err != nil {
+			// ~/bin
+			binPath = filepath.Join(os.Getenv("HOME"), "bin", writeToBin)
+
+			f, err = os.Create(binPath) // lint:allow_include_file
+			if err != nil {
+				return nil, binPath, errors.Wrap(err, "failed to create file")
+			}
+		}
+
+		return f, binPath, nil
+	}
+}
+
+func decompress(archiveStream io.ReadCloser, filename, findPattern string, create createFunc) (string, error) {
 	var binPath string
 
 	// .tar.gz or .tgz
@@ -43,7 +85,7 @@ func Decompress(archiveStream io.ReadCloser, filename, findPattern, writeToBin s
 			return "", errors.Wrap(err, "failed to create gzip reader")
 		}
 
-		binPath, err = handleTar(g, findPattern, writeToBin)
+		binPath, err = handleTar(g, findPattern, create)
 		if err != nil {
 			return binPath, err
 		}
@@ -56,7 +98,7 @@ func Decompress(archiveStream io.ReadCloser, filename, findPattern, writeToBin s
 			return "", errors.Wrap(err, "failed to create xz reader")
 		}
 
-		binPath, err = handleTar(x, findPattern, writeToBin)
+		binPath, err = handleTar(x, findPattern, create)
 		if err != nil {
 			return binPath, err
 		}
@@ -66,32 +108,30 @@ func Decompress(archiveStream io.ReadCloser, filename, findPattern, writeToBin s
 		regexp.MustCompile(`\.tbz2$`).MatchString(filename) {
 		b := bzip2.NewReader(archiveStream)
 
-		binPath, err := handleTar(b, findPattern, writeToBin)
+		binPath, err := handleTar(b, findPattern, create)
+		if err != nil {
+			return binPath, err
+		}
+
+		return binPath, nil
+	} else if regexp.MustCompile(`\.tar$`).MatchString(filename) {
+		binPath, err := handleTar(archiveStream, findPattern, create)
 		if err != nil {
 			return binPath, err
 		}
 
 		return binPath, nil
 	} else if regexp.MustCompile(`\.zip$`).MatchString(filename) {
-		binPath, err := handleZip(archiveStream, findPattern, writeToBin)
+		binPath, err := handleZip(archiveStream, findPattern, create)
 		if err != nil {
 			return binPath, err
 		}
 
 		return binPath, nil
 	} else {
-		// /usr/local/bin
-		binPath := "/" + filepath.Join("usr", "local", "bin", writeToBin)
-
-		f, err := os.Create(binPath) // lint:allow_include_file
+		f, binPath, err := create()
 		if err != nil {
-			// ~/bin
-			binPath = filepath.Join(os.Getenv("HOME"), "bin", writeToBin)
-
-			f, err = os.Create(binPath) // lint:allow_include_file
-			if err != nil {
-				return binPath, errors.Wrap(err, "failed to create file")
-			}
+			return binPath, err
 		}
 
 		err = f.Chmod(0o0755) // lint:allow_raw_number
@@ -113,12 +153,11 @@ func Decompress(archiveStream io.ReadCloser, filename, findPattern, writeToBin s
 	}
 }
 
-func handleTar(g io.Reader, findPattern, writeToBin string) (string, error) {
+func handleTar(g io.Reader, findPattern string, create createFunc) (string, error) {
 	t := tar.NewReader(g)
 	found := false
 
-	// /usr/local/bin
-	binPath := "/" + filepath.Join("usr", "local", "bin", writeToBin)
+	var binPath string
 
 	for {
 		hdr, err := t.Next()
@@ -129,26 +168,22 @@ func handleTar(g io.Reader, findPattern, writeToBin string) (string, error) {
 		}
 
 		if hdr.Typeflag == tar.TypeReg {
-			hdr.Name = strings.TrimPrefix(hdr.Name, "./")
+			hdr.Name = strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "./"), "/")
 
-			if !strings.EqualFold(hdr.Name, findPattern) {
+			if !strings.EqualFold(hdr.Name, strings.TrimPrefix(findPattern, "/")) {
 				// fmt.Println(hdr.Name)
 				continue
 			}
 
 			found = true
 
-			f, err := os.Create(binPath) // lint:allow_include_file
+			f, path, err := create()
 			if err != nil {
-				// ~/bin
-				binPath = filepath.Join(os.Getenv("HOME"), "bin", writeToBin)
-
-				f, err = os.Create(binPath) // lint:allow_include_file
-				if err != nil {
-					return binPath, errors.Wrap(err, "failed to create file")
-				}
+				return path, err
 			}
 
+			binPath = path
+
 			err = f.Chmod(0o0755) // lint:allow_raw_number
 			if err != nil {
 				return binPath, errors.Wrap(err, "failed to make executable")
@@ -173,7 +208,7 @@ func handleTar(g io.Reader, findPattern, writeToBin string) (string, error) {
 	return binPath, nil
 }
 
-func handleZip(z io.ReadCloser, findPattern, writeToBin string) (string, error) {
+func handleZip(z io.ReadCloser, findPattern string, create createFunc) (string, error) {
 	b, err := io.ReadAll(z) // The readCloser is the one from the zip-package
 	if err != nil {
 		return "", errors.Wrap(err, "error reading zip file into memory")
@@ -187,8 +222,9 @@ func handleZip(z io.ReadCloser, findPattern, writeToBin string) (string, error)
 		return "", errors.Wrap(err, "error reading zip header")
 	}
 
-	// /usr/local/bin
-	binPath := "/" + filepath.Join("usr", "local", "bin", writeToBin)
+	var binPath string
+
+	found := false
 
 	for i := range r.File {
 		hdr := r.File[i]
@@ -197,22 +233,21 @@ func handleZip(z io.ReadCloser, findPattern, writeToBin string) (string, error)
 			continue
 		}
 
+		found = true
+
 		zp, err := hdr.Open()
 		if err != nil {
-			log.Fatal(err)
+			return binPath, errors.Wrapf(err, "failed to open '%s' inside the archive", hdr.Name)
 		}
+		defer zp.Close()
 
-		f, err := os.Create(binPath) // lint:allow_include_file
+		f, path, err := create()
 		if err != nil {
-			// ~/bin
-			binPath = filepath.Join(os.Getenv("HOME"), "bin", writeToBin)
-
-			f, err = os.Create(binPath) // lint:allow_include_file
-			if err != nil {
-				return binPath, errors.Wrap(err, "failed to create file")
-			}
+			return path, err
 		}
 
+		binPath = path
+
 		err = f.Chmod(0o0755) // lint:allow_raw_number
 		if err != nil {
 			return binPath, errors.Wrap(err, "failed to make executable")
@@ -220,7 +255,7 @@ func handleZip(z io.ReadCloser, findPattern, writeToBin string) (string, error)
 
 		_, err = io.Copy(f, zp) // lint:allow_decompress
 		if err != nil {
-			return binPath, errors.Wrap(err, "error reading tar header")
+			return binPath, errors.Wrap(err, "error reading zip file")
 		}
 
 		err = f.Close()
@@ -229,5 +264,9 @@ func handleZip(z io.ReadCloser, findPattern, writeToBin string) (string, error)
 		}
 	}
 
+	if !found {
+		return binPath, errors.New(fmt.Sprintf("failed to find '%s' inside the archive", findPattern))
+	}
+
 	return binPath, nil
 }
@@ -0,0 +1,131 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipFile is a file to add to a test archive. A Method other than zip.Store or
+// zip.Deflate can't be opened.
+type zipFile struct {
+	Name   string
+	Body   string
+	Method uint16
+}
+
+func newZip(t *testing.T, files []zipFile) io.ReadCloser {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	w := zip.NewWriter(&b)
+
+	for i := range files {
+		f, err := w.CreateRaw(&zip.FileHeader{
+			Name:               files[i].Name,
+			Method:             files[i].Method,
+			CompressedSize64:   uint64(len(files[i].Body)),
+			UncompressedSize64: uint64(len(files[i].Body)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.Write([]byte(files[i].Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return io.NopCloser(&b)
+}
+
+func TestDecompressToZip(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Files    []zipFile
+		Find     string
+		Expected string
+		Error    bool
+	}{
+		"found": {
+			Files:    []zipFile{{Name: "README.md", Body: "readme"}, {Name: "tool", Body: "binary"}},
+			Find:     "tool",
+			Expected: "binary",
+		},
+		"case-insensitive": {
+			Files:    []zipFile{{Name: "Tool.exe", Body: "binary"}},
+			Find:     "tool.exe",
+			Expected: "binary",
+		},
+		"not-found": {
+			Files: []zipFile{{Name: "README.md", Body: "readme"}},
+			Find:  "tool",
+			Error: true,
+		},
+		"not-found-in-directory": {
+			Files: []zipFile{{Name: "tool_1.0.0/tool", Body: "binary"}},
+			Find:  "tool",
+			Error: true,
+		},
+		"unsupported-method": {
+			Files: []zipFile{{Name: "tool", Body: "binary", Method: 99}}, // lint:allow_raw_number
+			Find:  "tool",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool")
+
+			_, err := DecompressTo(newZip(t, tc.Files), "tool.zip", tc.Find, path)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				if _, statErr := os.Stat(path); statErr == nil {
+					t.Error("expected no file to be written")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(path) // lint:allow_include_file
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
@@ -23,49 +23,77 @@
 	"log"
 	"net/http"
 	"os"
-	"regexp"
-	"sort"
 	"strings"
 
 	gh "github.com/google/go-github/v60/github"
 	"github.com/hashicorp/go-version"
 	"github.com/mailgun/errors"
+	"github.com/northwood-labs/download-asset/provider"
 	"golang.org/x/oauth2"
 )
 
-var (
-	oauthConf oauth2.Config
+// tagsPerPage is the largest page size the API allows, which is used for both
+// tags and releases.
+const tagsPerPage = 100
 
-	ctx = context.Background()
-)
+var ctx = context.Background()
 
 type (
 	NewClientInput struct {
 		Endpoint string
 		Token    string
+
+		// App authenticates as a GitHub App installation instead of with Token.
+		App *AppAuth
+
+		// NoWait fails on GitHub's primary and secondary rate limits instead of
+		// waiting them out.
+		NoWait bool
 	}
 )
 
 func NewClient(input *NewClientInput) (*gh.Client, error) {
-	oauthClient := oauthConf.Client(ctx, &oauth2.Token{
-		AccessToken: input.Token,
-		TokenType:   "Bearer",
-	})
-
-	var (
-		client *gh.Client
-		err    error
-	)
+	retryTransport := provider.NewRetryTransport(http.DefaultTransport, input.NoWait)
 
-	apiEndpoint, uploadEndpoint, _ := ParseDomain(input.Endpoint)
+	// Without a token, requests are made anonymously.
+	oauthClient := &http.Client{Transport: retryTransport}
 
-	if input.Endpoint != "" {
-		client, err = gh.NewClient(oauthClient).WithEnterpriseURLs(apiEndpoint, uploadEndpoint)
+	switch {
+	case input.App != nil:
+		tokenSource, err := NewAppTokenSource(input.App, input.Endpoint, retryTransport)
 		if err != nil {
-			return client, errors.Wrap(err, "failed to create new GitHub client")
+			return nil, err
 		}
-	} else {
-		client = gh.NewClient(oauthClient)
+
+		oauthClient.Transport = &oauth2.Transport{
+			Source: tokenSource,
+			Base:   retryTransport,
+		}
+	case input.Token != "":
+		oauthClient.Transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: input.Token,
+				TokenType:   "Bearer",
+			}),
+			Base: retryTransport,
+		}
+	}
+
+	return newGitHubClient(oauthClient, input.Endpoint)
+}
+
+// newGitHubClient creates a client for either github.com or a GitHub Enterprise
+// Server endpoint.
+func newGitHubClient(httpClient *http.Client, endpoint string) (*gh.Client, error) {
+	if endpoint == "" {
+		return gh.NewClient(httpClient), nil
+	}
+
+	apiEndpoint, uploadEndpoint, _ := ParseDomain(endpoint)
+
+	client, err := gh.NewClient(httpClient).WithEnterpriseURLs(apiEndpoint, uploadEndpoint)
+	if err != nil {
+		return client, errors.Wrap(err, "failed to create new GitHub client")
 	}
 
 	return client, nil
@@ -80,65 +108,119 @@ func GetLatestRelease(client *gh.Client, owner, repo string) (*gh.RepositoryRele
 	return release, nil
 }
 
+// GetLatestTag returns the highest stable version in the tags which satisfies
+// the constraint. The `go` prefix of the tags in golang/go is removed.
+//
+// Deprecated: Use GetLatestTagName, which returns the tag itself.
 func GetLatestTag(client *gh.Client, owner, repo, constraint string) (*version.Version, error) {
-	isGo := false
+	format := provider.TagFormat{}
 	if owner+"/"+repo == "golang/go" {
-		isGo = true
+		format.Prefix = "go"
 	}
 
-	refs, _, err := client.Git.ListMatchingRefs(ctx, owner, repo, &gh.ReferenceListOptions{
-		Ref: "tags",
-	})
+	tags, err := ListTags(client, owner, repo)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get latest release")
+		return nil, err
+	}
+
+	tag, err := provider.Settings{TagFormat: format}.LatestTag(tags, constraint)
+	if err != nil {
+		return nil, err
 	}
 
-	versions := make([]*version.Version, 0)
+	ver, err := version.NewVersion(format.Version(tag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the version of tag %s", tag)
+	}
+
+	return ver, nil
+}
+
+// GetLatestTagName returns the tag with the highest stable version which
+// satisfies the constraint.
+func GetLatestTagName(client *gh.Client, owner, repo, constraint string) (string, error) {
+	tags, err := ListTags(client, owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.Settings{}.LatestTag(tags, constraint)
+}
+
+// ListTags returns every tag in the repository, without their dates.
+func ListTags(client *gh.Client, owner, repo string) ([]provider.Tag, error) {
+	refs, err := listTagRefs(client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]provider.Tag, 0, len(refs))
 
 	for i := range refs {
 		ref := refs[i]
-		ver, _ := strings.CutPrefix(ref.GetRef(), "refs/tags/")
-
-		// Handle Go specially
-		if isGo {
-			if strings.HasPrefix(ver, "go") {
-				ver = strings.TrimPrefix(ver, "go")
-			} else {
-				continue
-			}
+		tag, _ := strings.CutPrefix(ref.GetRef(), "refs/tags/")
+
+		tags = append(tags, provider.Tag{Name: tag})
+	}
+
+	return tags, nil
+}
+
+// listTagRefs returns every tag in the repository. It doesn't stop early once a
+// tag satisfies the constraint, since no early stop would be sound: the refs are
+// sorted by name rather than by version, so a higher matching version may be on
+// the last page. The GraphQL API can sort them by commit date instead, but that
+// isn't version order either (e.g., a patch for an older major version which is
+// released after a newer one), and it needs a token.
+func listTagRefs(client *gh.Client, owner, repo string) ([]*gh.Reference, error) {
+	opts := &gh.ReferenceListOptions{
+		Ref: "tags",
+		ListOptions: gh.ListOptions{
+			PerPage: tagsPerPage,
+		},
+	}
+
+	refs := []*gh.Reference{}
+
+	for {
+		page, resp, err := client.Git.ListMatchingRefs(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
 		}
 
-		var v *version.Version
+		refs = append(refs, page...)
 
-		v, err = version.NewVersion(ver)
-		if err == nil && v != nil && v.String() != "" {
-			versions = append(versions, v)
+		if resp.NextPage == 0 {
+			return refs, nil
 		}
+
+		opts.Page = resp.NextPage
 	}
+}
 
-	// After this, the versions are properly sorted
-	sort.Sort(sort.Reverse(version.Collection(versions)))
+// ListReleases returns every release in the repository, including pre-releases.
+// Drafts are included when the token is allowed to see them.
+func ListReleases(client *gh.Client, owner, repo string) ([]*gh.RepositoryRelease, error) {
+	opts := &gh.ListOptions{
+		PerPage: tagsPerPage,
+	}
 
-	var constraints version.Constraints
+	releases := []*gh.RepositoryRelease{}
 
-	if constraint != "" {
-		constraints, err = version.NewConstraint(constraint)
+	for {
+		page, resp, err := client.Repositories.ListReleases(ctx, owner, repo, opts)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to create new version constraint")
+			return nil, errors.Wrap(err, "failed to list releases")
 		}
-	}
 
-	if len(versions) > 0 {
-		for i := range versions {
-			ver := versions[i]
+		releases = append(releases, page...)
 
-			if constraints.Check(ver) {
-				return ver, nil
-			}
+		if resp.NextPage == 0 {
+			return releases, nil
 		}
-	}
 
-	return &version.Version{}, errors.New("no matching versions found")
+		opts.Page = resp.NextPage
+	}
 }
 
 func GetReleaseVersion(client *gh.Client, owner, repo, tag string) (*gh.RepositoryRelease, error) {
@@ -156,32 +238,7 @@ func GetAssetStream( // lint:allow_named_returns
 	release *gh.RepositoryRelease,
 	pattern string,
 ) (archiveStream io.ReadCloser, name string, err error) {
-	var i int
-
-	for i = range release.Assets {
-		asset := release.Assets[i]
-		rePattern := regexp.MustCompile(pattern)
-
-		if rePattern.MatchString(*asset.Name) {
-			break
-		}
-	}
-
-	if len(release.Assets) == 0 {
-		return nil, "", errors.New("no release assets found")
-	}
-
-	asset := release.Assets[i]
-
-	rc, _, err := client.Repositories.DownloadReleaseAsset(
-		ctx,
-		ownerRepo[0],
-		ownerRepo[1],
-		*asset.ID,
-		http.DefaultClient,
-	)
-
-	return rc, asset.GetName(), err
+	return NewProvider(client, provider.Settings{}, false).GetAssetStream(ownerRepo, toRelease(release), pattern)
 }
 
 func DownloadStream(archiveStream io.ReadCloser, filename, findPattern, writeToBin string) (string, error) {
@@ -0,0 +1,463 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// newTagServer serves the tags in pages of perPage, linking each page to the
+// next one in the same way as the GitHub API.
+func newTagServer(t *testing.T, tags []string, perPage int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	var server *httptest.Server
+
+	mux.HandleFunc("GET /api/v3/repos/octocat/hello/git/matching-refs/tags", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if got := r.URL.Query().Get("per_page"); got != strconv.Itoa(tagsPerPage) {
+			t.Errorf("per_page: got %q; want %d", got, tagsPerPage)
+		}
+
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+
+		start := min((page-1)*perPage, len(tags))
+		end := min(start+perPage, len(tags))
+
+		if end < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(
+				`<%s%s?page=%d&per_page=%d>; rel="next"`,
+				server.URL, r.URL.Path, page+1, tagsPerPage,
+			))
+		}
+
+		refs := []string{}
+		for _, tag := range tags[start:end] {
+			refs = append(refs, fmt.Sprintf(`{"ref": "refs/tags/%s"}`, tag))
+		}
+
+		fmt.Fprintf(w, "[%s]", strings.Join(refs, ","))
+	})
+
+	server = httptest.NewServer(mux)
+
+	return server
+}
+
+func TestGetLatestTagPagination(t *testing.T) {
+	// Tags are returned sorted by name, so the newest version is not
+	// necessarily on the first page.
+	tags := []string{}
+	for i := range 250 {
+		tags = append(tags, fmt.Sprintf("v1.%d.0", i))
+	}
+
+	tags = append(tags, "v10.0.0", "v2.0.0", "v2.1.0-beta.1")
+
+	var tests = map[string]struct { // lint:no_dupe
+		PerPage    int
+		Constraint string
+		Expected   string
+		Requests   int32
+	}{
+		"single-page": {
+			PerPage:  1000,
+			Expected: "v10.0.0",
+			Requests: 1,
+		},
+		"many-pages": {
+			PerPage:  100,
+			Expected: "v10.0.0",
+			Requests: 3,
+		},
+		// Every page is still read, since a later one may have a higher match.
+		"constraint-on-first-page": {
+			PerPage:    100,
+			Constraint: "~> 1.42.0",
+			Expected:   "v1.42.0",
+			Requests:   3,
+		},
+		"higher-match-on-last-page": {
+			PerPage:    100,
+			Constraint: ">= 1.0",
+			Expected:   "v10.0.0",
+			Requests:   3,
+		},
+		"constraint-on-last-page": {
+			PerPage:    100,
+			Constraint: ">= 2.0, < 3.0",
+			Expected:   "v2.0.0",
+			Requests:   3,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := newTagServer(t, tags, tc.PerPage, &requests)
+			defer server.Close()
+
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := GetLatestTagName(client, "octocat", "hello", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+
+			if requests.Load() != tc.Requests {
+				t.Errorf("requests: got %d; want %d", requests.Load(), tc.Requests)
+			}
+		})
+	}
+}
+
+func TestGetLatestTagVersion(t *testing.T) {
+	tags := []string{"v1.9.0", "v1.10.0", "v2.0.0-rc.1", "nightly"}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Expected   string
+		Error      bool
+	}{
+		"latest": {
+			Expected: "1.10.0",
+		},
+		"constraint": {
+			Constraint: "< 1.10",
+			Expected:   "1.9.0",
+		},
+		"no-match": {
+			Constraint: ">= 3.0",
+			Error:      true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := newTagServer(t, tags, 100, &requests) // lint:allow_raw_number
+			defer server.Close()
+
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ver, err := GetLatestTag(client, "octocat", "hello", tc.Constraint)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %s", ver)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if ver.String() != tc.Expected {
+				t.Errorf("got %q; want %q", ver.String(), tc.Expected)
+			}
+		})
+	}
+}
+
+func TestProviderChannels(t *testing.T) {
+	releases := []string{
+		`{"tag_name": "v2.0.0", "draft": true}`,
+		`{"tag_name": "v1.3.0-rc.1", "prerelease": true}`,
+		`{"tag_name": "v1.2.0"}`,
+		`{"tag_name": "v1.1.0"}`,
+	}
+
+	mux := http.NewServeMux()
+
+	var server *httptest.Server
+
+	mux.HandleFunc("GET /api/v3/repos/octocat/hello/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.2.0"}`)
+	})
+
+	mux.HandleFunc("GET /api/v3/repos/octocat/hello/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		// Drafts are not returned by tag.
+		if r.PathValue("tag") == "v2.0.0" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"tag_name": %q}`, r.PathValue("tag"))
+	})
+
+	// Two releases per page.
+	mux.HandleFunc("GET /api/v3/repos/octocat/hello/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprintf(w, "[%s]", strings.Join(releases[2:], ","))
+
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next"`, server.URL, r.URL.Path))
+		fmt.Fprintf(w, "[%s]", strings.Join(releases[:2], ","))
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Channel  provider.Channel
+		Latest   string
+		DraftErr bool
+	}{
+		"stable": {
+			Latest:   "v1.2.0",
+			DraftErr: true,
+		},
+		"prerelease": {
+			Channel:  provider.ChannelPrerelease,
+			Latest:   "v1.3.0-rc.1",
+			DraftErr: true,
+		},
+		"any": {
+			Channel: provider.ChannelAny,
+			Latest:  "v2.0.0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := NewProvider(client, provider.Settings{Channel: tc.Channel}, false)
+
+			release, err := p.GetLatestRelease("octocat", "hello")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if release.TagName != tc.Latest {
+				t.Errorf("got %q; want %q", release.TagName, tc.Latest)
+			}
+
+			draft, err := p.GetReleaseVersion("octocat", "hello", "v2.0.0")
+			if tc.DraftErr {
+				if err == nil {
+					t.Error("expected the draft to be hidden")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !draft.Draft {
+				t.Error("expected a draft release")
+			}
+		})
+	}
+}
+
+func TestProviderTagFormat(t *testing.T) {
+	tags := []string{
+		"cli/v2.0.0", "cli/v2.1.0", "cli/v2.2.0-rc.1",
+		"sdk/v3.0.0", "go1.22.1", "go1.9", "v9.0.0",
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Prefix   string
+		Regex    string
+		Expected string
+	}{
+		"prefix": {
+			Prefix:   "cli/",
+			Expected: "cli/v2.1.0",
+		},
+		"regex": {
+			Regex:    `^sdk/v(?P<version>.+)$`,
+			Expected: "sdk/v3.0.0",
+		},
+		"go": {
+			Prefix:   "go",
+			Expected: "go1.22.1",
+		},
+		"plain": {
+			Expected: "v9.0.0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := newTagServer(t, tags, 3, &requests)
+			defer server.Close()
+
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			format, err := provider.NewTagFormat(tc.Prefix, tc.Regex)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := NewProvider(client, provider.Settings{TagFormat: format}, false).GetLatestTag("octocat", "hello", "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestProviderCommitDate(t *testing.T) {
+	pages := []string{
+		`{"data": {"repository": {"refs": {
+			"pageInfo": {"hasNextPage": true, "endCursor": "abc"},
+			"nodes": [
+				{"name": "nightly", "target": {"committedDate": "2024-10-18T01:00:00Z"}},
+				{"name": "build-1", "target": {"committedDate": "2024-10-16T01:00:00Z"}}
+			]
+		}}}}`,
+		`{"data": {"repository": {"refs": {
+			"pageInfo": {"hasNextPage": false, "endCursor": "def"},
+			"nodes": [
+				{"name": "build-2", "target": {"target": {"committedDate": "2024-10-17T01:00:00Z"}}}
+			]
+		}}}}`,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		body := graphQLRequest{}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+
+			return
+		}
+
+		if body.Variables["owner"] != "octocat" || body.Variables["repo"] != "hello" {
+			t.Errorf("unexpected variables: %v", body.Variables)
+		}
+
+		if body.Variables["after"] == "abc" {
+			fmt.Fprint(w, pages[1])
+
+			return
+		}
+
+		fmt.Fprint(w, pages[0])
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Expected   string
+	}{
+		"latest": {
+			Expected: "nightly",
+		},
+		"annotated-tag": {
+			Constraint: "< 2024-10-18",
+			Expected:   "build-2",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewProvider(client, provider.Settings{Scheme: provider.SchemeCommitDate}, false)
+
+			tag, err := p.GetLatestTag("octocat", "hello", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Endpoint string
+		Expected string
+	}{
+		"github.com": {
+			Expected: "https://api.github.com/graphql",
+		},
+		"enterprise": {
+			Endpoint: "https://github.example.com",
+			Expected: "https://github.example.com/api/graphql",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: tc.Endpoint})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := graphQLURL(client); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
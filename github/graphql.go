@@ -0,0 +1,138 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v60/github"
+	"github.com/mailgun/errors"
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// tagDatesQuery lists tags along with the date of the commit they point to. The
+// REST API would need one more request per tag for that. Annotated tags point to
+// a tag object, which in turn points to the commit.
+const tagDatesQuery = `query($owner: String!, $repo: String!, $after: String) {
+  repository(owner: $owner, name: $repo) {
+    refs(refPrefix: "refs/tags/", first: 100, after: $after) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        target {
+          ... on Commit { committedDate }
+          ... on Tag { target { ... on Commit { committedDate } } }
+        }
+      }
+    }
+  }
+}`
+
+type (
+	graphQLRequest struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+
+	tagDatesResponse struct {
+		Data struct {
+			Repository *struct {
+				Refs struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []struct {
+						Name   string `json:"name"`
+						Target struct {
+							CommittedDate time.Time `json:"committedDate"`
+							Target        struct {
+								CommittedDate time.Time `json:"committedDate"`
+							} `json:"target"`
+						} `json:"target"`
+					} `json:"nodes"`
+				} `json:"refs"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+)
+
+// ListTagsByDate returns every tag in the repository, along with the date of the
+// commit it points to. It uses the GraphQL API, which requires a token.
+func ListTagsByDate(client *gh.Client, owner, repo string) ([]provider.Tag, error) {
+	tags := []provider.Tag{}
+	variables := map[string]any{"owner": owner, "repo": repo}
+
+	for {
+		req, err := client.NewRequest(http.MethodPost, graphQLURL(client), &graphQLRequest{
+			Query:     tagDatesQuery,
+			Variables: variables,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create GraphQL request")
+		}
+
+		resp := tagDatesResponse{}
+
+		if _, err := client.Do(ctx, req, &resp); err != nil {
+			return nil, errors.Wrap(err, "failed to list tags with their commit dates")
+		}
+
+		if len(resp.Errors) > 0 {
+			return nil, errors.Errorf("failed to list tags with their commit dates: %s", resp.Errors[0].Message)
+		}
+
+		if resp.Data.Repository == nil {
+			return nil, errors.Errorf("repository not found: %s/%s", owner, repo)
+		}
+
+		refs := resp.Data.Repository.Refs
+
+		for i := range refs.Nodes {
+			date := refs.Nodes[i].Target.CommittedDate
+			if date.IsZero() {
+				date = refs.Nodes[i].Target.Target.CommittedDate
+			}
+
+			tags = append(tags, provider.Tag{Name: refs.Nodes[i].Name, Date: date})
+		}
+
+		if !refs.PageInfo.HasNextPage {
+			return tags, nil
+		}
+
+		variables["after"] = refs.PageInfo.EndCursor
+	}
+}
+
+// graphQLURL returns the GraphQL endpoint which goes with the client's REST
+// endpoint. GitHub Enterprise Server serves it from `/api/graphql` rather than
+// `/api/v3/graphql`.
+func graphQLURL(client *gh.Client) string {
+	base := *client.BaseURL
+
+	if path, ok := strings.CutSuffix(base.Path, "/api/v3/"); ok {
+		base.Path = path + "/api/graphql"
+
+		return base.String()
+	}
+
+	return base.JoinPath("graphql").String()
+}
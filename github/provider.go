@@ -0,0 +1,183 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"io"
+	"net/http"
+
+	gh "github.com/google/go-github/v60/github"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+// Provider implements provider.Provider for GitHub and GitHub Enterprise Server.
+type Provider struct {
+	client   *gh.Client
+	settings provider.Settings
+
+	// downloadClient follows the redirect from the API to the asset CDN. It must
+	// not send the API token along, because the CDN URL is already signed.
+	downloadClient *http.Client
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// NewProvider wraps a client created with NewClient. Set noWait to fail
+// downloads immediately when a rate limit is hit, as with NewClientInput.
+func NewProvider(client *gh.Client, settings provider.Settings, noWait bool) *Provider {
+	return &Provider{
+		client:   client,
+		settings: settings,
+		downloadClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, noWait),
+		},
+	}
+}
+
+// GetLatestRelease returns the release which GitHub has marked as the latest. If
+// the channel allows pre-releases, or only some tags hold versions, it instead
+// returns the release in the channel with the highest version.
+func (p *Provider) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	if !p.settings.LatestByVersion() {
+		release, err := GetLatestRelease(p.client, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		return toRelease(release), nil
+	}
+
+	releases, err := p.listReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.settings.LatestRelease(releases)
+}
+
+// GetReleaseVersion returns the release for a tag. Draft releases cannot be
+// looked up by tag, so they are found in the list of releases instead.
+func (p *Provider) GetReleaseVersion(owner, repo, tag string) (*provider.Release, error) {
+	release, err := GetReleaseVersion(p.client, owner, repo, tag)
+	if err == nil {
+		return toRelease(release), nil
+	}
+
+	if !p.settings.Channel.AllowsDrafts() {
+		return nil, err
+	}
+
+	releases, listErr := p.listReleases(owner, repo)
+	if listErr != nil {
+		return nil, err
+	}
+
+	for i := range releases {
+		if releases[i].Draft && releases[i].TagName == tag {
+			return releases[i], nil
+		}
+	}
+
+	return nil, err
+}
+
+// ListReleases returns every release in the repository. Drafts are included
+// when the token is allowed to see them.
+func (p *Provider) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	return p.listReleases(owner, repo)
+}
+
+func (p *Provider) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := p.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return p.settings.LatestTag(tags, constraint)
+}
+
+// ListTags returns every tag in the repository. Their commit dates are only
+// looked up for the commit-date version scheme, since that takes the GraphQL API.
+func (p *Provider) ListTags(owner, repo string) ([]provider.Tag, error) {
+	if p.settings.Scheme == provider.SchemeCommitDate {
+		return ListTagsByDate(p.client, owner, repo)
+	}
+
+	return ListTags(p.client, owner, repo)
+}
+
+func (p *Provider) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	asset, err := p.settings.MatchAsset(release.Assets, pattern)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rc, _, err := p.client.Repositories.DownloadReleaseAsset(
+		ctx,
+		ownerRepo[0],
+		ownerRepo[1],
+		asset.ID,
+		p.downloadClient,
+	)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to download release asset")
+	}
+
+	return rc, asset.Name, nil
+}
+
+func (p *Provider) listReleases(owner, repo string) ([]*provider.Release, error) {
+	releases, err := ListReleases(p.client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*provider.Release, 0, len(releases))
+	for i := range releases {
+		out = append(out, toRelease(releases[i]))
+	}
+
+	return out, nil
+}
+
+// toRelease converts a GitHub release into the provider-agnostic type.
+func toRelease(release *gh.RepositoryRelease) *provider.Release {
+	r := &provider.Release{
+		TagName:     release.GetTagName(),
+		Name:        release.GetName(),
+		Body:        release.GetBody(),
+		Prerelease:  release.GetPrerelease(),
+		Draft:       release.GetDraft(),
+		PublishedAt: release.GetPublishedAt().Time,
+		Assets:      make([]*provider.Asset, 0, len(release.Assets)),
+	}
+
+	for i := range release.Assets {
+		asset := release.Assets[i]
+
+		r.Assets = append(r.Assets, &provider.Asset{
+			ID:   asset.GetID(),
+			Name: asset.GetName(),
+			URL:  asset.GetBrowserDownloadURL(),
+		})
+	}
+
+	return r
+}
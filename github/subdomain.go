@@ -30,6 +30,12 @@ func ParseDomain(domain string) ( // lint:allow_named_returns
 		return "", "", false
 	}
 
+	// The canonicalizer always strips the port, but GitHub Enterprise Server may
+	// be running on a non-standard one.
+	if withPort, err := canonicalizer.WhatWg.Parse(domain); err == nil {
+		url.SetPort(withPort.Port())
+	}
+
 	// Remove.
 	url.SetHash("")
 	url.SetPassword("")
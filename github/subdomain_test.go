@@ -98,6 +98,24 @@ func TestSubdomain(t *testing.T) {
 			UploadEndpoint:     "https://uploads.github.prod.company.com",
 			SubdomainIsolation: true,
 		},
+		"https-github.company.com-port": {
+			InputURL:           "https://github.company.com:8443",
+			APIEndpoint:        "https://github.company.com:8443/api/v3",
+			UploadEndpoint:     "https://github.company.com:8443/uploads",
+			SubdomainIsolation: false,
+		},
+		"https-api.github.company.com-port": {
+			InputURL:           "https://api.github.company.com:8443",
+			APIEndpoint:        "https://api.github.company.com:8443",
+			UploadEndpoint:     "https://uploads.github.company.com:8443",
+			SubdomainIsolation: true,
+		},
+		"https-github.company.com-default-port": {
+			InputURL:           "https://github.company.com:443",
+			APIEndpoint:        "https://github.company.com/api/v3",
+			UploadEndpoint:     "https://github.company.com/uploads",
+			SubdomainIsolation: false,
+		},
 	}
 
 	for name, tc := range tests {
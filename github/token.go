@@ -0,0 +1,156 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"go.yaml.in/yaml/v3"
+)
+
+const publicHost = "github.com"
+
+// LookupToken finds a token for github.com or the GitHub Enterprise Server host
+// behind endpoint. It checks, in order:
+//
+//  1. GITHUB_TOKEN and GH_TOKEN for github.com, or GH_ENTERPRISE_TOKEN,
+//     GITHUB_ENTERPRISE_TOKEN and GITHUB_TOKEN for any other host.
+//  2. hostTokens.
+//  3. ~/.netrc.
+//  4. The `oauth_token` for the host in the `gh` CLI's hosts.yml.
+func LookupToken(endpoint string, hostTokens map[string]string) (token, source string) { // lint:allow_named_returns
+	hosts := tokenHosts(endpoint)
+
+	envVars := []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	if hosts[0] != publicHost {
+		envVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN", "GITHUB_TOKEN"}
+	}
+
+	for i := range envVars {
+		if v := os.Getenv(envVars[i]); v != "" {
+			return v, "$" + envVars[i]
+		}
+	}
+
+	for i := range hosts {
+		if v := hostTokens[hosts[i]]; v != "" {
+			return v, "config file (" + hosts[i] + ")"
+		}
+	}
+
+	if v, path := provider.NetrcToken(hosts...); v != "" {
+		return v, path
+	}
+
+	if v, path := ghCLIToken(hosts...); v != "" {
+		return v, path
+	}
+
+	return "", ""
+}
+
+// MaskToken returns a version of the token which is safe to display.
+func MaskToken(token string) string {
+	const visible = 8
+
+	if len(token) <= visible {
+		return strings.Repeat(".", len(token))
+	}
+
+	return token[0:visible] + strings.Repeat(".", 33) // lint:allow_raw_number
+}
+
+// tokenHosts returns the hostnames which a token for endpoint may be stored
+// under. The web hostname (e.g., github.com) is always first, followed by the
+// API hostname if it is different (e.g., api.github.com).
+func tokenHosts(endpoint string) []string {
+	if endpoint == "" {
+		return []string{publicHost, "api." + publicHost}
+	}
+
+	apiEndpoint, _, subdomainIsolation := ParseDomain(endpoint)
+
+	u, err := url.Parse(apiEndpoint)
+	if err != nil || u.Hostname() == "" {
+		return []string{publicHost, "api." + publicHost}
+	}
+
+	host := u.Hostname()
+
+	if subdomainIsolation {
+		return []string{strings.TrimPrefix(host, "api."), host}
+	}
+
+	return []string{host}
+}
+
+// ghCLIToken reads the `oauth_token` for the first matching host from the `gh`
+// CLI's hosts.yml. Tokens stored in the system keyring are not visible here.
+func ghCLIToken(hosts ...string) (token, path string) { // lint:allow_named_returns
+	path = ghCLIHostsFile()
+	if path == "" {
+		return "", ""
+	}
+
+	b, err := os.ReadFile(path) // lint:allow_include_file
+	if err != nil {
+		return "", ""
+	}
+
+	config := map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}{}
+
+	err = yaml.Unmarshal(b, &config)
+	if err != nil {
+		return "", ""
+	}
+
+	for i := range hosts {
+		if v := config[hosts[i]].OAuthToken; v != "" {
+			return v, path
+		}
+	}
+
+	return "", ""
+}
+
+func ghCLIHostsFile() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
@@ -0,0 +1,159 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testNetrc = `machine api.github.com
+  login octocat
+  password netrc-public
+
+macdef init
+machine github.company.com password not-this-one
+
+machine github.company.com login octocat password netrc-enterprise
+default login anonymous password not-this-either
+`
+
+	testHostsYml = `github.com:
+    user: octocat
+    oauth_token: gh-public
+    git_protocol: https
+ghe.company.com:
+    user: octocat
+    oauth_token: gh-enterprise
+`
+)
+
+func TestLookupToken(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Endpoint       string
+		Env            map[string]string
+		HostTokens     map[string]string
+		ExpectedToken  string
+		ExpectedSource string
+	}{
+		"github-token": {
+			Endpoint:       "https://api.github.com",
+			Env:            map[string]string{"GITHUB_TOKEN": "env-github", "GH_TOKEN": "env-gh"},
+			ExpectedToken:  "env-github",
+			ExpectedSource: "$GITHUB_TOKEN",
+		},
+		"gh-token": {
+			Endpoint:       "https://api.github.com",
+			Env:            map[string]string{"GH_TOKEN": "env-gh", "GH_ENTERPRISE_TOKEN": "env-enterprise"},
+			ExpectedToken:  "env-gh",
+			ExpectedSource: "$GH_TOKEN",
+		},
+		"enterprise-token": {
+			Endpoint:       "github.company.com",
+			Env:            map[string]string{"GITHUB_TOKEN": "env-github", "GH_ENTERPRISE_TOKEN": "env-enterprise"},
+			ExpectedToken:  "env-enterprise",
+			ExpectedSource: "$GH_ENTERPRISE_TOKEN",
+		},
+		"enterprise-falls-back-to-github-token": {
+			Endpoint:       "github.company.com",
+			Env:            map[string]string{"GITHUB_TOKEN": "env-github"},
+			ExpectedToken:  "env-github",
+			ExpectedSource: "$GITHUB_TOKEN",
+		},
+		"config": {
+			Endpoint:       "api.ghe.company.com",
+			HostTokens:     map[string]string{"ghe.company.com": "config-enterprise"},
+			ExpectedToken:  "config-enterprise",
+			ExpectedSource: "config file (ghe.company.com)",
+		},
+		"netrc-public": {
+			Endpoint:      "https://api.github.com",
+			ExpectedToken: "netrc-public",
+		},
+		"netrc-enterprise": {
+			Endpoint:      "https://github.company.com",
+			ExpectedToken: "netrc-enterprise",
+		},
+		"gh-cli": {
+			Endpoint:      "https://api.ghe.company.com",
+			ExpectedToken: "gh-enterprise",
+		},
+		"anonymous": {
+			Endpoint:      "https://github.example.com",
+			ExpectedToken: "",
+		},
+	}
+
+	dir := t.TempDir()
+	netrc := filepath.Join(dir, "netrc")
+	hostsYml := filepath.Join(dir, "hosts.yml")
+
+	if err := os.WriteFile(netrc, []byte(testNetrc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(hostsYml, []byte(testHostsYml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, k := range []string{"GITHUB_TOKEN", "GH_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+				t.Setenv(k, tc.Env[k])
+			}
+
+			t.Setenv("NETRC", netrc)
+			t.Setenv("GH_CONFIG_DIR", dir)
+
+			token, source := LookupToken(tc.Endpoint, tc.HostTokens)
+
+			if token != tc.ExpectedToken {
+				t.Errorf("token: got %q; want %q", token, tc.ExpectedToken)
+			}
+
+			if tc.ExpectedSource != "" && source != tc.ExpectedSource {
+				t.Errorf("source: got %q; want %q", source, tc.ExpectedSource)
+			}
+		})
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected string
+	}{
+		"short": {
+			Input:    "abc",
+			Expected: "...",
+		},
+		"long": {
+			Input:    "ghp_1234567890",
+			Expected: "ghp_1234.................................",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := MaskToken(tc.Input)
+
+			if actual != tc.Expected {
+				t.Errorf("got %q; want %q", actual, tc.Expected)
+			}
+		})
+	}
+}
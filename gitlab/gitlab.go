@@ -0,0 +1,451 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package gitlab provides a library for downloading release assets from GitLab
+(gitlab.com or a self-managed instance).
+*/
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEndpoint is used when no endpoint has been provided.
+	DefaultEndpoint = "https://gitlab.com"
+
+	apiPath = "/api/v4"
+	perPage = "100"
+
+	// maxRedirects matches the limit of Go's default HTTP client.
+	maxRedirects = 10
+)
+
+type (
+	NewClientInput struct {
+		Endpoint string
+		Token    string
+
+		// JobToken sends Token as a CI/CD job token instead of a personal,
+		// project or group access token.
+		JobToken bool
+
+		// Settings choose between the project's releases and tags.
+		Settings provider.Settings
+
+		// NoWait fails on GitLab's RateLimit-* limits instead of waiting for them
+		// to reset.
+		NoWait bool
+	}
+
+	// Client talks to the GitLab REST API, and implements provider.Provider.
+	Client struct {
+		apiEndpoint string
+		host        string
+		token       string
+		jobToken    bool
+		httpClient  *http.Client
+		settings    provider.Settings
+	}
+
+	release struct {
+		TagName     string    `json:"tag_name"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		ReleasedAt  time.Time `json:"released_at"`
+		Assets      struct {
+			Links []struct {
+				ID             int64  `json:"id"`
+				Name           string `json:"name"`
+				URL            string `json:"url"`
+				DirectAssetURL string `json:"direct_asset_url"`
+			} `json:"links"`
+		} `json:"assets"`
+	}
+
+	tag struct {
+		Name   string `json:"name"`
+		Commit struct {
+			CommittedDate time.Time `json:"committed_date"`
+		} `json:"commit"`
+	}
+
+	genericPackage struct {
+		ID      int64  `json:"id"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	packageFile struct {
+		ID       int64  `json:"id"`
+		FileName string `json:"file_name"`
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	_ provider.Provider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	apiEndpoint := APIEndpoint(input.Endpoint)
+
+	u, err := url.Parse(apiEndpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid GitLab endpoint: %s", input.Endpoint)
+	}
+
+	c := &Client{
+		apiEndpoint: apiEndpoint,
+		host:        u.Host,
+		token:       input.Token,
+		jobToken:    input.JobToken,
+		settings:    input.Settings,
+	}
+
+	c.httpClient = &http.Client{
+		Transport:     provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		CheckRedirect: c.checkRedirect,
+	}
+
+	return c, nil
+}
+
+// checkRedirect stops credentials from following a redirect to another host
+// (e.g., object storage or a CDN). Go only drops the Authorization and Cookie
+// headers itself, not GitLab's own token headers.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	if req.URL.Host != c.host {
+		req.Header.Del("JOB-TOKEN")
+		req.Header.Del("PRIVATE-TOKEN")
+		req.Header.Del("Authorization")
+	}
+
+	return nil
+}
+
+// APIEndpoint normalizes an endpoint (e.g., `gitlab.company.com`) into the URL
+// of its REST API (e.g., `https://gitlab.company.com/api/v4`).
+func APIEndpoint(endpoint string) string {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	if !strings.HasSuffix(endpoint, apiPath) {
+		endpoint += apiPath
+	}
+
+	return endpoint
+}
+
+// GetLatestRelease returns the release which GitLab considers to be the latest.
+// If the channel allows pre-releases, or only some tags hold versions, it
+// instead returns the release in the channel with the highest version.
+func (c *Client) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	if !c.settings.LatestByVersion() {
+		r := release{}
+
+		_, err := c.getJSON(c.projectURL(owner, repo, "releases/permalink/latest"), &r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get latest release")
+		}
+
+		return c.toRelease(owner, repo, &r), nil
+	}
+
+	// Packages are only looked up for the release which is chosen.
+	releases, err := c.ListReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := c.settings.LatestRelease(releases)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetReleaseVersion(owner, repo, latest.TagName)
+}
+
+func (c *Client) GetReleaseVersion(owner, repo, tagName string) (*provider.Release, error) {
+	r := release{}
+
+	_, err := c.getJSON(c.projectURL(owner, repo, "releases/"+escapePath(tagName)), &r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get release by tag")
+	}
+
+	return c.toRelease(owner, repo, &r), nil
+}
+
+// ListReleases returns every release in the project. Their assets are the
+// release links; files in the generic package registry would take two more
+// requests per release, so they are only looked up for a single release.
+func (c *Client) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	releases := []*provider.Release{}
+	next := c.projectURL(owner, repo, "releases") + "?per_page=" + perPage
+
+	for next != "" {
+		page := []release{}
+
+		resp, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list releases")
+		}
+
+		for i := range page {
+			releases = append(releases, newRelease(&page[i], c.settings.TagFormat))
+		}
+
+		next = ""
+
+		if nextPage := resp.Header.Get("X-Next-Page"); nextPage != "" {
+			next = c.projectURL(owner, repo, "releases") + "?per_page=" + perPage + "&page=" + nextPage
+		}
+	}
+
+	return releases, nil
+}
+
+func (c *Client) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+func (c *Client) ListTags(owner, repo string) ([]provider.Tag, error) {
+	tags := []provider.Tag{}
+	next := c.projectURL(owner, repo, "repository/tags") + "?per_page=" + perPage
+
+	for next != "" {
+		page := []tag{}
+
+		resp, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
+		}
+
+		for i := range page {
+			tags = append(tags, provider.Tag{Name: page[i].Name, Date: page[i].Commit.CommittedDate})
+		}
+
+		next = ""
+
+		if nextPage := resp.Header.Get("X-Next-Page"); nextPage != "" {
+			next = c.projectURL(owner, repo, "repository/tags") + "?per_page=" + perPage + "&page=" + nextPage
+		}
+	}
+
+	return tags, nil
+}
+
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	asset, err := c.settings.MatchAsset(release.Assets, pattern)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.get(asset.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to download %s", asset.Name)
+	}
+
+	return resp.Body, asset.Name, nil
+}
+
+// toRelease converts a GitLab release into the provider-agnostic type. Release
+// links come first, followed by any files in the generic package registry which
+// were published under the same version.
+func (c *Client) toRelease(owner, repo string, r *release) *provider.Release {
+	rel := newRelease(r, c.settings.TagFormat)
+	rel.Assets = append(rel.Assets, c.genericPackageAssets(owner, repo, r.TagName)...)
+
+	return rel
+}
+
+// newRelease converts a GitLab release into the provider-agnostic type, with
+// only its release links as assets. The tag format finds the version in the tag.
+func newRelease(r *release, format provider.TagFormat) *provider.Release {
+	rel := &provider.Release{
+		TagName:     r.TagName,
+		Name:        r.Name,
+		Body:        r.Description,
+		Prerelease:  format.IsPrerelease(r.TagName),
+		PublishedAt: r.ReleasedAt,
+		Assets:      []*provider.Asset{},
+	}
+
+	for i := range r.Assets.Links {
+		link := r.Assets.Links[i]
+
+		assetURL := link.DirectAssetURL
+		if assetURL == "" {
+			assetURL = link.URL
+		}
+
+		rel.Assets = append(rel.Assets, &provider.Asset{
+			ID:   link.ID,
+			Name: link.Name,
+			URL:  assetURL,
+		})
+	}
+
+	return rel
+}
+
+// genericPackageAssets lists the files in the generic package registry which
+// were published with the same version as the tag (with or without the `v`).
+// The registry is optional, so errors are treated as "no files".
+func (c *Client) genericPackageAssets(owner, repo, tagName string) []*provider.Asset {
+	assets := []*provider.Asset{}
+	versions := []string{tagName}
+
+	if trimmed := strings.TrimPrefix(tagName, "v"); trimmed != tagName {
+		versions = append(versions, trimmed)
+	}
+
+	for _, ver := range versions {
+		packages := []genericPackage{}
+		query := url.Values{
+			"package_type":    []string{"generic"},
+			"package_version": []string{ver},
+			"per_page":        []string{perPage},
+		}
+
+		_, err := c.getJSON(c.projectURL(owner, repo, "packages")+"?"+query.Encode(), &packages)
+		if err != nil {
+			return assets
+		}
+
+		for i := range packages {
+			pkg := packages[i]
+			files := []packageFile{}
+
+			_, err := c.getJSON(
+				c.projectURL(owner, repo, fmt.Sprintf("packages/%d/package_files?per_page=%s", pkg.ID, perPage)),
+				&files,
+			)
+			if err != nil {
+				continue
+			}
+
+			for j := range files {
+				assets = append(assets, &provider.Asset{
+					ID:   files[j].ID,
+					Name: files[j].FileName,
+					URL: c.projectURL(owner, repo, strings.Join([]string{
+						"packages/generic",
+						escapePath(pkg.Name),
+						escapePath(pkg.Version),
+						escapePath(files[j].FileName),
+					}, "/")),
+				})
+			}
+		}
+	}
+
+	return assets
+}
+
+// projectURL builds an API URL for a project. GitLab identifies projects by
+// their URL-encoded path, including the slash.
+func (c *Client) projectURL(owner, repo, path string) string {
+	return c.apiEndpoint + "/projects/" + escapePath(owner+"/"+repo) + "/" + path
+}
+
+// escapePath escapes a value for use as a single path segment, including any
+// slashes inside it.
+func escapePath(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "/", "%2F")
+}
+
+func (c *Client) getJSON(rawURL string, v any) (*http.Response, error) {
+	resp, err := c.get(rawURL)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to decode the GitLab API response")
+	}
+
+	return resp, nil
+}
+
+// get performs a GET request. Credentials are only sent to the GitLab host
+// itself, never to third-party URLs which a release may link to.
+func (c *Client) get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	if c.token != "" && req.URL.Host == c.host {
+		if c.jobToken {
+			req.Header.Set("JOB-TOKEN", c.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+
+		message := struct {
+			Message any `json:"message"`
+		}{}
+
+		_ = json.NewDecoder(resp.Body).Decode(&message) // lint:allow_unhandled
+
+		return nil, errors.Errorf("GET %s: %s (%v)", req.URL.Redacted(), resp.Status, message.Message)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,388 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+const testToken = "glpat-test"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	release := func(tag string) string {
+		return fmt.Sprintf(`{
+			"tag_name": %[1]q,
+			"name": "Release %[1]s",
+			"released_at": "2024-03-01T00:00:00Z",
+			"assets": {
+				"links": [
+					{"id": 1, "name": "tool_linux_amd64.tar.gz", "url": "%[2]s/downloads/tool_linux_amd64.tar.gz"},
+					{"id": 2, "name": "tool_darwin_arm64.tar.gz", "url": "https://example.com/x", "direct_asset_url": "%[2]s/downloads/tool_darwin_arm64.tar.gz"}
+				]
+			}
+		}`, tag, server.URL)
+	}
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/releases/permalink/latest", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "group/tool" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		fmt.Fprint(w, release("v1.2.0"))
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/releases/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, release(r.PathValue("tag")))
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/repository/tags", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"name": "v1.0.0"}, {"name": "v1.1.0"}, {"name": "not-a-version", "commit": {"committed_date": "2024-10-18T01:00:00Z"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"name": "v2.0.0", "commit": {"committed_date": "2024-10-17T01:00:00Z"}}, {"name": "v1.2.0"}]`)
+		}
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/packages", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("package_version") != "1.2.0" {
+			fmt.Fprint(w, `[]`)
+
+			return
+		}
+
+		fmt.Fprint(w, `[{"id": 7, "name": "tool", "version": "1.2.0"}]`)
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/packages/7/package_files", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 70, "file_name": "tool_windows_amd64.zip"}]`)
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{id}/packages/generic/tool/1.2.0/{file}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+
+			return
+		}
+
+		fmt.Fprint(w, "package:"+r.PathValue("file"))
+	})
+
+	mux.HandleFunc("GET /downloads/{file}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "link:"+r.PathValue("file"))
+	})
+
+	return server
+}
+
+func TestGetLatestRelease(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL, Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := client.GetLatestRelease("group", "tool")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if release.TagName != "v1.2.0" {
+		t.Errorf("tag: got %q; want %q", release.TagName, "v1.2.0")
+	}
+
+	names := []string{}
+	for i := range release.Assets {
+		names = append(names, release.Assets[i].Name)
+	}
+
+	expected := []string{"tool_linux_amd64.tar.gz", "tool_darwin_arm64.tar.gz", "tool_windows_amd64.zip"}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("assets: got %v; want %v", names, expected)
+	}
+}
+
+func TestGetLatestTag(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Scheme     provider.Scheme
+		Constraint string
+		Expected   string
+	}{
+		"latest": {
+			Constraint: "",
+			Expected:   "v2.0.0",
+		},
+		"constrained": {
+			Constraint: "~> 1.1",
+			Expected:   "v1.2.0",
+		},
+		"commit-date": {
+			Scheme:   provider.SchemeCommitDate,
+			Expected: "not-a-version",
+		},
+		"commit-date-constrained": {
+			Scheme:     provider.SchemeCommitDate,
+			Constraint: "< 2024-10-18",
+			Expected:   "v2.0.0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				Endpoint: server.URL,
+				Settings: provider.Settings{Scheme: tc.Scheme},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := client.GetLatestTag("group", "tool", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetAssetStream(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Token    string
+		Pattern  string
+		Expected string
+		Error    bool
+	}{
+		"link": {
+			Pattern:  "linux_amd64",
+			Expected: "link:tool_linux_amd64.tar.gz",
+		},
+		"direct-asset-url": {
+			Pattern:  "darwin_arm64",
+			Expected: "link:tool_darwin_arm64.tar.gz",
+		},
+		"generic-package": {
+			Token:    testToken,
+			Pattern:  "windows_amd64",
+			Expected: "package:tool_windows_amd64.zip",
+		},
+		"generic-package-unauthorized": {
+			Pattern: "windows_amd64",
+			Error:   true,
+		},
+		"no-match": {
+			Pattern: "plan9",
+			Error:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL, Token: tc.Token})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			release, err := client.GetReleaseVersion("group", "tool", "v1.2.0")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stream, _, err := client.GetAssetStream([]string{"group", "tool"}, release, tc.Pattern)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stream.Close()
+
+			b, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tc.Expected {
+				t.Errorf("got %q; want %q", string(b), tc.Expected)
+			}
+		})
+	}
+}
+
+func TestNewReleasePrerelease(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Prefix   string
+		Tag      string
+		Expected bool
+	}{
+		"stable":        {Tag: "v1.2.0", Expected: false},
+		"rc":            {Tag: "v1.3.0-rc.1", Expected: true},
+		"not-version":   {Tag: "nightly", Expected: false},
+		"prefix-stable": {Prefix: "cli/", Tag: "cli/v2.0.0", Expected: false},
+		"prefix-rc":     {Prefix: "cli/", Tag: "cli/v2.0.0-rc.1", Expected: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			format := provider.TagFormat{Prefix: tc.Prefix}
+
+			if got := newRelease(&release{TagName: tc.Tag}, format).Prerelease; got != tc.Expected {
+				t.Errorf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestRedirectCredentials(t *testing.T) {
+	// The other server stands in for object storage or a CDN.
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s",
+			r.Header.Get("JOB-TOKEN"), r.Header.Get("PRIVATE-TOKEN"), r.Header.Get("Authorization"),
+		)
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("GET /cross-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/file", http.StatusFound)
+	})
+
+	mux.HandleFunc("GET /same-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file", http.StatusFound)
+	})
+
+	mux.HandleFunc("GET /file", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s",
+			r.Header.Get("JOB-TOKEN"), r.Header.Get("PRIVATE-TOKEN"), r.Header.Get("Authorization"),
+		)
+	})
+
+	var tests = map[string]struct { // lint:no_dupe
+		JobToken bool
+		Path     string
+		Expected string
+	}{
+		"job-token-cross-host": {
+			JobToken: true,
+			Path:     "/cross-host",
+			Expected: "||",
+		},
+		"access-token-cross-host": {
+			Path:     "/cross-host",
+			Expected: "||",
+		},
+		"job-token-same-host": {
+			JobToken: true,
+			Path:     "/same-host",
+			Expected: testToken + "||",
+		},
+		"access-token-same-host": {
+			Path:     "/same-host",
+			Expected: "||Bearer " + testToken,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL, Token: testToken, JobToken: tc.JobToken})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.get(server.URL + tc.Path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tc.Expected {
+				t.Errorf("got %q; want %q", string(b), tc.Expected)
+			}
+		})
+	}
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected string
+	}{
+		"empty": {
+			Input:    "",
+			Expected: "https://gitlab.com/api/v4",
+		},
+		"hostname": {
+			Input:    "gitlab.company.com",
+			Expected: "https://gitlab.company.com/api/v4",
+		},
+		"trailing-slash": {
+			Input:    "http://gitlab.company.com/",
+			Expected: "http://gitlab.company.com/api/v4",
+		},
+		"already-api": {
+			Input:    "https://gitlab.company.com/api/v4",
+			Expected: "https://gitlab.company.com/api/v4",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := APIEndpoint(tc.Input)
+
+			if actual != tc.Expected {
+				t.Errorf("got %q; want %q", actual, tc.Expected)
+			}
+		})
+	}
+}
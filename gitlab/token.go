@@ -0,0 +1,64 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// JobTokenSource is the source reported by LookupToken for a CI/CD job token.
+const JobTokenSource = "$CI_JOB_TOKEN"
+
+// LookupToken finds a token for the GitLab instance behind endpoint. It checks,
+// in order:
+//
+//  1. GITLAB_TOKEN and GL_TOKEN.
+//  2. hostTokens.
+//  3. ~/.netrc.
+//  4. CI_JOB_TOKEN, when running in a GitLab CI/CD job on the same instance.
+func LookupToken(endpoint string, hostTokens map[string]string) (token, source string) { // lint:allow_named_returns
+	for _, envVar := range []string{"GITLAB_TOKEN", "GL_TOKEN"} {
+		if v := os.Getenv(envVar); v != "" {
+			return v, "$" + envVar
+		}
+	}
+
+	u, err := url.Parse(APIEndpoint(endpoint))
+	if err != nil {
+		return "", ""
+	}
+
+	host := u.Hostname()
+
+	if v := hostTokens[host]; v != "" {
+		return v, "config file (" + host + ")"
+	}
+
+	if v, path := provider.NetrcToken(host); v != "" {
+		return v, path
+	}
+
+	if v := os.Getenv("CI_JOB_TOKEN"); v != "" {
+		if ci, err := url.Parse(os.Getenv("CI_SERVER_URL")); err == nil && strings.EqualFold(ci.Hostname(), host) {
+			return v, JobTokenSource
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,329 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package golang provides a library for installing Go toolchains from the go.dev
+download index.
+
+Go releases are not GitHub release assets, so the index is the source of both
+the versions and the archives. Versions are reported without the `go` prefix
+(e.g., `1.22.1`).
+*/
+package golang
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEndpoint is used when no endpoint has been provided.
+	DefaultEndpoint = "https://go.dev/dl/"
+
+	// Owner and Repo are the GitHub repository which the provider is inferred
+	// for.
+	Owner = "golang"
+	Repo  = "go"
+
+	versionPrefix = "go"
+	kindArchive   = "archive"
+)
+
+type (
+	NewClientInput struct {
+		// Endpoint is the URL of the download page, or of a mirror with the same
+		// layout.
+		Endpoint string
+
+		// OS and Arch select the archive (using GOOS/GOARCH names) when no
+		// pattern is given.
+		OS   string
+		Arch string
+
+		// Settings choose between Go releases (e.g., whether release candidates
+		// such as go1.22rc1 are allowed).
+		Settings provider.Settings
+
+		// NoWait gives up if go.dev (or the mirror) is rate-limiting requests.
+		NoWait bool
+	}
+
+	// Client reads the download index, and implements provider.Provider.
+	Client struct {
+		baseURL    string
+		os         string
+		arch       string
+		httpClient *http.Client
+		settings   provider.Settings
+		releases   []goRelease
+	}
+
+	goRelease struct {
+		Version string   `json:"version"`
+		Stable  bool     `json:"stable"`
+		Files   []goFile `json:"files"`
+	}
+
+	goFile struct {
+		Filename string `json:"filename"`
+		SHA256   string `json:"sha256"`
+		Kind     string `json:"kind"`
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	_ provider.Provider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	endpoint := input.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid Go download endpoint: %s", input.Endpoint)
+	}
+
+	return &Client{
+		baseURL:  strings.TrimRight(endpoint, "/") + "/",
+		os:       input.OS,
+		arch:     input.Arch,
+		settings: input.Settings,
+		httpClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		},
+	}, nil
+}
+
+// BaseURL returns the URL of the download page.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// GetLatestRelease returns the latest release in the channel.
+func (c *Client) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	tag, err := c.GetLatestTag(owner, repo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetReleaseVersion(owner, repo, tag)
+}
+
+// GetReleaseVersion returns a specific release. The tag may be written with or
+// without the `go` prefix, and in either Go's form (`1.22rc1`) or semver form
+// (`1.22.0-rc1`).
+func (c *Client) GetReleaseVersion(owner, repo, tagName string) (*provider.Release, error) {
+	want, err := version.NewVersion(strings.TrimPrefix(tagName, versionPrefix))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid Go version: %s", tagName)
+	}
+
+	releases, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range releases {
+		r := releases[i]
+
+		ver, err := version.NewVersion(strings.TrimPrefix(r.Version, versionPrefix))
+		if err != nil || !ver.Equal(want) {
+			continue
+		}
+
+		return c.toRelease(&r), nil
+	}
+
+	return nil, errors.Errorf("Go %s was not found in the download index", tagName)
+}
+
+// ListReleases returns every release of Go in the download index.
+func (c *Client) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	releases, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*provider.Release, 0, len(releases))
+
+	for i := range releases {
+		out = append(out, c.toRelease(&releases[i]))
+	}
+
+	return out, nil
+}
+
+// GetLatestTag returns the highest version which satisfies the constraint.
+// Unstable releases (betas and release candidates) are pre-releases, so they are
+// only chosen if the channel allows them.
+func (c *Client) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+// ListTags returns every version of Go, without the `go` prefix.
+func (c *Client) ListTags(owner, repo string) ([]provider.Tag, error) {
+	releases, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(releases))
+
+	for i := range releases {
+		tags = append(tags, strings.TrimPrefix(releases[i].Version, versionPrefix))
+	}
+
+	return provider.NewTags(tags), nil
+}
+
+// GetAssetStream downloads the archive whose filename matches the pattern, or
+// the archive for the client's platform if the pattern is empty. The stream
+// fails when read to the end if it does not match the published SHA-256.
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	var asset *provider.Asset
+
+	if pattern != "" {
+		asset, err = c.settings.MatchAsset(release.Assets, pattern)
+	} else {
+		asset, err = c.platformAsset(release)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.get(asset.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to download %s", asset.Name)
+	}
+
+	return provider.VerifyChecksumCloser(resp.Body, asset.Checksum), asset.Name, nil
+}
+
+// platformAsset finds the archive for the client's platform, which is named
+// `go<version>.<os>-<arch>.<ext>`. 32-bit ARM builds are named `armv6l`.
+func (c *Client) platformAsset(release *provider.Release) (*provider.Asset, error) {
+	arch := c.arch
+	if arch == "arm" {
+		arch = "armv6l"
+	}
+
+	prefix := versionPrefix + release.TagName + "." + c.os + "-" + arch + "."
+
+	for i := range release.Assets {
+		if strings.HasPrefix(release.Assets[i].Name, prefix) {
+			return release.Assets[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("no %s/%s archive of Go %s", c.os, c.arch, release.TagName)
+}
+
+// index downloads (once) the list of every Go release.
+// toRelease converts a release from the download index into the
+// provider-agnostic type. Its assets are the archives; installers and source
+// code are skipped.
+func (c *Client) toRelease(r *goRelease) *provider.Release {
+	rel := &provider.Release{
+		TagName:    strings.TrimPrefix(r.Version, versionPrefix),
+		Name:       r.Version,
+		Prerelease: !r.Stable,
+		Assets:     []*provider.Asset{},
+	}
+
+	for i := range r.Files {
+		f := r.Files[i]
+
+		if f.Kind != kindArchive {
+			continue
+		}
+
+		rel.Assets = append(rel.Assets, &provider.Asset{
+			Name:     f.Filename,
+			URL:      c.baseURL + url.PathEscape(f.Filename),
+			Checksum: "sha256:" + f.SHA256,
+		})
+	}
+
+	return rel
+}
+
+func (c *Client) index() ([]goRelease, error) {
+	if c.releases != nil {
+		return c.releases, nil
+	}
+
+	resp, err := c.get(c.baseURL + "?mode=json&include=all")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download the Go release index")
+	}
+	defer resp.Body.Close()
+
+	releases := []goRelease{}
+
+	err = json.NewDecoder(resp.Body).Decode(&releases)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the Go release index")
+	}
+
+	c.releases = releases
+
+	return releases, nil
+}
+
+func (c *Client) get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+
+		return nil, errors.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,427 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+var testVersions = map[string]bool{
+	"go1.21.8":  true,
+	"go1.22.1":  true,
+	"go1.22.0":  true,
+	"go1.23rc1": false,
+}
+
+// testArchive builds a tar.gz with the layout of a Go release.
+func testArchive(t *testing.T, extra ...*tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	g := gzip.NewWriter(&buf)
+	w := tar.NewWriter(g)
+
+	files := []struct {
+		Name, Body string
+	}{
+		{"go/VERSION", "go1.22.1"},
+		{"go/bin/go", "#!/bin/sh\necho go\n"},
+		{"go/bin/gofmt", "#!/bin/sh\necho gofmt\n"},
+	}
+
+	for _, f := range files {
+		err := w.WriteHeader(&tar.Header{Name: f.Name, Mode: 0o755, Size: int64(len(f.Body)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = w.Write([]byte(f.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, hdr := range extra {
+		err := w.WriteHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func newTestServer(t *testing.T, archive []byte) *httptest.Server {
+	t.Helper()
+
+	sum := sha256.Sum256(archive)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /dl/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mode") != "json" || r.URL.Query().Get("include") != "all" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		releases := []string{}
+
+		for ver, stable := range testVersions {
+			checksum := hex.EncodeToString(sum[:])
+			if ver == "go1.22.0" {
+				checksum = strings.Repeat("0", 64)
+			}
+
+			releases = append(releases, fmt.Sprintf(`{
+				"version": %q,
+				"stable": %t,
+				"files": [
+					{"filename": "%[1]s.src.tar.gz", "os": "", "arch": "", "sha256": "", "kind": "source"},
+					{"filename": "%[1]s.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": %[3]q, "kind": "archive"},
+					{"filename": "%[1]s.linux-armv6l.tar.gz", "os": "linux", "arch": "armv6l", "sha256": %[3]q, "kind": "archive"},
+					{"filename": "%[1]s.windows-amd64.msi", "os": "windows", "arch": "amd64", "sha256": %[3]q, "kind": "installer"}
+				]
+			}`, ver, stable, checksum))
+		}
+
+		fmt.Fprintf(w, "[%s]", strings.Join(releases, ","))
+	})
+
+	mux.HandleFunc("GET /dl/{file}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGetLatestTag(t *testing.T) {
+	server := newTestServer(t, testArchive(t))
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Channel    provider.Channel
+		Expected   string
+	}{
+		"latest-stable": {
+			Expected: "1.22.1",
+		},
+		"constrained": {
+			Constraint: "~> 1.21.0",
+			Expected:   "1.21.8",
+		},
+		"unstable-needs-channel": {
+			Constraint: ">= 1.22",
+			Expected:   "1.22.1",
+		},
+		"unstable": {
+			Constraint: ">= 1.22",
+			Channel:    provider.ChannelPrerelease,
+			Expected:   "1.23rc1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				Endpoint: server.URL + "/dl/",
+				Settings: provider.Settings{Channel: tc.Channel},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := client.GetLatestTag(Owner, Repo, tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetReleaseVersion(t *testing.T) {
+	server := newTestServer(t, testArchive(t))
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL + "/dl/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Tag      string
+		Expected string
+		Error    bool
+	}{
+		"plain": {
+			Tag:      "1.22.1",
+			Expected: "1.22.1",
+		},
+		"go-prefix": {
+			Tag:      "go1.21.8",
+			Expected: "1.21.8",
+		},
+		"semver-prerelease": {
+			Tag:      "1.23.0-rc1",
+			Expected: "1.23rc1",
+		},
+		"missing": {
+			Tag:   "1.19.0",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			release, err := client.GetReleaseVersion(Owner, Repo, tc.Tag)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if release.TagName != tc.Expected {
+				t.Errorf("got %q; want %q", release.TagName, tc.Expected)
+			}
+
+			// Only archives are assets.
+			if len(release.Assets) != 2 { // lint:allow_raw_number
+				t.Errorf("got %d assets; want 2", len(release.Assets))
+			}
+		})
+	}
+}
+
+func TestGetAssetStream(t *testing.T) {
+	archive := testArchive(t)
+
+	server := newTestServer(t, archive)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Version  string
+		Arch     string
+		Pattern  string
+		Expected string
+		Error    bool
+		Mismatch bool
+	}{
+		"current-platform": {
+			Version:  "1.22.1",
+			Arch:     "amd64",
+			Expected: "go1.22.1.linux-amd64.tar.gz",
+		},
+		"arm": {
+			Version:  "1.22.1",
+			Arch:     "arm",
+			Expected: "go1.22.1.linux-armv6l.tar.gz",
+		},
+		"pattern": {
+			Version:  "1.21.8",
+			Arch:     "amd64",
+			Pattern:  `armv6l\.tar\.gz$`,
+			Expected: "go1.21.8.linux-armv6l.tar.gz",
+		},
+		"missing-platform": {
+			Version: "1.22.1",
+			Arch:    "riscv64",
+			Error:   true,
+		},
+		"checksum-mismatch": {
+			Version:  "1.22.0",
+			Arch:     "amd64",
+			Expected: "go1.22.0.linux-amd64.tar.gz",
+			Mismatch: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL + "/dl/", OS: "linux", Arch: tc.Arch})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			release, err := client.GetReleaseVersion(Owner, Repo, tc.Version)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stream, assetName, err := client.GetAssetStream([]string{Owner, Repo}, release, tc.Pattern)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stream.Close()
+
+			if assetName != tc.Expected {
+				t.Errorf("got %q; want %q", assetName, tc.Expected)
+			}
+
+			b, err := io.ReadAll(stream)
+			if tc.Mismatch {
+				if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+					t.Errorf("expected a checksum mismatch; got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(b, archive) {
+				t.Error("the downloaded archive does not match")
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	sdkDir := filepath.Join(dir, "sdk")
+	binDir := filepath.Join(dir, "bin")
+
+	// Install twice, to check that the previous copy is replaced.
+	for i := 0; i < 2; i++ {
+		out, err := Install(&InstallInput{
+			Archive:  bytes.NewReader(testArchive(t)),
+			Filename: "go1.22.1.linux-amd64.tar.gz",
+			Version:  "1.22.1",
+			SDKDirs:  []string{filepath.Join(dir, "missing", "\x00"), sdkDir},
+			BinDirs:  []string{binDir},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if out.Root != filepath.Join(sdkDir, "go1.22.1") {
+			t.Errorf("got root %q", out.Root)
+		}
+
+		if len(out.Links) != 2 { // lint:allow_raw_number
+			t.Errorf("got links %v", out.Links)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(binDir, "gofmt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(b) != "#!/bin/sh\necho gofmt\n" {
+		t.Errorf("gofmt links to the wrong file: %q", string(b))
+	}
+
+	entries, err := os.ReadDir(sdkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected only the installed tree in %s; got %d entries", sdkDir, len(entries))
+	}
+}
+
+func TestInstallRejectsEscapes(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Header *tar.Header
+		Error  bool
+	}{
+		"traversal-is-skipped": {
+			Header: &tar.Header{Name: "go/../../evil", Typeflag: tar.TypeReg},
+		},
+		"outside-tree-is-skipped": {
+			Header: &tar.Header{Name: "evil", Typeflag: tar.TypeReg},
+		},
+		"relative-symlink": {
+			Header: &tar.Header{Name: "go/bin/go2", Linkname: "go", Typeflag: tar.TypeSymlink},
+		},
+		"escaping-symlink": {
+			Header: &tar.Header{Name: "go/bin/evil", Linkname: "../../../etc/passwd", Typeflag: tar.TypeSymlink},
+			Error:  true,
+		},
+		"absolute-symlink": {
+			Header: &tar.Header{Name: "go/bin/evil", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+			Error:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			_, err := Install(&InstallInput{
+				Archive:  bytes.NewReader(testArchive(t, tc.Header)),
+				Filename: "go1.22.1.linux-amd64.tar.gz",
+				Version:  "1.22.1",
+				SDKDirs:  []string{filepath.Join(dir, "sdk")},
+				BinDirs:  []string{filepath.Join(dir, "bin")},
+			})
+
+			if tc.Error && err == nil {
+				t.Error("expected an error")
+			} else if !tc.Error && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "evil")); err == nil {
+				t.Error("a file was written outside of the Go tree")
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// treePrefix is the directory which every file in a Go archive is inside of.
+const treePrefix = "go/"
+
+type (
+	InstallInput struct {
+		// Archive is the downloaded `.tar.gz` or `.zip`, and Filename is its name.
+		Archive  io.Reader
+		Filename string
+
+		// Version is used to name the installation directory (`go<version>`).
+		Version string
+
+		// SDKDirs are the directories to install into, in order of preference.
+		// Defaults to DefaultSDKDirs.
+		SDKDirs []string
+
+		// BinDirs are the directories to link `go` and `gofmt` into, in order of
+		// preference. Defaults to DefaultBinDirs.
+		BinDirs []string
+	}
+
+	InstallOutput struct {
+		// Root is the installed Go tree (i.e., GOROOT).
+		Root string
+
+		// Links are the links which were created in the bin directory.
+		Links []string
+	}
+)
+
+// DefaultSDKDirs returns /usr/local/sdk, falling back to $HOME/sdk (where
+// `golang.org/dl` installs toolchains).
+func DefaultSDKDirs() []string {
+	return []string{
+		"/" + filepath.Join("usr", "local", "sdk"),
+		filepath.Join(os.Getenv("HOME"), "sdk"),
+	}
+}
+
+// DefaultBinDirs returns /usr/local/bin, falling back to $HOME/bin, as for any
+// other binary.
+func DefaultBinDirs() []string {
+	return []string{
+		"/" + filepath.Join("usr", "local", "bin"),
+		filepath.Join(os.Getenv("HOME"), "bin"),
+	}
+}
+
+// Install unpacks the `go/` tree from the archive into `go<version>` in the
+// first writable SDK directory, replacing any previous copy, and then links
+// `go` and `gofmt` into the first writable bin directory.
+func Install(input *InstallInput) (*InstallOutput, error) {
+	sdkDirs := input.SDKDirs
+	if len(sdkDirs) == 0 {
+		sdkDirs = DefaultSDKDirs()
+	}
+
+	binDirs := input.BinDirs
+	if len(binDirs) == 0 {
+		binDirs = DefaultBinDirs()
+	}
+
+	parent, staging, err := makeStagingDir(sdkDirs, input.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Does nothing once the staging directory has been renamed into place.
+	defer os.RemoveAll(staging)
+
+	switch {
+	case strings.HasSuffix(input.Filename, ".tar.gz"):
+		err = extractTarGz(input.Archive, staging)
+	case strings.HasSuffix(input.Filename, ".zip"):
+		err = extractZip(input.Archive, staging)
+	default:
+		err = errors.Errorf("unsupported Go archive: %s", input.Filename)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Join(parent, "go"+input.Version)
+
+	err = os.RemoveAll(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to remove the previous copy of %s", root)
+	}
+
+	err = os.Rename(staging, root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to move Go into %s", root)
+	}
+
+	links, err := linkBinaries(root, binDirs)
+	if err != nil {
+		return &InstallOutput{Root: root}, err
+	}
+
+	return &InstallOutput{Root: root, Links: links}, nil
+}
+
+// makeStagingDir creates a temporary directory next to where Go will be
+// installed, so that it can be moved into place in one step.
+func makeStagingDir(sdkDirs []string, ver string) (parent, staging string, err error) { // lint:allow_named_returns
+	for _, dir := range sdkDirs {
+		err = os.MkdirAll(dir, 0o755) // lint:allow_raw_number
+		if err != nil {
+			continue
+		}
+
+		staging, err = os.MkdirTemp(dir, ".go"+ver+"-*")
+		if err != nil {
+			continue
+		}
+
+		return dir, staging, nil
+	}
+
+	return "", "", errors.Wrapf(err, "none of %s are writable", strings.Join(sdkDirs, ", "))
+}
+
+// linkBinaries links the binaries in the Go tree into the first bin directory
+// where it is allowed, replacing whatever was there before.
+func linkBinaries(root string, binDirs []string) ([]string, error) {
+	var lastErr error
+
+	for _, dir := range binDirs {
+		links := []string{}
+
+		lastErr = os.MkdirAll(dir, 0o755) // lint:allow_raw_number
+		if lastErr != nil {
+			continue
+		}
+
+		for _, name := range []string{"go", "gofmt"} {
+			if runtime.GOOS == "windows" {
+				name += ".exe"
+			}
+
+			link := filepath.Join(dir, name)
+
+			err := os.Remove(link)
+			if err != nil && !os.IsNotExist(err) {
+				lastErr = err
+
+				break
+			}
+
+			err = os.Symlink(filepath.Join(root, "bin", name), link)
+			if err != nil {
+				lastErr = err
+
+				break
+			}
+
+			links = append(links, link)
+		}
+
+		if lastErr == nil {
+			return links, nil
+		}
+	}
+
+	return nil, errors.Wrap(lastErr, "failed to link go and gofmt")
+}
+
+func extractTarGz(r io.Reader, dest string) error {
+	g, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to create gzip reader")
+	}
+
+	t := tar.NewReader(g)
+
+	for {
+		hdr, err := t.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		target, ok := treePath(dest, hdr.Name)
+		if !ok {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755) // lint:allow_raw_number
+		case tar.TypeReg:
+			err = writeFile(target, t, hdr.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = writeSymlink(dest, target, hdr.Linkname)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Read to the end of the download, so that its checksum is verified.
+	_, err = io.Copy(io.Discard, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the Go archive")
+	}
+
+	return nil
+}
+
+func extractZip(r io.Reader, dest string) error {
+	b, err := io.ReadAll(r) // This also verifies the checksum of the download.
+	if err != nil {
+		return errors.Wrap(err, "error reading zip file into memory")
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return errors.Wrap(err, "error reading zip header")
+	}
+
+	for _, f := range z.File {
+		target, ok := treePath(dest, f.Name)
+		if !ok {
+			continue
+		}
+
+		if f.FileInfo().IsDir() {
+			err = os.MkdirAll(target, 0o755) // lint:allow_raw_number
+			if err != nil {
+				return errors.Wrapf(err, "failed to create %s", target)
+			}
+
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", f.Name)
+		}
+
+		err = writeFile(target, rc, f.Mode())
+		rc.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// treePath maps a path in the archive to the destination, stripping the `go/`
+// prefix. Anything outside of the tree, or escaping it, is skipped.
+func treePath(dest, name string) (string, bool) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	if !strings.HasPrefix(name, treePrefix) {
+		return "", false
+	}
+
+	rel := filepath.FromSlash(strings.TrimPrefix(name, treePrefix))
+	if rel == "" || !filepath.IsLocal(rel) {
+		return "", false
+	}
+
+	return filepath.Join(dest, rel), true
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0o755) // lint:allow_raw_number
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Dir(target))
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()) // lint:allow_include_file
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", target)
+	}
+
+	_, err = io.Copy(f, r) // lint:allow_decompress
+	if err != nil {
+		f.Close()
+
+		return errors.Wrapf(err, "failed to write %s", target)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "could not close %s", target)
+	}
+
+	return nil
+}
+
+// writeSymlink creates a symlink, as long as it points somewhere inside of the
+// tree.
+func writeSymlink(dest, target, linkname string) error {
+	resolved := filepath.Join(filepath.Dir(target), linkname)
+
+	rel, err := filepath.Rel(dest, resolved)
+	if err != nil || filepath.IsAbs(linkname) || !filepath.IsLocal(rel) {
+		return errors.Errorf("refusing to create a symlink outside of the Go tree: %s -> %s", target, linkname)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0o755) // lint:allow_raw_number
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Dir(target))
+	}
+
+	err = os.Symlink(linkname, target)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create symlink %s", target)
+	}
+
+	return nil
+}
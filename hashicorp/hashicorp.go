@@ -0,0 +1,363 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package hashicorp provides a library for downloading products (Terraform,
+Packer, Vault, etc.) from releases.hashicorp.com, or a mirror of it.
+
+The product is the repository name from `owner/repo` (e.g., `hashicorp/terraform`
+downloads `terraform`). Every download is verified against the release's
+SHA256SUMS file.
+*/
+package hashicorp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+// DefaultEndpoint is used when no endpoint has been provided.
+const DefaultEndpoint = "https://releases.hashicorp.com"
+
+type (
+	NewClientInput struct {
+		// Endpoint is the base URL of the releases site, or of a mirror with the
+		// same layout.
+		Endpoint string
+
+		// OS and Arch select the build (using GOOS/GOARCH names) when no pattern
+		// is given.
+		OS   string
+		Arch string
+
+		// Settings choose between the versions in the product's index.json.
+		Settings provider.Settings
+
+		// NoWait gives up if the releases site is rate-limiting requests.
+		NoWait bool
+	}
+
+	// Client reads the releases index, and implements provider.Provider.
+	Client struct {
+		baseURL    string
+		os         string
+		arch       string
+		httpClient *http.Client
+		settings   provider.Settings
+	}
+
+	productIndex struct {
+		Name     string                    `json:"name"`
+		Versions map[string]*releaseDetail `json:"versions"`
+	}
+
+	releaseDetail struct {
+		Name    string  `json:"name"`
+		Version string  `json:"version"`
+		Builds  []build `json:"builds"`
+	}
+
+	build struct {
+		OS       string `json:"os"`
+		Arch     string `json:"arch"`
+		Filename string `json:"filename"`
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	_ provider.PlatformProvider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	endpoint := input.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid HashiCorp releases endpoint: %s", input.Endpoint)
+	}
+
+	return &Client{
+		baseURL:  strings.TrimRight(endpoint, "/"),
+		os:       input.OS,
+		arch:     input.Arch,
+		settings: input.Settings,
+		httpClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		},
+	}, nil
+}
+
+// ForPlatform returns a copy of the client which chooses the build for another
+// platform. HashiCorp doesn't publish variants, so the variant is ignored.
+func (c *Client) ForPlatform(os, arch, _ string, settings provider.Settings) provider.Provider {
+	cp := *c
+	cp.os, cp.arch, cp.settings = os, arch, settings
+
+	return &cp
+}
+
+// BaseURL returns the base URL of the releases site.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// GetLatestRelease returns the highest version of the product in the channel.
+func (c *Client) GetLatestRelease(owner, product string) (*provider.Release, error) {
+	tag, err := c.GetLatestTag(owner, product, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetReleaseVersion(owner, product, tag)
+}
+
+// GetReleaseVersion returns a specific version of the product. Its assets are
+// the builds for every platform.
+func (c *Client) GetReleaseVersion(owner, product, tagName string) (*provider.Release, error) {
+	detail := releaseDetail{}
+
+	err := c.getJSON(c.productURL(product, tagName, "index.json"), &detail)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s %s", product, tagName)
+	}
+
+	return c.toRelease(product, &detail), nil
+}
+
+// ListReleases returns every version of the product, except for enterprise and
+// other builds with metadata. The index already includes their builds.
+func (c *Client) ListReleases(owner, product string) ([]*provider.Release, error) {
+	index, err := c.productIndex(product)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*provider.Release, 0, len(index.Versions))
+
+	for v, detail := range index.Versions {
+		if strings.Contains(v, "+") {
+			continue
+		}
+
+		releases = append(releases, c.toRelease(product, detail))
+	}
+
+	return releases, nil
+}
+
+// toRelease converts a version of the product into the provider-agnostic type.
+// Its assets are the builds for every platform.
+func (c *Client) toRelease(product string, detail *releaseDetail) *provider.Release {
+	rel := &provider.Release{
+		TagName:    detail.Version,
+		Name:       detail.Name + " " + detail.Version,
+		Prerelease: provider.IsPrerelease(detail.Version),
+		Assets:     make([]*provider.Asset, 0, len(detail.Builds)),
+	}
+
+	for i := range detail.Builds {
+		rel.Assets = append(rel.Assets, &provider.Asset{
+			Name: detail.Builds[i].Filename,
+			URL:  c.productURL(product, detail.Version, detail.Builds[i].Filename),
+		})
+	}
+
+	return rel
+}
+
+// GetLatestTag returns the highest version of the product which satisfies the
+// constraint. Enterprise and other builds with metadata (e.g., `+ent`) are
+// skipped.
+func (c *Client) GetLatestTag(owner, product, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, product)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+// ListTags returns every version of the product, except for enterprise and
+// other builds with metadata.
+func (c *Client) ListTags(owner, product string) ([]provider.Tag, error) {
+	index, err := c.productIndex(product)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(index.Versions))
+
+	for v := range index.Versions {
+		if strings.Contains(v, "+") {
+			continue
+		}
+
+		tags = append(tags, v)
+	}
+
+	return provider.NewTags(tags), nil
+}
+
+// productIndex downloads the index of every version of the product.
+func (c *Client) productIndex(product string) (*productIndex, error) {
+	index := productIndex{}
+
+	err := c.getJSON(c.baseURL+"/"+url.PathEscape(product)+"/index.json", &index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the versions of %s", product)
+	}
+
+	return &index, nil
+}
+
+// GetAssetStream downloads the build whose filename matches the pattern, or the
+// build for the client's platform if the pattern is empty. The stream fails
+// when read to the end if it does not match the release's SHA256SUMS.
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	if len(ownerRepo) != 2 { // lint:allow_raw_number
+		return nil, "", errors.New("invalid owner/repo")
+	}
+
+	product := ownerRepo[1]
+
+	var asset *provider.Asset
+
+	if pattern != "" {
+		asset, err = c.settings.MatchAsset(release.Assets, pattern)
+	} else {
+		asset, err = c.platformAsset(product, release)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	sums, err := c.shasums(product, release.TagName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sum, ok := sums[asset.Name]
+	if !ok {
+		return nil, "", errors.Errorf("%s is not listed in the SHA256SUMS for %s", asset.Name, release.TagName)
+	}
+
+	resp, err := c.get(asset.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to download %s", asset.Name)
+	}
+
+	return provider.VerifyChecksumCloser(resp.Body, "sha256:"+sum), asset.Name, nil
+}
+
+// platformAsset finds the build for the client's platform, which is named
+// `<product>_<version>_<os>_<arch>.zip`.
+func (c *Client) platformAsset(product string, release *provider.Release) (*provider.Asset, error) {
+	filename := product + "_" + release.TagName + "_" + c.os + "_" + c.arch + ".zip"
+
+	for i := range release.Assets {
+		if release.Assets[i].Name == filename {
+			return release.Assets[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("no %s/%s build of %s %s", c.os, c.arch, product, release.TagName)
+}
+
+// shasums downloads the SHA256SUMS file for a version, and returns a map of
+// filename to checksum.
+func (c *Client) shasums(product, ver string) (map[string]string, error) {
+	resp, err := c.get(c.productURL(product, ver, product+"_"+ver+"_SHA256SUMS"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download SHA256SUMS")
+	}
+	defer resp.Body.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 { // lint:allow_raw_number
+			sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read SHA256SUMS")
+	}
+
+	return sums, nil
+}
+
+func (c *Client) productURL(product, ver, filename string) string {
+	return c.baseURL + "/" + url.PathEscape(product) + "/" + url.PathEscape(ver) + "/" + url.PathEscape(filename)
+}
+
+func (c *Client) getJSON(rawURL string, v any) error {
+	resp, err := c.get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the releases index")
+	}
+
+	return nil
+}
+
+func (c *Client) get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+
+		return nil, errors.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashicorp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+var testVersions = []string{"1.5.7", "1.6.0", "1.6.1", "1.7.0-alpha20231025", "1.6.1+ent"}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	builds := func(ver string) string {
+		parts := []string{}
+
+		for _, platform := range []string{"linux_amd64", "linux_arm64", "darwin_arm64"} {
+			parts = append(parts, fmt.Sprintf(
+				`{"os": %q, "arch": %q, "filename": "terraform_%s_%s.zip"}`,
+				strings.Split(platform, "_")[0], strings.Split(platform, "_")[1], ver, platform,
+			))
+		}
+
+		return fmt.Sprintf(`{"name": "terraform", "version": %q, "builds": [%s]}`, ver, strings.Join(parts, ","))
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /terraform/index.json", func(w http.ResponseWriter, r *http.Request) {
+		versions := []string{}
+		for _, v := range testVersions {
+			versions = append(versions, fmt.Sprintf("%q: %s", v, builds(v)))
+		}
+
+		fmt.Fprintf(w, `{"name": "terraform", "versions": {%s}}`, strings.Join(versions, ","))
+	})
+
+	mux.HandleFunc("GET /terraform/{ver}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		ver, file := r.PathValue("ver"), r.PathValue("file")
+
+		switch {
+		case file == "index.json":
+			fmt.Fprint(w, builds(ver))
+		case strings.HasSuffix(file, "_SHA256SUMS"):
+			for _, platform := range []string{"linux_amd64", "linux_arm64"} {
+				name := fmt.Sprintf("terraform_%s_%s.zip", ver, platform)
+				sum := sha256.Sum256([]byte("zip:" + name))
+
+				fmt.Fprintf(w, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+			}
+
+			// The darwin build is listed with the wrong checksum.
+			fmt.Fprintf(w, "%s  terraform_%s_darwin_arm64.zip\n", strings.Repeat("0", 64), ver)
+		default:
+			fmt.Fprint(w, "zip:"+file)
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGetLatestTag(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Channel    provider.Channel
+		Expected   string
+	}{
+		"latest-stable": {
+			Expected: "1.6.1",
+		},
+		"constrained": {
+			Constraint: "~> 1.5.0",
+			Expected:   "1.5.7",
+		},
+		"prerelease-needs-channel": {
+			Constraint: ">= 1.6",
+			Expected:   "1.6.1",
+		},
+		"prerelease": {
+			Constraint: ">= 1.6",
+			Channel:    provider.ChannelPrerelease,
+			Expected:   "1.7.0-alpha20231025",
+		},
+		"latest-any": {
+			Channel:  provider.ChannelAny,
+			Expected: "1.7.0-alpha20231025",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				Endpoint: server.URL,
+				Settings: provider.Settings{Channel: tc.Channel},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := client.GetLatestTag("hashicorp", "terraform", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetAssetStream(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	var tests = map[string]struct { // lint:no_dupe
+		Arch     string
+		Pattern  string
+		Expected string
+		Error    bool
+	}{
+		"current-platform": {
+			Arch:     "amd64",
+			Expected: "terraform_1.6.1_linux_amd64.zip",
+		},
+		"pattern": {
+			Arch:     "amd64",
+			Pattern:  `linux_arm64\.zip$`,
+			Expected: "terraform_1.6.1_linux_arm64.zip",
+		},
+		"missing-platform": {
+			Arch:  "riscv64",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{Endpoint: server.URL, OS: "linux", Arch: tc.Arch})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			release, err := client.GetLatestRelease("hashicorp", "terraform")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stream, assetName, err := client.GetAssetStream([]string{"hashicorp", "terraform"}, release, tc.Pattern)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stream.Close()
+
+			b, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if assetName != tc.Expected || string(b) != "zip:"+tc.Expected {
+				t.Errorf("got %q (%q); want %q", assetName, string(b), tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetAssetStreamChecksumMismatch(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client, err := NewClient(&NewClientInput{Endpoint: server.URL, OS: "darwin", Arch: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := client.GetReleaseVersion("hashicorp", "terraform", "1.6.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream, _, err := client.GetAssetStream([]string{"hashicorp", "terraform"}, release, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	_, err = io.ReadAll(stream)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch; got %v", err)
+	}
+}
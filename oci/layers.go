@@ -0,0 +1,276 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+type (
+	// tempFile deletes itself when closed.
+	tempFile struct {
+		*os.File
+	}
+
+	// whiteouts tracks the paths which upper layers have deleted.
+	whiteouts struct {
+		deleted map[string]bool
+		opaque  map[string]bool
+	}
+)
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name()) // lint:allow_unhandled
+
+	return err
+}
+
+// digestOf returns the reference if it is a digest, or an empty string if it is
+// a tag.
+func digestOf(reference string) string {
+	if strings.Contains(reference, ":") {
+		return reference
+	}
+
+	return ""
+}
+
+// mergeLayers flattens the layers of an image into a single, uncompressed
+// tarball in a temporary file, which is deleted when closed. Layers are read
+// from the top down, so the first copy of a path wins, and whiteouts hide paths
+// in the layers beneath them.
+func (c *Client) mergeLayers(repository string, layers []descriptor) (io.ReadCloser, error) {
+	f, err := os.CreateTemp("", "download-asset-*.tar")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a temporary file for the image")
+	}
+
+	out := &tempFile{File: f}
+	tw := tar.NewWriter(f)
+	seen := map[string]bool{}
+	wh := whiteouts{deleted: map[string]bool{}, opaque: map[string]bool{}}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		err = c.copyLayer(tw, repository, layers[i], seen, &wh)
+		if err != nil {
+			out.Close()
+
+			return nil, errors.Wrapf(err, "failed to read layer %s", layers[i].Digest)
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		out.Close()
+
+		return nil, errors.Wrap(err, "failed to write the merged image")
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		out.Close()
+
+		return nil, errors.Wrap(err, "failed to rewind the merged image")
+	}
+
+	return out, nil
+}
+
+// copyLayer copies the entries of one layer which are not hidden by the layers
+// above it. Its own whiteouts only apply to the layers below, so they are
+// recorded once the whole layer has been read.
+func (c *Client) copyLayer(
+	tw *tar.Writer,
+	repository string,
+	layer descriptor,
+	seen map[string]bool,
+	wh *whiteouts,
+) error {
+	blob, err := c.blob(repository, layer)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	var r io.Reader = blob
+
+	switch {
+	case strings.Contains(layer.MediaType, "zstd"):
+		return errors.Errorf("zstd-compressed layers are not supported (%s)", layer.MediaType)
+	case strings.Contains(layer.MediaType, "gzip"):
+		g, err := gzip.NewReader(blob)
+		if err != nil {
+			return errors.Wrap(err, "failed to create gzip reader")
+		}
+		defer g.Close()
+
+		r = g
+	}
+
+	tr := tar.NewReader(r)
+	layerWhiteouts := whiteouts{deleted: map[string]bool{}, opaque: map[string]bool{}}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		name := cleanName(hdr.Name)
+		dir, base := path.Split(name)
+
+		switch {
+		case name == "":
+			continue
+		case base == whiteoutOpaque:
+			layerWhiteouts.opaque[path.Clean(dir)] = true
+
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			layerWhiteouts.deleted[path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))] = true
+
+			continue
+		case seen[name] || wh.hides(name):
+			continue
+		}
+
+		seen[name] = true
+		hdr.Name = name
+
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = cleanName(hdr.Linkname)
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write %s", name)
+		}
+
+		_, err = io.Copy(tw, tr) // lint:allow_decompress
+		if err != nil {
+			return errors.Wrapf(err, "failed to copy %s", name)
+		}
+	}
+
+	// Read to the end of the blob, so that its digest is verified.
+	_, err = io.Copy(io.Discard, blob)
+	if err != nil {
+		return errors.Wrap(err, "failed to read layer")
+	}
+
+	for k := range layerWhiteouts.deleted {
+		wh.deleted[k] = true
+	}
+
+	for k := range layerWhiteouts.opaque {
+		wh.opaque[k] = true
+	}
+
+	return nil
+}
+
+// bundleFiles packs the titled files of an artifact into a tarball in a
+// temporary file, so that the archive path can pick one of them.
+func (c *Client) bundleFiles(repository string, files []descriptor) (io.ReadCloser, error) {
+	f, err := os.CreateTemp("", "download-asset-*.tar")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a temporary file for the artifact")
+	}
+
+	out := &tempFile{File: f}
+	tw := tar.NewWriter(f)
+
+	for i := range files {
+		err = c.bundleFile(tw, repository, files[i])
+		if err != nil {
+			out.Close()
+
+			return nil, err
+		}
+	}
+
+	err = tw.Close()
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		out.Close()
+
+		return nil, errors.Wrap(err, "failed to write the artifact")
+	}
+
+	return out, nil
+}
+
+func (c *Client) bundleFile(tw *tar.Writer, repository string, file descriptor) error {
+	blob, err := c.blob(repository, file)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	name := cleanName(file.Annotations[annotationTitle])
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     file.Size,
+		Mode:     0o755, // lint:allow_raw_number
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+
+	_, err = io.Copy(tw, blob) // lint:allow_decompress
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy %s", name)
+	}
+
+	return nil
+}
+
+// hides reports whether a path was deleted by an upper layer, either directly,
+// by deleting one of its parents, or by making one of its parents opaque.
+func (wh *whiteouts) hides(name string) bool {
+	for p := name; p != "." && p != "/"; p = path.Dir(p) {
+		if wh.deleted[p] || (p != name && wh.opaque[p]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanName normalizes a path inside a layer to be relative, without a leading
+// `./` or `/`.
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
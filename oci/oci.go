@@ -0,0 +1,519 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package oci provides a library for downloading binaries from OCI registries,
+either from artifacts pushed with ORAS (or similar) or from the layers of a
+container image.
+
+There are no release objects to look up, so the "pattern" passed to
+GetAssetStream is the (already resolved) reference of the image or artifact.
+*/
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	mediaTypeOCIIndex        = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest     = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	annotationTitle          = "org.opencontainers.image.title"
+	annotationReferenceType  = "vnd.docker.reference.type"
+	referenceTypeAttestation = "attestation-manifest"
+
+	// basicAuthUser is used with a token which has no username of its own.
+	basicAuthUser = "token"
+)
+
+type (
+	NewClientInput struct {
+		// Reference is the image or artifact, as in `ghcr.io/org/tool:1.2.3`. Its
+		// tag may still be a template; only the registry and repository are used
+		// when listing tags.
+		Reference string
+
+		// Token is either a bare token or `username:password`.
+		Token string
+
+		// OS, Arch and Variant select the manifest from an image index, using
+		// GOOS/GOARCH names (as the OCI image spec does). Variant is optional.
+		OS      string
+		Arch    string
+		Variant string
+
+		// Settings choose between the tags in the repository.
+		Settings provider.Settings
+
+		// NoWait fails on registry pull limits (e.g., Docker Hub's) instead of
+		// waiting for them to reset.
+		NoWait bool
+	}
+
+	// Client talks to an OCI distribution (Docker Registry v2) API, and
+	// implements provider.Provider.
+	Client struct {
+		ref        Reference
+		username   string
+		password   string
+		bearer     string
+		os         string
+		arch       string
+		variant    string
+		httpClient *http.Client
+		settings   provider.Settings
+	}
+
+	descriptor struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+		Platform    *struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant,omitempty"`
+		} `json:"platform,omitempty"`
+	}
+
+	// manifest covers both image indexes and image manifests, which are told
+	// apart by which of Manifests or Layers is set.
+	manifest struct {
+		MediaType string       `json:"mediaType"`
+		Manifests []descriptor `json:"manifests"`
+		Layers    []descriptor `json:"layers"`
+	}
+)
+
+var (
+	ctx = context.Background()
+
+	challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	manifestMediaTypes = []string{
+		mediaTypeOCIIndex,
+		mediaTypeOCIManifest,
+		mediaTypeDockerList,
+		mediaTypeDockerManifest,
+	}
+
+	_ provider.PlatformProvider = (*Client)(nil)
+)
+
+func NewClient(input *NewClientInput) (*Client, error) {
+	ref, err := ParseReference(input.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		ref:      ref,
+		os:       input.OS,
+		arch:     input.Arch,
+		variant:  input.Variant,
+		settings: input.Settings,
+		httpClient: &http.Client{
+			Transport: provider.NewRetryTransport(http.DefaultTransport, input.NoWait),
+		},
+	}
+
+	if input.Token != "" {
+		c.username, c.password, _ = strings.Cut(input.Token, ":")
+		if c.password == "" {
+			c.username, c.password = basicAuthUser, input.Token
+		}
+	}
+
+	return c, nil
+}
+
+// ForPlatform returns a copy of the client which chooses the image for another
+// platform from an image index.
+func (c *Client) ForPlatform(os, arch, variant string, settings provider.Settings) provider.Provider {
+	cp := *c
+	cp.os, cp.arch, cp.variant, cp.settings = os, arch, variant, settings
+
+	return &cp
+}
+
+// RegistryURL returns the base URL of the registry's API.
+func (c *Client) RegistryURL() string {
+	return c.ref.scheme() + "://" + c.ref.Registry + "/v2/"
+}
+
+// GetLatestRelease returns the highest version among the repository's tags.
+func (c *Client) GetLatestRelease(owner, repo string) (*provider.Release, error) {
+	tag, err := c.GetLatestTag(owner, repo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider.Release{TagName: tag}, nil
+}
+
+// GetReleaseVersion returns a release for the tag. Whether it exists is only
+// known once its manifest is requested.
+func (c *Client) GetReleaseVersion(owner, repo, tagName string) (*provider.Release, error) {
+	return &provider.Release{TagName: tagName}, nil
+}
+
+// ListReleases returns no releases, since a registry only has tags.
+func (c *Client) ListReleases(owner, repo string) ([]*provider.Release, error) {
+	return []*provider.Release{}, nil
+}
+
+// GetLatestTag returns the highest version among the repository's tags which
+// satisfies the constraint.
+func (c *Client) GetLatestTag(owner, repo, constraint string) (string, error) {
+	tags, err := c.ListTags(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	return c.settings.LatestTag(tags, constraint)
+}
+
+// ListTags returns every tag in the repository.
+func (c *Client) ListTags(owner, repo string) ([]provider.Tag, error) {
+	tags := []string{}
+	next := c.apiURL(c.ref.Repository, "tags/list") + "?n=1000"
+
+	for next != "" {
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+
+		resp, err := c.get(next, "application/json")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode the list of tags")
+		}
+
+		tags = append(tags, page.Tags...)
+		next = ""
+
+		if link := provider.NextLink(resp.Header.Get("Link")); link != "" && len(page.Tags) > 0 {
+			u, err := resp.Request.URL.Parse(link)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid link to the next page of tags")
+			}
+
+			next = u.String()
+		}
+	}
+
+	return provider.NewTags(tags), nil
+}
+
+// GetAssetStream fetches the image or artifact at the reference passed as the
+// pattern. For an image index, the manifest for the client's platform is used.
+//
+// Artifacts with a single titled file (as pushed by ORAS) are streamed as that
+// file. Otherwise, the layers are merged into a temporary tarball, so that the
+// archive path can be found in whichever layer provides it.
+func (c *Client) GetAssetStream( // lint:allow_named_returns
+	ownerRepo []string,
+	release *provider.Release,
+	pattern string,
+) (archiveStream io.ReadCloser, name string, err error) {
+	ref, err := ParseReference(pattern)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, err := c.resolveManifest(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(m.Layers) == 0 {
+		return nil, "", errors.Errorf("%s has no layers", ref)
+	}
+
+	titled := []descriptor{}
+
+	for i := range m.Layers {
+		if m.Layers[i].Annotations[annotationTitle] != "" {
+			titled = append(titled, m.Layers[i])
+		}
+	}
+
+	if len(titled) == 1 {
+		title := path.Base(titled[0].Annotations[annotationTitle])
+
+		blob, err := c.blob(ref.Repository, titled[0])
+		if err != nil {
+			return nil, "", err
+		}
+
+		return blob, title, nil
+	}
+
+	name = path.Base(ref.Repository) + ".tar"
+
+	if len(titled) > 1 {
+		archiveStream, err = c.bundleFiles(ref.Repository, titled)
+	} else {
+		archiveStream, err = c.mergeLayers(ref.Repository, m.Layers)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return archiveStream, name, nil
+}
+
+// resolveManifest fetches the manifest for the reference, descending into an
+// image index if there is one.
+func (c *Client) resolveManifest(ref Reference) (*manifest, error) {
+	m, err := c.manifest(ref.Repository, ref.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Manifests) == 0 {
+		return m, nil
+	}
+
+	d, err := c.selectPlatform(m.Manifests)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to select a manifest from %s", ref)
+	}
+
+	return c.manifest(ref.Repository, d.Digest)
+}
+
+// selectPlatform picks the manifest for the client's platform from an index. A
+// manifest with the same variant wins over one where either variant is empty.
+func (c *Client) selectPlatform(manifests []descriptor) (*descriptor, error) {
+	var match *descriptor
+
+	for i := range manifests {
+		d := manifests[i]
+
+		if d.Platform == nil || d.Annotations[annotationReferenceType] == referenceTypeAttestation {
+			continue
+		}
+
+		if d.Platform.OS != c.os || d.Platform.Architecture != c.arch {
+			continue
+		}
+
+		if d.Platform.Variant == c.variant {
+			return &d, nil
+		}
+
+		if match == nil && (c.variant == "" || d.Platform.Variant == "") {
+			match = &d
+		}
+	}
+
+	if match != nil {
+		return match, nil
+	}
+
+	platform := c.os + "/" + c.arch
+	if c.variant != "" {
+		platform += "/" + c.variant
+	}
+
+	return nil, errors.Errorf("no manifest for %s", platform)
+}
+
+func (c *Client) manifest(repository, reference string) (*manifest, error) {
+	resp, err := c.get(c.apiURL(repository, "manifests/"+reference), manifestMediaTypes...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the manifest for %s", reference)
+	}
+	defer resp.Body.Close()
+
+	m := manifest{}
+
+	err = json.NewDecoder(provider.VerifyChecksum(resp.Body, digestOf(reference))).Decode(&m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the manifest for %s", reference)
+	}
+
+	return &m, nil
+}
+
+// blob opens a blob. The digest is verified as the stream is read, and reading
+// fails if it does not match.
+func (c *Client) blob(repository string, d descriptor) (io.ReadCloser, error) {
+	resp, err := c.get(c.apiURL(repository, "blobs/"+d.Digest))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download %s", d.Digest)
+	}
+
+	return provider.VerifyChecksumCloser(resp.Body, d.Digest), nil
+}
+
+func (c *Client) apiURL(repository, path string) string {
+	return c.ref.scheme() + "://" + c.ref.Registry + "/v2/" + repository + "/" + path
+}
+
+// get performs a GET request against the registry, answering an authentication
+// challenge if there is one.
+func (c *Client) get(rawURL string, accept ...string) (*http.Response, error) {
+	resp, err := c.do(rawURL, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		err = c.authenticate(challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = c.do(rawURL, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+
+		return nil, errors.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (c *Client) do(rawURL string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+
+	// Blobs are often redirected to a storage service on another host, but the
+	// client drops the Authorization header when that happens.
+	switch {
+	case c.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearer)
+	case c.password != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	return resp, nil
+}
+
+// authenticate answers a `WWW-Authenticate` challenge. For the Bearer scheme, a
+// token is requested from the realm (anonymously, unless there are
+// credentials), and used for all later requests.
+func (c *Client) authenticate(challenge string) error {
+	scheme, rawParams, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return errors.Errorf("the registry requires credentials (%s)", challenge)
+	}
+
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(rawParams, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return errors.Errorf("invalid authentication challenge: %s", challenge)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull"
+	}
+
+	query := realm.Query()
+	query.Set("scope", scope)
+
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to create token request")
+	}
+
+	if c.password != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString(
+			[]byte(c.username+":"+c.password),
+		))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "token request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to get a registry token from %s: %s", realm.Host, resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the registry token")
+	}
+
+	c.bearer = token.Token
+	if c.bearer == "" {
+		c.bearer = token.AccessToken
+	}
+
+	if c.bearer == "" {
+		return errors.Errorf("no registry token returned by %s", realm.Host)
+	}
+
+	return nil
+}
@@ -0,0 +1,572 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const registryToken = "registry-token"
+
+// testRegistry is an in-memory registry which requires an anonymous Bearer
+// token, like most public registries.
+type testRegistry struct {
+	server    *httptest.Server
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string][]string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+
+	reg := &testRegistry{
+		blobs:     map[string][]byte{},
+		manifests: map[string][]byte{},
+		tags:      map[string][]string{},
+	}
+
+	mux := http.NewServeMux()
+	reg.server = httptest.NewServer(mux)
+
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"token": %q}`, registryToken)
+	})
+
+	mux.HandleFunc("GET /v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+registryToken {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test-registry",scope="repository:org/tool:pull"`,
+				reg.server.URL,
+			))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+		switch {
+		case strings.Contains(path, "/manifests/"):
+			repo, ref, _ := strings.Cut(path, "/manifests/")
+
+			b, ok := reg.manifests[repo+"@"+ref]
+			if !ok {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", mediaTypeOCIManifest)
+			w.Write(b)
+		case strings.Contains(path, "/blobs/"):
+			_, digest, _ := strings.Cut(path, "/blobs/")
+
+			b, ok := reg.blobs[digest]
+			if !ok {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			w.Write(b)
+		case strings.HasSuffix(path, "/tags/list"):
+			repo := strings.TrimSuffix(path, "/tags/list")
+			tags := reg.tags[repo]
+
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=2&last=%s>; rel="next"`, repo, tags[1]))
+				tags = tags[:2]
+			} else {
+				tags = tags[2:]
+			}
+
+			json.NewEncoder(w).Encode(map[string]any{"name": repo, "tags": tags})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	return reg
+}
+
+func digestOfBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// addBlob stores a blob and returns its descriptor.
+func (reg *testRegistry) addBlob(mediaType string, b []byte, annotations map[string]string) descriptor {
+	d := descriptor{
+		MediaType:   mediaType,
+		Digest:      digestOfBytes(b),
+		Size:        int64(len(b)),
+		Annotations: annotations,
+	}
+
+	reg.blobs[d.Digest] = b
+
+	return d
+}
+
+// addManifest stores a manifest under its digest and the tag, and returns its
+// digest.
+func (reg *testRegistry) addManifest(repo, tag string, m any) string {
+	b, _ := json.Marshal(m)
+	digest := digestOfBytes(b)
+
+	reg.manifests[repo+"@"+digest] = b
+	if tag != "" {
+		reg.manifests[repo+"@"+tag] = b
+	}
+
+	return digest
+}
+
+// layer builds a gzipped tarball of the files, in order.
+func layer(t *testing.T, files ...string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	g := gzip.NewWriter(buf)
+	tw := tar.NewWriter(g)
+
+	for i := 0; i < len(files); i += 2 {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     files[i],
+			Size:     int64(len(files[i+1])),
+			Mode:     0o755, // lint:allow_raw_number
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tw.Write([]byte(files[i+1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tw.Close()
+	g.Close()
+
+	return buf.Bytes()
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[hdr.Name] = string(b)
+	}
+
+	return files
+}
+
+func TestGetAssetStreamImage(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.server.Close()
+
+	const layerType = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	lower := reg.addBlob(layerType, layer(t,
+		"usr/bin/tool", "old",
+		"etc/deleted.conf", "deleted",
+		"opt/dir/a", "hidden by opaque",
+	), nil)
+
+	upper := reg.addBlob(layerType, layer(t,
+		"./usr/bin/tool", "new",
+		"etc/.wh.deleted.conf", "",
+		"opt/dir/.wh..wh..opq", "",
+		"opt/dir/b", "kept",
+	), nil)
+
+	amd64 := reg.addManifest("org/tool", "", manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers:    []descriptor{lower, upper},
+	})
+
+	arm64 := reg.addManifest("org/tool", "", manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers:    []descriptor{reg.addBlob(layerType, layer(t, "usr/bin/tool", "arm64"), nil)},
+	})
+
+	index := fmt.Sprintf(`{
+		"mediaType": %q,
+		"manifests": [
+			{"mediaType": %[2]q, "digest": %[3]q, "platform": {"os": "unknown", "architecture": "unknown"},
+			 "annotations": {"vnd.docker.reference.type": "attestation-manifest"}},
+			{"mediaType": %[2]q, "digest": %[4]q, "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+			{"mediaType": %[2]q, "digest": %[3]q, "platform": {"os": "linux", "architecture": "amd64"}}
+		]
+	}`, mediaTypeOCIIndex, mediaTypeOCIManifest, amd64, arm64)
+	reg.manifests["org/tool@1.2.0"] = []byte(index)
+
+	host := strings.TrimPrefix(reg.server.URL, "http://")
+
+	var tests = map[string]struct { // lint:no_dupe
+		Arch     string
+		Variant  string
+		Expected map[string]string
+		Error    bool
+	}{
+		"amd64": {
+			Arch: "amd64",
+			Expected: map[string]string{
+				"usr/bin/tool": "new",
+				"opt/dir/b":    "kept",
+			},
+		},
+		"arm64-variant": {
+			Arch:    "arm64",
+			Variant: "v8",
+			Expected: map[string]string{
+				"usr/bin/tool": "arm64",
+			},
+		},
+		"missing-platform": {
+			Arch:  "riscv64",
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&NewClientInput{
+				Reference: host + "/org/tool:{{.Ver}}",
+				OS:        "linux",
+				Arch:      tc.Arch,
+				Variant:   tc.Variant,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream, assetName, err := client.GetAssetStream(nil, nil, host+"/org/tool:1.2.0")
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stream.Close()
+
+			if assetName != "tool.tar" {
+				t.Errorf("name: got %q; want %q", assetName, "tool.tar")
+			}
+
+			actual := readTar(t, stream)
+			if fmt.Sprint(actual) != fmt.Sprint(tc.Expected) {
+				t.Errorf("got %v; want %v", actual, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestSelectPlatform(t *testing.T) {
+	const (
+		v6First = `[
+			{"digest": "arm-v6", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}},
+			{"digest": "arm-v7", "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}}
+		]`
+		v7First = `[
+			{"digest": "arm-v7", "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}},
+			{"digest": "arm-v6", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}}
+		]`
+		noVariantFirst = `[
+			{"digest": "arm", "platform": {"os": "linux", "architecture": "arm"}},
+			{"digest": "arm-v6", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}}
+		]`
+	)
+
+	var tests = map[string]struct { // lint:no_dupe
+		Index    string
+		Variant  string
+		Expected string
+		Error    bool
+	}{
+		"v6-first-wants-v6": {
+			Index:    v6First,
+			Variant:  "v6",
+			Expected: "arm-v6",
+		},
+		"v6-first-wants-v7": {
+			Index:    v6First,
+			Variant:  "v7",
+			Expected: "arm-v7",
+		},
+		"v7-first-wants-v6": {
+			Index:    v7First,
+			Variant:  "v6",
+			Expected: "arm-v6",
+		},
+		"v7-first-wants-v7": {
+			Index:    v7First,
+			Variant:  "v7",
+			Expected: "arm-v7",
+		},
+		"exact-variant-wins": {
+			Index:    noVariantFirst,
+			Variant:  "v6",
+			Expected: "arm-v6",
+		},
+		"no-variant-matches-any": {
+			Index:    noVariantFirst,
+			Variant:  "v7",
+			Expected: "arm",
+		},
+		"any-variant": {
+			Index:    v7First,
+			Expected: "arm-v7",
+		},
+		"missing-variant": {
+			Index:   v7First,
+			Variant: "v5",
+			Error:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			manifests := []descriptor{}
+
+			err := json.Unmarshal([]byte(tc.Index), &manifests)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := &Client{os: "linux", arch: "arm", variant: tc.Variant}
+
+			d, err := client.selectPlatform(manifests)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", d.Digest)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if d.Digest != tc.Expected {
+				t.Errorf("got %q; want %q", d.Digest, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestGetAssetStreamArtifact(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.server.Close()
+
+	const fileType = "application/vnd.oci.image.layer.v1.tar"
+
+	single := reg.addBlob(fileType, []byte("archive"), map[string]string{annotationTitle: "tool_linux_amd64.tar.gz"})
+	reg.addManifest("org/tool", "single", manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers:    []descriptor{single},
+	})
+
+	reg.addManifest("org/tool", "multi", manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers: []descriptor{
+			reg.addBlob(fileType, []byte("one"), map[string]string{annotationTitle: "bin/tool"}),
+			reg.addBlob(fileType, []byte("two"), map[string]string{annotationTitle: "LICENSE"}),
+		},
+	})
+
+	corrupt := single
+	corrupt.Digest = digestOfBytes([]byte("something else"))
+	reg.blobs[corrupt.Digest] = []byte("archive")
+	reg.addManifest("org/tool", "corrupt", manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers:    []descriptor{corrupt},
+	})
+
+	host := strings.TrimPrefix(reg.server.URL, "http://")
+
+	client, err := NewClient(&NewClientInput{Reference: host + "/org/tool", OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("single-file", func(t *testing.T) {
+		stream, assetName, err := client.GetAssetStream(nil, nil, host+"/org/tool:single")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer stream.Close()
+
+		b, err := io.ReadAll(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if assetName != "tool_linux_amd64.tar.gz" || string(b) != "archive" {
+			t.Errorf("got %q (%q); want %q (%q)", assetName, string(b), "tool_linux_amd64.tar.gz", "archive")
+		}
+	})
+
+	t.Run("multiple-files", func(t *testing.T) {
+		stream, _, err := client.GetAssetStream(nil, nil, host+"/org/tool:multi")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer stream.Close()
+
+		actual := readTar(t, stream)
+		expected := map[string]string{"bin/tool": "one", "LICENSE": "two"}
+
+		if fmt.Sprint(actual) != fmt.Sprint(expected) {
+			t.Errorf("got %v; want %v", actual, expected)
+		}
+	})
+
+	t.Run("checksum-mismatch", func(t *testing.T) {
+		stream, _, err := client.GetAssetStream(nil, nil, host+"/org/tool:corrupt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer stream.Close()
+
+		_, err = io.ReadAll(stream)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("expected a checksum mismatch; got %v", err)
+		}
+	})
+}
+
+func TestGetLatestTag(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.server.Close()
+
+	reg.tags["org/tool"] = []string{"1.0.0", "latest", "1.10.0", "sha256-abc.sig", "1.9.3"}
+
+	host := strings.TrimPrefix(reg.server.URL, "http://")
+
+	client, err := NewClient(&NewClientInput{Reference: host + "/org/tool:{{.Ver}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Expected   string
+	}{
+		"latest": {
+			Expected: "1.10.0",
+		},
+		"constrained": {
+			Constraint: "< 1.10",
+			Expected:   "1.9.3",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := client.GetLatestTag("", "", tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected Reference
+	}{
+		"docker-hub-official": {
+			Input:    "alpine",
+			Expected: Reference{Registry: "registry-1.docker.io", Repository: "library/alpine", Tag: "latest"},
+		},
+		"docker-hub-user": {
+			Input:    "hadolint/hadolint:v2.12.0",
+			Expected: Reference{Registry: "registry-1.docker.io", Repository: "hadolint/hadolint", Tag: "v2.12.0"},
+		},
+		"ghcr": {
+			Input:    "ghcr.io/org/team/tool:1.2.3",
+			Expected: Reference{Registry: "ghcr.io", Repository: "org/team/tool", Tag: "1.2.3"},
+		},
+		"port": {
+			Input:    "localhost:5000/tool",
+			Expected: Reference{Registry: "localhost:5000", Repository: "tool", Tag: "latest"},
+		},
+		"digest": {
+			Input:    "ghcr.io/org/tool@sha256:abc",
+			Expected: Reference{Registry: "ghcr.io", Repository: "org/tool", Tag: "sha256:abc"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseReference(tc.Input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Errorf("got %+v; want %+v", actual, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	defaultTag        = "latest"
+)
+
+// Reference is a parsed image or artifact reference, such as
+// `ghcr.io/org/tool:1.2.3`.
+type Reference struct {
+	// Registry is the host (and optional port) of the registry.
+	Registry string
+
+	// Repository is the path of the repository within the registry.
+	Repository string
+
+	// Tag is the tag, or the digest (e.g., `sha256:…`) if the reference was
+	// pinned with `@`.
+	Tag string
+}
+
+// ParseReference parses a reference. As with `docker pull`, references without
+// a registry are looked up on Docker Hub, and the tag defaults to `latest`.
+func ParseReference(ref string) (Reference, error) {
+	r := Reference{}
+
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://")
+	if ref == "" {
+		return r, errors.New("empty image reference")
+	}
+
+	name := ref
+
+	if i := strings.Index(ref, "@"); i >= 0 {
+		name, r.Tag = ref[:i], ref[i+1:]
+	} else if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, r.Tag = ref[:i], ref[i+1:]
+	}
+
+	if r.Tag == "" {
+		r.Tag = defaultTag
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	} else {
+		r.Registry, r.Repository = dockerHub, name
+	}
+
+	if r.Registry == dockerHub {
+		r.Registry = dockerHubRegistry
+
+		if !strings.Contains(r.Repository, "/") {
+			r.Repository = "library/" + r.Repository
+		}
+	}
+
+	if r.Repository == "" {
+		return r, errors.Errorf("invalid image reference: %s", ref)
+	}
+
+	return r, nil
+}
+
+// String returns the reference in its canonical form.
+func (r Reference) String() string {
+	if strings.Contains(r.Tag, ":") {
+		return r.Registry + "/" + r.Repository + "@" + r.Tag
+	}
+
+	return r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// scheme returns the URL scheme to use for the registry. Like Docker, registries
+// on the local machine are assumed to be plain HTTP.
+func (r Reference) scheme() string {
+	host := r.Registry
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	switch strings.Trim(host, "[]") {
+	case "localhost", "127.0.0.1", "::1":
+		return "http"
+	default:
+		return "https"
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/northwood-labs/download-asset/provider"
+)
+
+// Docker Hub credentials are stored under its legacy index URL.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// LookupToken finds credentials for the registry behind the reference. It
+// checks, in order:
+//
+//  1. OCI_TOKEN (a bare token, or `username:password`).
+//  2. hostTokens, by the registry's hostname (`docker.io` for Docker Hub).
+//  3. ~/.netrc.
+//  4. A matching `auths` entry in Docker's config.json (as written by
+//     `docker login`), honoring DOCKER_CONFIG.
+func LookupToken(reference string, hostTokens map[string]string) (token, source string) { // lint:allow_named_returns
+	if v := os.Getenv("OCI_TOKEN"); v != "" {
+		return v, "$OCI_TOKEN"
+	}
+
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", ""
+	}
+
+	host := ref.Registry
+	if host == dockerHubRegistry {
+		host = dockerHub
+	}
+
+	if v := hostTokens[host]; v != "" {
+		return v, "config file (" + host + ")"
+	}
+
+	if v, path := provider.NetrcToken(host); v != "" {
+		return v, path
+	}
+
+	return dockerConfigToken(host)
+}
+
+// dockerConfigToken reads the `username:password` for a registry from Docker's
+// config.json. Credential helpers are not supported.
+func dockerConfigToken(host string) (token, source string) { // lint:allow_named_returns
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	path := filepath.Join(dir, "config.json")
+
+	b, err := os.ReadFile(path) // lint:allow_include_file
+	if err != nil {
+		return "", ""
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return "", ""
+	}
+
+	keys := []string{host, "https://" + host}
+	if host == dockerHub {
+		keys = append(keys, dockerHubAuthKey)
+	}
+
+	for _, k := range keys {
+		auth, ok := config.Auths[k]
+		if !ok || auth.Auth == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil || !strings.Contains(string(decoded), ":") {
+			continue
+		}
+
+		return string(decoded), path
+	}
+
+	return "", ""
+}
@@ -0,0 +1,195 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/binary"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/cpu"
+)
+
+const (
+	// atHWCAP is the auxiliary vector entry which holds the CPU's capabilities.
+	atHWCAP = 16
+
+	// HWCAP bits on 32-bit ARM, from the Linux kernel's asm/hwcap.h.
+	hwcapVFP   = 1 << 6
+	hwcapNEON  = 1 << 12
+	hwcapVFPv3 = 1 << 13
+	hwcapLPAE  = 1 << 20
+
+	// minArmVersion is the oldest ARM version which Go supports, and
+	// maxArm32Version is the newest one which 32-bit ARM binaries are built for.
+	minArmVersion   = 5
+	maxArm32Version = 7
+
+	// defaultArmVersion is assumed when the ARM version can't be detected.
+	// ARMv6 binaries also run on ARMv7.
+	defaultArmVersion = 6
+)
+
+var (
+	reArmModel = regexp.MustCompile(`(?m)^(?:model name|Processor)\s*:.*\bARMv(\d+)`)
+	reArmArch  = regexp.MustCompile(`(?m)^CPU architecture\s*:\s*(\d+)`)
+)
+
+// ArmVersion returns the ARM architecture version of the current CPU (e.g., `7`
+// for ARMv7), for use in asset names like `armv7`. It is `8` for 64-bit ARM,
+// and an empty string on other architectures.
+//
+// On 32-bit ARM, it is read from /proc/cpuinfo, then from the capabilities in
+// the auxiliary vector. ARMv8 CPUs running 32-bit code are ARMv7, which is the
+// newest version that 32-bit binaries are built for.
+func ArmVersion() string {
+	if runtime.GOARCH == "arm64" {
+		return "8"
+	}
+
+	if runtime.GOARCH != "arm" {
+		return ""
+	}
+
+	cpuinfo, _ := os.ReadFile("/proc/cpuinfo") // lint:allow_unhandled
+	auxv, _ := os.ReadFile("/proc/self/auxv")  // lint:allow_unhandled
+
+	return strconv.Itoa(armVersion(string(cpuinfo), auxv))
+}
+
+// armVersion works out the version of a 32-bit ARM CPU. The model name comes
+// first, since ARMv6 CPUs (e.g., the first Raspberry Pi) claim to be
+// architecture 7.
+func armVersion(cpuinfo string, auxv []byte) int {
+	if m := reArmModel.FindStringSubmatch(cpuinfo); m != nil {
+		if v, err := strconv.Atoi(m[1]); err == nil {
+			return clampArmVersion(v)
+		}
+	}
+
+	if caps, ok := hwcap(auxv, 4); ok { // lint:allow_raw_number
+		switch {
+		case caps&(hwcapVFPv3|hwcapNEON|hwcapLPAE) != 0:
+			return maxArm32Version
+		case caps&hwcapVFP != 0:
+			return defaultArmVersion
+		default:
+			return minArmVersion
+		}
+	}
+
+	if m := reArmArch.FindStringSubmatch(cpuinfo); m != nil {
+		if v, err := strconv.Atoi(m[1]); err == nil {
+			return clampArmVersion(v)
+		}
+	}
+
+	return defaultArmVersion
+}
+
+func clampArmVersion(v int) int {
+	return max(minArmVersion, min(v, maxArm32Version))
+}
+
+// hwcap finds AT_HWCAP in an auxiliary vector (e.g., /proc/self/auxv), which is
+// a list of type and value pairs, each of which is wordSize bytes long.
+func hwcap(auxv []byte, wordSize int) (uint64, bool) {
+	word := func(b []byte) uint64 {
+		if wordSize == 8 { // lint:allow_raw_number
+			return binary.LittleEndian.Uint64(b)
+		}
+
+		return uint64(binary.LittleEndian.Uint32(b))
+	}
+
+	for i := 0; i+2*wordSize <= len(auxv); i += 2 * wordSize {
+		if word(auxv[i:]) == atHWCAP {
+			return word(auxv[i+wordSize:]), true
+		}
+	}
+
+	return 0, false
+}
+
+// Amd64Level returns the x86-64 microarchitecture level of the current CPU
+// (`v1` to `v4`, as in GOAMD64), or an empty string on other architectures.
+//
+// The levels are checked with CPUID, using the features which tell them apart
+// in practice: SSE4.2 and POPCNT for v2, AVX2, BMI2 and FMA for v3, and the
+// AVX-512 basics for v4.
+func Amd64Level() string {
+	if runtime.GOARCH != "amd64" {
+		return ""
+	}
+
+	x := cpu.X86
+
+	v2 := x.HasCX16 && x.HasPOPCNT && x.HasSSE3 && x.HasSSSE3 && x.HasSSE41 && x.HasSSE42
+	v3 := v2 && x.HasAVX && x.HasAVX2 && x.HasBMI1 && x.HasBMI2 && x.HasFMA && x.HasOSXSAVE
+	v4 := v3 && x.HasAVX512F && x.HasAVX512BW && x.HasAVX512CD && x.HasAVX512DQ && x.HasAVX512VL
+
+	switch {
+	case v4:
+		return "v4"
+	case v3:
+		return "v3"
+	case v2:
+		return "v2"
+	default:
+		return "v1"
+	}
+}
+
+// ArmVersions returns the ARM versions whose binaries run on a CPU of version v,
+// newest first (e.g., `7`, `6`, `5`). It only returns v if it is not a 32-bit
+// ARM version.
+func ArmVersions(v string) []string {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minArmVersion || n > maxArm32Version {
+		return []string{v}
+	}
+
+	versions := make([]string, 0, n-minArmVersion+1)
+	for ; n >= minArmVersion; n-- {
+		versions = append(versions, strconv.Itoa(n))
+	}
+
+	return versions
+}
+
+// Amd64Levels returns the x86-64 levels whose binaries run on a CPU of level,
+// best first (e.g., `v3`, `v2`, `v1`). The last one is an empty string, for the
+// baseline builds which don't name a level. It only returns level if it is not
+// a level.
+func Amd64Levels(level string) []string {
+	var n int
+
+	if len(level) == 2 && level[0] == 'v' { // lint:allow_raw_number
+		n, _ = strconv.Atoi(level[1:]) // lint:allow_unhandled
+	}
+
+	if n < 1 || n > 4 { // lint:allow_raw_number
+		return []string{level}
+	}
+
+	levels := make([]string, 0, n+1)
+	for ; n >= 1; n-- {
+		levels = append(levels, "v"+strconv.Itoa(n))
+	}
+
+	return append(levels, "")
+}
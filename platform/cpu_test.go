@@ -0,0 +1,121 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// auxv32 builds a 32-bit auxiliary vector from type and value pairs.
+func auxv32(pairs ...uint32) []byte {
+	b := make([]byte, 0, len(pairs)*4) // lint:allow_raw_number
+
+	for _, p := range pairs {
+		b = binary.LittleEndian.AppendUint32(b, p)
+	}
+
+	return b
+}
+
+func TestArmVersion(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		CPUInfo  string
+		Auxv     []byte
+		Expected int
+	}{
+		"raspberry-pi-1": {
+			CPUInfo:  "model name\t: ARMv6-compatible processor rev 7 (v6l)\nCPU architecture: 7\n",
+			Expected: 6,
+		},
+		"old-kernel": {
+			CPUInfo:  "Processor\t: ARMv7 Processor rev 4 (v7l)\nCPU architecture: 7\n",
+			Expected: 7,
+		},
+		"armv8-in-32-bit": {
+			CPUInfo:  "model name\t: ARMv8 Processor rev 4 (v8l)\nCPU architecture: 8\n",
+			Expected: 7,
+		},
+		"hwcap-neon": {
+			CPUInfo:  "CPU architecture: 6\n",
+			Auxv:     auxv32(6, 4096, 16, hwcapVFP|hwcapNEON|hwcapVFPv3, 0, 0),
+			Expected: 7,
+		},
+		"hwcap-vfp": {
+			Auxv:     auxv32(16, hwcapVFP, 0, 0),
+			Expected: 6,
+		},
+		"hwcap-none": {
+			Auxv:     auxv32(16, 0, 0, 0),
+			Expected: 5,
+		},
+		"cpu-architecture": {
+			CPUInfo:  "processor\t: 0\nCPU architecture: 7\n",
+			Expected: 7,
+		},
+		"unknown": {
+			Expected: 6,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := armVersion(tc.CPUInfo, tc.Auxv); got != tc.Expected {
+				t.Errorf("got %d; want %d", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestArmVersions(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Version  string
+		Expected []string
+	}{
+		"armv7":   {Version: "7", Expected: []string{"7", "6", "5"}},
+		"armv6":   {Version: "6", Expected: []string{"6", "5"}},
+		"arm64":   {Version: "8", Expected: []string{"8"}},
+		"not-arm": {Version: "", Expected: []string{""}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := ArmVersions(tc.Version); !slices.Equal(got, tc.Expected) {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestAmd64Levels(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Level    string
+		Expected []string
+	}{
+		"v3":        {Level: "v3", Expected: []string{"v3", "v2", "v1", ""}},
+		"v1":        {Level: "v1", Expected: []string{"v1", ""}},
+		"not-amd64": {Level: "", Expected: []string{""}},
+		"invalid":   {Level: "v9", Expected: []string{"v9"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Amd64Levels(tc.Level); !slices.Equal(got, tc.Expected) {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"debug/elf"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	// LibcGNU is the GNU C library (glibc), which most Linux distributions use.
+	LibcGNU = "gnu"
+
+	// LibcMusl is musl, which Alpine and other small distributions use.
+	LibcMusl = "musl"
+)
+
+// shell is a binary which every Linux system has, and which is dynamically
+// linked against the system's C library.
+const shell = "/bin/sh"
+
+// Libc returns the C library of the current system: LibcGNU or LibcMusl on
+// Linux, or an empty string elsewhere.
+//
+// It looks at the dynamic loader which /bin/sh asks for first, then at the
+// output of `ldd --version`. If neither says, it assumes glibc.
+func Libc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+
+	if interp, err := interpreter(shell); err == nil {
+		if libc := libcFromInterpreter(interp); libc != "" {
+			return libc
+		}
+	}
+
+	// musl's ldd exits with an error for --version, but still prints its name.
+	out, _ := exec.Command("ldd", "--version").CombinedOutput() // lint:allow_unhandled
+	if libc := libcFromLdd(string(out)); libc != "" {
+		return libc
+	}
+
+	return LibcGNU
+}
+
+// interpreter returns the dynamic loader which an ELF binary asks for (e.g.,
+// `/lib/ld-musl-x86_64.so.1`), or an empty string if it is statically linked.
+func interpreter(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+
+		b, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(b), "\x00"), nil
+	}
+
+	return "", nil
+}
+
+// libcFromInterpreter names the C library which a dynamic loader belongs to, or
+// returns an empty string if it is not known.
+func libcFromInterpreter(interp string) string {
+	name := interp[strings.LastIndex(interp, "/")+1:]
+
+	switch {
+	case strings.HasPrefix(name, "ld-musl"):
+		return LibcMusl
+	case strings.HasPrefix(name, "ld-linux"), strings.HasPrefix(name, "ld64.so"), strings.HasPrefix(name, "ld.so"):
+		return LibcGNU
+	default:
+		return ""
+	}
+}
+
+// libcFromLdd names the C library from the output of `ldd --version`, or returns
+// an empty string if it is not known.
+func libcFromLdd(out string) string {
+	lower := strings.ToLower(out)
+
+	switch {
+	case strings.Contains(lower, "musl"):
+		return LibcMusl
+	case strings.Contains(lower, "glibc"), strings.Contains(lower, "gnu libc"):
+		return LibcGNU
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package platform describes the platform which assets are downloaded for, in the
+forms which release assets are commonly named with.
+*/
+package platform
+
+var (
+	// tripleArchs are the architectures of Rust/LLVM target triples, by GOARCH.
+	tripleArchs = map[string]string{
+		"386":      "i686",
+		"amd64":    "x86_64",
+		"arm64":    "aarch64",
+		"loong64":  "loongarch64",
+		"mips":     "mips",
+		"mipsle":   "mipsel",
+		"mips64":   "mips64",
+		"mips64le": "mips64el",
+		"ppc64":    "powerpc64",
+		"ppc64le":  "powerpc64le",
+		"riscv64":  "riscv64gc",
+		"s390x":    "s390x",
+	}
+
+	// tripleArmArchs are the architectures of 32-bit ARM target triples, by ARM
+	// version. Rust names ARMv6 plain `arm`.
+	tripleArmArchs = map[string]string{
+		"5": "armv5te",
+		"6": "arm",
+		"7": "armv7",
+	}
+
+	// tripleOSes are the vendor and OS parts of target triples, by GOOS.
+	tripleOSes = map[string]string{
+		"darwin":    "apple-darwin",
+		"dragonfly": "unknown-dragonfly",
+		"freebsd":   "unknown-freebsd",
+		"illumos":   "unknown-illumos",
+		"linux":     "unknown-linux",
+		"netbsd":    "unknown-netbsd",
+		"openbsd":   "unknown-openbsd",
+		"solaris":   "sun-solaris",
+		"windows":   "pc-windows-msvc",
+	}
+
+	// tripleExceptions are the target triples which don't follow the pattern of
+	// the others, by GOOS/GOARCH.
+	tripleExceptions = map[string]string{
+		"aix/ppc64":     "powerpc64-ibm-aix",
+		"android/386":   "i686-linux-android",
+		"android/amd64": "x86_64-linux-android",
+		"android/arm":   "armv7-linux-androideabi",
+		"android/arm64": "aarch64-linux-android",
+		"ios/amd64":     "x86_64-apple-ios",
+		"ios/arm64":     "aarch64-apple-ios",
+		"js/wasm":       "wasm32-unknown-unknown",
+		"wasip1/wasm":   "wasm32-wasip1",
+	}
+)
+
+// Triple returns the Rust/LLVM-style target triple for a GOOS and GOARCH (e.g.,
+// `x86_64-unknown-linux-gnu` or `aarch64-apple-darwin`). On 32-bit ARM, the
+// triple depends on the ARM version, in the same form as ArmVersion (ARMv7 if
+// empty). Linux triples end with the ABI, which depends on libc (LibcGNU or
+// LibcMusl; glibc if empty). It returns an empty string for platforms which
+// have no triple (e.g., plan9).
+func Triple(goos, goarch, armVersion, libc string) string {
+	if triple, ok := tripleExceptions[goos+"/"+goarch]; ok {
+		return triple
+	}
+
+	if armVersion == "" {
+		armVersion = defaultArm32Variant
+	}
+
+	arch, ok := tripleArchs[goarch]
+	if goarch == "arm" {
+		arch, ok = tripleArmArchs[armVersion]
+	}
+
+	if !ok {
+		return ""
+	}
+
+	vendorOS, ok := tripleOSes[goos]
+	if !ok {
+		return ""
+	}
+
+	if goos != "linux" {
+		return arch + "-" + vendorOS
+	}
+
+	return arch + "-" + vendorOS + "-" + linuxABI(goarch, armVersion, libc)
+}
+
+// linuxABI is the last part of a Linux triple. 32-bit ARM uses the hard-float
+// ABI, which is what every current distribution ships, except on ARMv5 which
+// has no FPU.
+func linuxABI(goarch, armVersion, libc string) string {
+	if libc == "" {
+		libc = LibcGNU
+	}
+
+	switch {
+	case goarch == "arm" && armVersion == "5":
+		return libc + "eabi"
+	case goarch == "arm":
+		return libc + "eabihf"
+	case goarch == "mips64" || goarch == "mips64le":
+		return libc + "abi64"
+	default:
+		return libc
+	}
+}
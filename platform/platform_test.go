@@ -0,0 +1,143 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"testing"
+)
+
+func TestTriple(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		GOOS       string
+		GOARCH     string
+		ArmVersion string
+		Libc       string
+		Expected   string
+	}{
+		"linux-amd64": {GOOS: "linux", GOARCH: "amd64", Expected: "x86_64-unknown-linux-gnu"},
+		"linux-arm64": {GOOS: "linux", GOARCH: "arm64", Expected: "aarch64-unknown-linux-gnu"},
+		"linux-arm":   {GOOS: "linux", GOARCH: "arm", Expected: "armv7-unknown-linux-gnueabihf"},
+		"linux-glibc": {GOOS: "linux", GOARCH: "amd64", Libc: LibcGNU, Expected: "x86_64-unknown-linux-gnu"},
+		"linux-musl":  {GOOS: "linux", GOARCH: "amd64", Libc: LibcMusl, Expected: "x86_64-unknown-linux-musl"},
+		"linux-arm-musl": {
+			GOOS:     "linux",
+			GOARCH:   "arm",
+			Libc:     LibcMusl,
+			Expected: "armv7-unknown-linux-musleabihf",
+		},
+		"linux-armv7": {
+			GOOS:       "linux",
+			GOARCH:     "arm",
+			ArmVersion: "7",
+			Expected:   "armv7-unknown-linux-gnueabihf",
+		},
+		"linux-armv6": {
+			GOOS:       "linux",
+			GOARCH:     "arm",
+			ArmVersion: "6",
+			Expected:   "arm-unknown-linux-gnueabihf",
+		},
+		"linux-armv6-musl": {
+			GOOS:       "linux",
+			GOARCH:     "arm",
+			ArmVersion: "6",
+			Libc:       LibcMusl,
+			Expected:   "arm-unknown-linux-musleabihf",
+		},
+		"linux-armv5": {
+			GOOS:       "linux",
+			GOARCH:     "arm",
+			ArmVersion: "5",
+			Expected:   "armv5te-unknown-linux-gnueabi",
+		},
+		"linux-arm-unknown-version": {
+			GOOS:       "linux",
+			GOARCH:     "arm",
+			ArmVersion: "4",
+			Expected:   "",
+		},
+		"linux-arm64-version": {
+			GOOS:       "linux",
+			GOARCH:     "arm64",
+			ArmVersion: "8",
+			Expected:   "aarch64-unknown-linux-gnu",
+		},
+		"linux-mips64":  {GOOS: "linux", GOARCH: "mips64le", Expected: "mips64el-unknown-linux-gnuabi64"},
+		"darwin-musl":   {GOOS: "darwin", GOARCH: "arm64", Libc: LibcMusl, Expected: "aarch64-apple-darwin"},
+		"darwin-arm64":  {GOOS: "darwin", GOARCH: "arm64", Expected: "aarch64-apple-darwin"},
+		"windows-amd64": {GOOS: "windows", GOARCH: "amd64", Expected: "x86_64-pc-windows-msvc"},
+		"freebsd-amd64": {GOOS: "freebsd", GOARCH: "amd64", Expected: "x86_64-unknown-freebsd"},
+		"wasip1-wasm":   {GOOS: "wasip1", GOARCH: "wasm", Expected: "wasm32-wasip1"},
+		"js-wasm":       {GOOS: "js", GOARCH: "wasm", Expected: "wasm32-unknown-unknown"},
+		"android-arm64": {GOOS: "android", GOARCH: "arm64", Expected: "aarch64-linux-android"},
+		"android-arm":   {GOOS: "android", GOARCH: "arm", Expected: "armv7-linux-androideabi"},
+		"ios-arm64":     {GOOS: "ios", GOARCH: "arm64", Expected: "aarch64-apple-ios"},
+		"aix-ppc64":     {GOOS: "aix", GOARCH: "ppc64", Expected: "powerpc64-ibm-aix"},
+		"aix-musl":      {GOOS: "aix", GOARCH: "ppc64", Libc: LibcMusl, Expected: "powerpc64-ibm-aix"},
+		"unknown-os":    {GOOS: "plan9", GOARCH: "amd64", Expected: ""},
+		"unknown-arch":  {GOOS: "linux", GOARCH: "wasm", Expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Triple(tc.GOOS, tc.GOARCH, tc.ArmVersion, tc.Libc); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLibcFromInterpreter(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Interpreter string
+		Expected    string
+	}{
+		"musl-amd64":  {Interpreter: "/lib/ld-musl-x86_64.so.1", Expected: LibcMusl},
+		"musl-arm64":  {Interpreter: "/lib/ld-musl-aarch64.so.1", Expected: LibcMusl},
+		"glibc-amd64": {Interpreter: "/lib64/ld-linux-x86-64.so.2", Expected: LibcGNU},
+		"glibc-arm64": {Interpreter: "/lib/ld-linux-aarch64.so.1", Expected: LibcGNU},
+		"glibc-ppc64": {Interpreter: "/lib64/ld64.so.2", Expected: LibcGNU},
+		"static":      {Interpreter: "", Expected: ""},
+		"unknown":     {Interpreter: "/system/bin/linker64", Expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := libcFromInterpreter(tc.Interpreter); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLibcFromLdd(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Output   string
+		Expected string
+	}{
+		"musl":   {Output: "musl libc (x86_64)\nVersion 1.2.4\n", Expected: LibcMusl},
+		"debian": {Output: "ldd (Debian GLIBC 2.36-9+deb12u4) 2.36\n", Expected: LibcGNU},
+		"fedora": {Output: "ldd (GNU libc) 2.38\n", Expected: LibcGNU},
+		"none":   {Output: "sh: ldd: not found\n", Expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := libcFromLdd(tc.Output); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
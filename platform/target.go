@@ -0,0 +1,130 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultArm32Variant is the ARM version which Docker assumes for linux/arm.
+const defaultArm32Variant = "7"
+
+var reVariant = regexp.MustCompile(`^v\d+$`)
+
+// Platform is an OS, CPU architecture and optional variant (e.g., `v7` for
+// ARMv7, or `v3` for x86-64-v3), using Go's names as Docker and the OCI image
+// spec do.
+type Platform struct {
+	OS      string
+	Arch    string
+	Variant string
+}
+
+// Current returns the platform which this binary is running on.
+func Current() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+// Parse reads a platform in Docker's `os/arch[/variant]` format (e.g.,
+// `linux/arm64` or `linux/arm/v7`).
+func Parse(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "/")
+
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" { // lint:allow_raw_number
+		return Platform{}, errors.Errorf("invalid platform %q; expected os/arch or os/arch/variant", s)
+	}
+
+	p := Platform{OS: parts[0], Arch: parts[1]}
+
+	if len(parts) == 3 { // lint:allow_raw_number
+		p.Variant = parts[2]
+
+		if !reVariant.MatchString(p.Variant) {
+			return Platform{}, errors.Errorf("invalid platform variant %q; expected a version like v7", p.Variant)
+		}
+	}
+
+	return p, nil
+}
+
+// Override returns p with every part which is set in o replaced. The variant
+// belongs to the architecture, so it is dropped when o changes the
+// architecture without setting one.
+func (p Platform) Override(o Platform) Platform {
+	if o.OS != "" {
+		p.OS = o.OS
+	}
+
+	if o.Arch != "" && o.Arch != p.Arch {
+		p.Arch = o.Arch
+		p.Variant = ""
+	}
+
+	if o.Variant != "" {
+		p.Variant = o.Variant
+	}
+
+	return p
+}
+
+// IsCurrent reports whether p is the OS and architecture which this binary is
+// running on, so that details like the libc can be detected.
+func (p Platform) IsCurrent() bool {
+	return p.OS == runtime.GOOS && p.Arch == runtime.GOARCH
+}
+
+// ArmVersion returns the ARM version which the variant asks for, in the same
+// form as the function of the same name. 32-bit ARM defaults to ARMv7, as it
+// does for Docker.
+func (p Platform) ArmVersion() string {
+	switch p.Arch {
+	case "arm":
+		if p.Variant == "" {
+			return defaultArm32Variant
+		}
+
+		return strings.TrimPrefix(p.Variant, "v")
+	case "arm64":
+		return "8"
+	default:
+		return ""
+	}
+}
+
+// Amd64Level returns the x86-64 level which the variant asks for, in the same
+// form as the function of the same name. It defaults to the baseline, `v1`.
+func (p Platform) Amd64Level() string {
+	if p.Arch != "amd64" {
+		return ""
+	}
+
+	if p.Variant == "" {
+		return "v1"
+	}
+
+	return p.Variant
+}
+
+func (p Platform) String() string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Arch
+	}
+
+	return p.OS + "/" + p.Arch + "/" + p.Variant
+}
@@ -0,0 +1,108 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected Platform
+		Error    bool
+	}{
+		"os-arch":       {Input: "linux/arm64", Expected: Platform{OS: "linux", Arch: "arm64"}},
+		"variant":       {Input: "linux/arm/v7", Expected: Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+		"uppercase":     {Input: " Linux/AMD64/V3 ", Expected: Platform{OS: "linux", Arch: "amd64", Variant: "v3"}},
+		"missing-arch":  {Input: "linux", Error: true},
+		"empty-os":      {Input: "/arm64", Error: true},
+		"too-many":      {Input: "linux/arm/v7/extra", Error: true},
+		"wrong-variant": {Input: "linux/arm/hf", Error: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(tc.Input)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %v", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	base := Platform{OS: "linux", Arch: "arm", Variant: "v6"}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Override Platform
+		Expected string
+	}{
+		"nothing":      {Override: Platform{}, Expected: "linux/arm/v6"},
+		"os":           {Override: Platform{OS: "freebsd"}, Expected: "freebsd/arm/v6"},
+		"variant":      {Override: Platform{Variant: "v7"}, Expected: "linux/arm/v7"},
+		"same-arch":    {Override: Platform{Arch: "arm"}, Expected: "linux/arm/v6"},
+		"other-arch":   {Override: Platform{Arch: "arm64"}, Expected: "linux/arm64"},
+		"arch-variant": {Override: Platform{Arch: "amd64", Variant: "v3"}, Expected: "linux/amd64/v3"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := base.Override(tc.Override).String(); got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestPlatformVariants(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Platform   Platform
+		ArmVersion string
+		Amd64Level string
+	}{
+		"arm":       {Platform: Platform{OS: "linux", Arch: "arm"}, ArmVersion: "7"},
+		"arm-v6":    {Platform: Platform{OS: "linux", Arch: "arm", Variant: "v6"}, ArmVersion: "6"},
+		"arm64":     {Platform: Platform{OS: "linux", Arch: "arm64", Variant: "v8"}, ArmVersion: "8"},
+		"amd64":     {Platform: Platform{OS: "linux", Arch: "amd64"}, Amd64Level: "v1"},
+		"amd64-v3":  {Platform: Platform{OS: "linux", Arch: "amd64", Variant: "v3"}, Amd64Level: "v3"},
+		"riscv64":   {Platform: Platform{OS: "linux", Arch: "riscv64"}},
+		"windows64": {Platform: Platform{OS: "windows", Arch: "amd64"}, Amd64Level: "v1"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.Platform.ArmVersion(); got != tc.ArmVersion {
+				t.Errorf("ArmVersion: got %q; want %q", got, tc.ArmVersion)
+			}
+
+			if got := tc.Platform.Amd64Level(); got != tc.Amd64Level {
+				t.Errorf("Amd64Level: got %q; want %q", got, tc.Amd64Level)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// checksumReader hashes everything read through it, and returns an error
+// instead of io.EOF if the checksum does not match.
+type checksumReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected string
+}
+
+// VerifyChecksum wraps r so that it is checked against the checksum (e.g.,
+// `sha256:…`) as it is read. Reading to the end fails if it does not match.
+// Checksums without an algorithm, or with an unknown one, are not verified.
+func VerifyChecksum(r io.Reader, checksum string) io.Reader {
+	algorithm, expected, found := strings.Cut(checksum, ":")
+	if !found {
+		return r
+	}
+
+	switch algorithm {
+	case "sha256":
+		return &checksumReader{r: r, h: sha256.New(), expected: strings.ToLower(expected)}
+	case "sha512":
+		return &checksumReader{r: r, h: sha512.New(), expected: strings.ToLower(expected)}
+	default:
+		return r
+	}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if actual := hex.EncodeToString(c.h.Sum(nil)); actual != c.expected {
+			return n, errors.Errorf("checksum mismatch: expected %s, got %s", c.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+// VerifyChecksumCloser is VerifyChecksum for a stream which must be closed.
+func VerifyChecksumCloser(rc io.ReadCloser, checksum string) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: VerifyChecksum(rc, checksum),
+		Closer: rc,
+	}
+}
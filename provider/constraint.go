@@ -0,0 +1,164 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// reConstraint splits one term of a constraint into its operator and version.
+var reConstraint = regexp.MustCompile(`^\s*(=|!=|>=|<=|>|<|~>)?\s*(\S+)\s*$`)
+
+type (
+	// Constraint is a version constraint (e.g., `>= 1.2, < 2.0`) using the same
+	// syntax as hashicorp/go-version. What a version looks like depends on the
+	// version scheme (e.g., `>= 2024.10` for calver, or `< 2024-10-17` for
+	// commit-date).
+	//
+	// go-version only lets a pre-release satisfy a constraint which mentions a
+	// pre-release of the same version, so `>= 1.2` never matches `1.3.0-rc1`.
+	// When the channel allows pre-releases, a Constraint instead compares them
+	// by their ordering alone (`1.3.0-rc1` is between `1.2.0` and `1.3.0`).
+	Constraint struct {
+		terms    []constraintTerm
+		settings Settings
+	}
+
+	constraintTerm struct {
+		op  string
+		key sortKey
+
+		// segments is how many segments were written, which matters for `~>`.
+		segments int
+	}
+)
+
+// NewConstraint parses a constraint in the version scheme. An empty constraint
+// matches every version in the channel.
+func (s Settings) NewConstraint(constraint string) (*Constraint, error) {
+	c := &Constraint{settings: s}
+
+	if strings.TrimSpace(constraint) == "" {
+		return c, nil
+	}
+
+	for _, term := range strings.Split(constraint, ",") {
+		m := reConstraint.FindStringSubmatch(term)
+		if m == nil {
+			return nil, errors.Errorf("malformed constraint: %s", constraint)
+		}
+
+		if m[1] == "~>" && (s.Scheme == SchemeLexical || s.Scheme == SchemeCommitDate) {
+			return nil, errors.Errorf("~> is not supported by the %s version scheme", s.Scheme)
+		}
+
+		key, err := s.Scheme.constraintKey(m[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed constraint: %s", constraint)
+		}
+
+		core, _, _ := strings.Cut(m[2], "+")
+		if s.Scheme.isSemver() {
+			core, _, _ = strings.Cut(core, "-")
+		}
+
+		c.terms = append(c.terms, constraintTerm{
+			op:       m[1],
+			key:      key,
+			segments: len(splitSegments(core)),
+		})
+	}
+
+	return c, nil
+}
+
+// Check reports whether the tag is a version in the scheme, belongs to the
+// channel, and satisfies every term of the constraint.
+func (c *Constraint) Check(tag Tag) bool {
+	key, ok := c.settings.key(tag)
+
+	return ok && c.check(key)
+}
+
+func (c *Constraint) check(key sortKey) bool {
+	if key.ver != nil && key.ver.Prerelease() != "" && !c.settings.Channel.AllowsPrereleases() {
+		return false
+	}
+
+	for i := range c.terms {
+		if !c.terms[i].check(c.settings.Scheme, key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t *constraintTerm) check(scheme Scheme, key sortKey) bool {
+	cmp := scheme.compare(key, t.key)
+
+	switch t.op {
+	case "", "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case "~>":
+		return cmp >= 0 && t.samePrefix(key)
+	}
+
+	return false
+}
+
+// samePrefix checks the upper bound of `~>`: every segment but the last one
+// which was written must match. (`~> 1.2` allows `1.x`; `~> 1.2.3` allows
+// `1.2.x`.) This also rules out a pre-release of the next version, so
+// `~> 1.2.3` does not match `1.3.0-rc1`.
+func (t *constraintTerm) samePrefix(key sortKey) bool {
+	got, want := key.parts, t.key.parts
+
+	if key.ver != nil {
+		got, want = segmentStrings(key.ver.Segments64()), segmentStrings(t.key.ver.Segments64())
+	}
+
+	for i := 0; i < t.segments-1 && i < len(want); i++ {
+		if i >= len(got) || compareParts(got[i:i+1], want[i:i+1]) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func segmentStrings(segments []int64) []string {
+	s := make([]string, 0, len(segments))
+
+	for _, n := range segments {
+		s = append(s, strconv.FormatInt(n, 10))
+	}
+
+	return s
+}
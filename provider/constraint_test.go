@@ -0,0 +1,128 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+)
+
+func TestConstraint(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Constraint string
+		Channel    Channel
+		Version    string
+		Expected   bool
+	}{
+		"empty-stable": {
+			Version:  "1.2.3",
+			Expected: true,
+		},
+		"empty-skips-prerelease": {
+			Version:  "1.2.3-rc1",
+			Expected: false,
+		},
+		"empty-prerelease-channel": {
+			Channel:  ChannelPrerelease,
+			Version:  "1.2.3-rc1",
+			Expected: true,
+		},
+		"stable-channel-rejects-exact-prerelease": {
+			Constraint: "= 1.2.3-rc1",
+			Version:    "1.2.3-rc1",
+			Expected:   false,
+		},
+		"greater-than-prerelease": {
+			Constraint: ">= 1.2",
+			Channel:    ChannelPrerelease,
+			Version:    "1.3.0-rc1",
+			Expected:   true,
+		},
+		"prerelease-sorts-below-release": {
+			Constraint: ">= 1.3.0",
+			Channel:    ChannelPrerelease,
+			Version:    "1.3.0-rc1",
+			Expected:   false,
+		},
+		"less-than-next-major": {
+			Constraint: ">= 1.0, < 2.0",
+			Channel:    ChannelAny,
+			Version:    "2.0.0-beta.1",
+			Expected:   true,
+		},
+		"pessimistic-minor": {
+			Constraint: "~> 1.2",
+			Version:    "1.9.0",
+			Expected:   true,
+		},
+		"pessimistic-minor-next-major": {
+			Constraint: "~> 1.2",
+			Version:    "2.0.0",
+			Expected:   false,
+		},
+		"pessimistic-patch": {
+			Constraint: "~> 1.2.3",
+			Version:    "1.2.9",
+			Expected:   true,
+		},
+		"pessimistic-patch-next-minor": {
+			Constraint: "~> 1.2.3",
+			Version:    "1.3.0",
+			Expected:   false,
+		},
+		"pessimistic-patch-next-minor-prerelease": {
+			Constraint: "~> 1.2.3",
+			Channel:    ChannelPrerelease,
+			Version:    "1.3.0-rc1",
+			Expected:   false,
+		},
+		"pessimistic-patch-prerelease": {
+			Constraint: "~> 1.2.3",
+			Channel:    ChannelPrerelease,
+			Version:    "1.2.4-rc1",
+			Expected:   true,
+		},
+		"not-equal": {
+			Constraint: "!= 1.2.3",
+			Version:    "v1.2.3",
+			Expected:   false,
+		},
+		"bare-version": {
+			Constraint: "1.2",
+			Version:    "1.2.0",
+			Expected:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := Settings{Channel: tc.Channel}.NewConstraint(tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := c.Check(Tag{Name: tc.Version}); got != tc.Expected {
+				t.Errorf("%q in %q: got %t; want %t", tc.Version, tc.Constraint, got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{">= nope", ">> 1.2", "1.2,"} {
+		if _, err := (Settings{}).NewConstraint(constraint); err == nil {
+			t.Errorf("%q: expected an error", constraint)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+)
+
+func TestNextLink(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected string
+	}{
+		"empty": {
+			Input:    "",
+			Expected: "",
+		},
+		"next": {
+			Input:    `<https://x/tags?page=3>; rel="next", <https://x/tags?page=9>; rel="last"`,
+			Expected: "https://x/tags?page=3",
+		},
+		"no-next": {
+			Input:    `<https://x/tags?page=1>; rel="first", <https://x/tags?page=1>; rel="prev"`,
+			Expected: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := NextLink(tc.Input)
+
+			if actual != tc.Expected {
+				t.Errorf("got %q; want %q", actual, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NetrcToken reads the password for the first matching `machine` in the user's
+// netrc file.
+func NetrcToken(hosts ...string) (token, path string) { // lint:allow_named_returns
+	path = os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+
+		path = filepath.Join(home, name)
+	}
+
+	f, err := os.Open(path) // lint:allow_include_file
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	passwords := parseNetrc(bufio.NewScanner(f))
+
+	for i := range hosts {
+		if v := passwords[hosts[i]]; v != "" {
+			return v, path
+		}
+	}
+
+	return "", ""
+}
+
+// parseNetrc returns a map of machine name to password. Macro definitions are
+// skipped, and the `default` entry is deliberately ignored so that a token is
+// never sent to a host it wasn't meant for.
+func parseNetrc(scanner *bufio.Scanner) map[string]string {
+	passwords := map[string]string{}
+	machine := ""
+	inMacro := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				if i+1 < len(fields) {
+					i++
+					machine = fields[i]
+				}
+			case "default":
+				machine = ""
+			case "password":
+				if i+1 < len(fields) {
+					i++
+
+					if machine != "" {
+						passwords[machine] = fields[i]
+					}
+				}
+			case "login", "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	return passwords
+}
@@ -0,0 +1,137 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package provider defines the interface shared by every source of release assets
+(GitHub, GitLab, Gitea, etc.), along with the plumbing they have in common.
+
+Providers which take a token have a LookupToken function. It returns the token
+along with a short description of where it came from (e.g., `$GITHUB_TOKEN`, or
+the path of the netrc file). If no token was found, both values are empty and
+requests should be made anonymously. Every LookupToken checks the hostTokens
+from the config file (a map of hostname to token), and the user's netrc file
+with NetrcToken, along with the places which are specific to the provider.
+*/
+package provider
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	// GitHub is the name of the GitHub (and GitHub Enterprise Server) provider.
+	GitHub = "github"
+
+	// GitLab is the name of the GitLab (gitlab.com and self-managed) provider.
+	GitLab = "gitlab"
+
+	// Gitea is the name of the Gitea and Forgejo (including Codeberg) provider.
+	Gitea = "gitea"
+
+	// URL is the name of the provider which downloads assets directly from a URL
+	// template, for projects without a forge API.
+	URL = "url"
+
+	// OCI is the name of the provider which downloads from OCI registries, either
+	// as artifacts or from container images.
+	OCI = "oci"
+
+	// HashiCorp is the name of the provider which downloads from
+	// releases.hashicorp.com, or a mirror of it.
+	HashiCorp = "hashicorp"
+
+	// Golang is the name of the provider which installs Go toolchains from the
+	// go.dev download index.
+	Golang = "golang"
+)
+
+type (
+	// Provider is a source of releases and their assets.
+	Provider interface {
+		// GetLatestRelease returns the release which the provider considers to be
+		// the latest.
+		GetLatestRelease(owner, repo string) (*Release, error)
+
+		// GetReleaseVersion returns the release for a specific tag.
+		GetReleaseVersion(owner, repo, tag string) (*Release, error)
+
+		// ListReleases returns every release in no particular order, including
+		// pre-releases and drafts if the provider has them. Providers without
+		// releases (only tags or versions) return none.
+		ListReleases(owner, repo string) ([]*Release, error)
+
+		// GetLatestTag returns the tag with the highest version which satisfies
+		// the constraint. An empty constraint matches everything.
+		GetLatestTag(owner, repo, constraint string) (string, error)
+
+		// ListTags returns every tag (or version, if the provider has no tags), in
+		// no particular order.
+		ListTags(owner, repo string) ([]Tag, error)
+
+		// GetAssetStream opens the first asset of the release whose name matches
+		// the pattern. It returns the stream and the name of the asset.
+		GetAssetStream(ownerRepo []string, release *Release, pattern string) (io.ReadCloser, string, error)
+	}
+
+	// PlatformProvider is a Provider which chooses the build for a platform by
+	// itself (e.g., from an image index), rather than with a pattern.
+	PlatformProvider interface {
+		Provider
+
+		// ForPlatform returns a copy of the provider which chooses the build for
+		// another platform, using Go's names for the OS and architecture. The
+		// copy shares the original's credentials and connections.
+		ForPlatform(os, arch, variant string, settings Settings) Provider
+	}
+
+	// Tag is a provider-agnostic view of a tag.
+	Tag struct {
+		Name string
+
+		// Date is when the commit which the tag points to was made, if the
+		// provider can tell. It is only needed by the commit-date version scheme.
+		Date time.Time
+	}
+
+	// Release is a provider-agnostic view of a release.
+	Release struct {
+		TagName     string
+		Name        string
+		Body        string
+		Prerelease  bool
+		Draft       bool
+		PublishedAt time.Time
+
+		// Assets is nil if the provider can't list them (e.g., for a tag without
+		// a release), as opposed to there being none.
+		Assets []*Asset
+	}
+
+	// Asset is a downloadable file attached to a release.
+	Asset struct {
+		// ID is the provider's identifier for the asset, if it has one.
+		ID int64
+
+		// Name is the filename which patterns are matched against.
+		Name string
+
+		// URL is where the asset can be downloaded from.
+		URL string
+
+		// Checksum is the published checksum of the asset (e.g., `sha256:…`), if
+		// the provider has one.
+		Checksum string
+	}
+)
@@ -0,0 +1,360 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a failed request is retried before giving up.
+	DefaultMaxRetries = 4
+
+	// DefaultMinBackoff is the base delay used for exponential backoff.
+	DefaultMinBackoff = 500 * time.Millisecond
+
+	// DefaultMaxBackoff caps the delay between two attempts.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// RetryTransport is an http.RoundTripper which retries idempotent requests that
+// fail with a transient error, using jittered exponential backoff. It also
+// understands the rate limit headers sent by GitHub and GitLab, and will wait
+// until the limit resets before trying again (unless NoWait is set).
+type RetryTransport struct {
+	// Base is the underlying RoundTripper. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+
+	// MinBackoff and MaxBackoff bound the exponential backoff delay.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// NoWait returns rate-limited responses immediately instead of waiting for
+	// the limit to reset, so that a request fails as soon as a rate limit is
+	// hit (e.g., in CI, where waiting up to an hour is worse than failing).
+	NoWait bool
+
+	// Output receives messages about waiting. Defaults to os.Stderr.
+	Output io.Writer
+}
+
+// NewRetryTransport returns a RetryTransport wrapping base with the default settings.
+func NewRetryTransport(base http.RoundTripper, noWait bool) *RetryTransport {
+	return &RetryTransport{
+		Base:       base,
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		NoWait:     noWait,
+		Output:     os.Stderr,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return t.base().RoundTrip(req)
+	}
+
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	// Downloads can be interrupted mid-stream by the CDN. If the server supports
+	// range requests, pick up where we left off instead of failing the download.
+	if resp.StatusCode == http.StatusOK &&
+		resp.Header.Get("Accept-Ranges") == "bytes" &&
+		resp.Header.Get("Content-Encoding") == "" &&
+		resp.ContentLength > 0 {
+		resp.Body = &resumableBody{
+			transport: t,
+			req:       req,
+			body:      resp.Body,
+			size:      resp.ContentLength,
+		}
+	}
+
+	return resp, nil
+}
+
+func (t *RetryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	for attempt := 0; ; attempt++ {
+		r := req
+
+		// The last attempt read the body, so later ones need a new copy.
+		if attempt > 0 {
+			r, err = rewind(req)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err = t.base().RoundTrip(r)
+
+		wait, retry := t.shouldRetry(resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body) // lint:allow_unhandled
+			_ = resp.Body.Close()                 // lint:allow_unhandled
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+
+			return nil, errors.Wrap(req.Context().Err(), "request cancelled while waiting to retry")
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry decides whether a request should be attempted again, and how long
+// to wait before doing so.
+func (t *RetryTransport) shouldRetry(resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if attempt >= t.MaxRetries {
+			return 0, false
+		}
+
+		return t.backoff(attempt), true
+	}
+
+	if wait, limited := rateLimitWait(resp); limited {
+		// Rate limits are retried a limited number of times too, so that a
+		// server which keeps saying "retry now" can't keep the loop going.
+		if t.NoWait || attempt >= t.MaxRetries {
+			return 0, false
+		}
+
+		wait = max(wait, t.backoff(attempt))
+
+		fmt.Fprintf(
+			t.output(),
+			"API rate limit reached; waiting %s (until %s) before retrying.\n",
+			wait.Round(time.Second),
+			time.Now().Add(wait).Format(time.Kitchen),
+		)
+
+		return wait, true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		if attempt >= t.MaxRetries {
+			return 0, false
+		}
+
+		return t.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns an exponential delay for the given attempt, with the upper
+// half of the delay randomized so that concurrent clients spread out.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.MinBackoff << attempt
+	if ceiling <= 0 || ceiling > t.MaxBackoff {
+		ceiling = t.MaxBackoff
+	}
+
+	half := ceiling / 2 // lint:allow_raw_number
+	if half <= 0 {
+		return ceiling
+	}
+
+	return half + rand.N(half)
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+func (t *RetryTransport) output() io.Writer {
+	if t.Output == nil {
+		return os.Stderr
+	}
+
+	return t.Output
+}
+
+// rateLimitWait inspects a response for rate limit signals: `Retry-After` (used
+// for GitHub's secondary rate limits, and by most other APIs), GitHub's
+// `X-RateLimit-*` headers, and GitLab's `RateLimit-*` headers.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if when, err := http.ParseTime(retryAfter); err == nil {
+			return max(time.Until(when), 0), true
+		}
+	}
+
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+
+		reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Give the clocks a second to agree with each other.
+		return max(time.Until(time.Unix(reset, 0)), 0) + time.Second, true
+	}
+
+	return 0, false
+}
+
+// isIdempotent reports whether a request can be sent again. Requests with a
+// body are only retried if the body can be read again, with GetBody.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	default:
+		return false
+	}
+}
+
+// rewind returns a copy of the request with a new copy of its body.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the request body again")
+	}
+
+	r.Body = body
+
+	return r, nil
+}
+
+// contentRangeStart returns the offset of the first byte in a 206 response,
+// from its `Content-Range` header (e.g., `bytes 100-199/200`).
+func contentRangeStart(resp *http.Response) (int64, bool) {
+	var start, end int64
+
+	_, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end)
+
+	return start, err == nil
+}
+
+// resumableBody re-requests the remainder of a response body with a Range
+// header when the connection is interrupted mid-stream.
+type resumableBody struct {
+	transport *RetryTransport
+	req       *http.Request
+	body      io.ReadCloser
+	read      int64
+	size      int64
+	resumes   int
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+
+	if err == nil || err == io.EOF || b.read >= b.size {
+		return n, err
+	}
+
+	if b.resumes >= b.transport.MaxRetries {
+		return n, errors.Wrap(err, "download interrupted")
+	}
+
+	b.resumes++
+	_ = b.body.Close() // lint:allow_unhandled
+
+	req, rerr := rewind(b.req)
+	if rerr != nil {
+		return n, errors.Wrap(rerr, "failed to resume interrupted download")
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.read))
+
+	resp, rerr := b.transport.roundTrip(req)
+	if rerr != nil {
+		return n, errors.Wrap(rerr, "failed to resume interrupted download")
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		_ = resp.Body.Close() // lint:allow_unhandled
+
+		return n, errors.Wrapf(err, "failed to resume interrupted download (HTTP %d)", resp.StatusCode)
+	}
+
+	// A server which ignores or misreads the range would repeat or skip bytes.
+	if start, ok := contentRangeStart(resp); !ok || start != b.read {
+		_ = resp.Body.Close() // lint:allow_unhandled
+
+		return n, errors.Wrapf(err, "failed to resume interrupted download (got range %q, want it to start at %d)",
+			resp.Header.Get("Content-Range"), b.read)
+	}
+
+	b.body = resp.Body
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return b.Read(p)
+}
+
+func (b *resumableBody) Close() error {
+	return errors.Wrap(b.body.Close(), "failed to close response body")
+}
@@ -0,0 +1,295 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTransport(noWait bool, output io.Writer) *RetryTransport {
+	return &RetryTransport{
+		Base:       http.DefaultTransport,
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		NoWait:     noWait,
+		Output:     output,
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Responses      []int
+		Headers        http.Header
+		NoWait         bool
+		ExpectedStatus int
+		ExpectedCalls  int32
+		ExpectedOutput string
+	}{
+		"success": {
+			Responses:      []int{http.StatusOK},
+			ExpectedStatus: http.StatusOK,
+			ExpectedCalls:  1,
+		},
+		"transient-502": {
+			Responses:      []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			ExpectedStatus: http.StatusOK,
+			ExpectedCalls:  3,
+		},
+		"gives-up": {
+			Responses:      []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			ExpectedStatus: http.StatusBadGateway,
+			ExpectedCalls:  3,
+		},
+		"not-found": {
+			Responses:      []int{http.StatusNotFound, http.StatusOK},
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCalls:  1,
+		},
+		"secondary-rate-limit": {
+			Responses:      []int{http.StatusForbidden, http.StatusOK},
+			Headers:        http.Header{"Retry-After": []string{"0"}},
+			ExpectedStatus: http.StatusOK,
+			ExpectedCalls:  2,
+			ExpectedOutput: "rate limit reached",
+		},
+		"repeated-rate-limit": {
+			Responses: []int{
+				http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests,
+				http.StatusTooManyRequests, http.StatusOK,
+			},
+			Headers:        http.Header{"Retry-After": []string{"0"}},
+			ExpectedStatus: http.StatusTooManyRequests,
+			ExpectedCalls:  3,
+			ExpectedOutput: "rate limit reached",
+		},
+		"repeated-secondary-rate-limit": {
+			Responses: []int{
+				http.StatusForbidden, http.StatusForbidden, http.StatusForbidden,
+				http.StatusForbidden, http.StatusOK,
+			},
+			Headers:        http.Header{"Retry-After": []string{"0"}},
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCalls:  3,
+			ExpectedOutput: "rate limit reached",
+		},
+		"secondary-rate-limit-no-wait": {
+			Responses:      []int{http.StatusForbidden, http.StatusOK},
+			Headers:        http.Header{"Retry-After": []string{"60"}},
+			NoWait:         true,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCalls:  1,
+		},
+		"primary-rate-limit-no-wait": {
+			Responses: []int{http.StatusForbidden, http.StatusOK},
+			Headers: http.Header{
+				"X-Ratelimit-Remaining": []string{"0"},
+				"X-Ratelimit-Reset":     []string{fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix())},
+			},
+			NoWait:         true,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCalls:  1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := calls.Add(1) - 1
+
+				if tc.Responses[i] != http.StatusOK {
+					for k, v := range tc.Headers {
+						w.Header()[k] = v
+					}
+				}
+
+				w.WriteHeader(tc.Responses[i])
+			}))
+			defer server.Close()
+
+			output := new(bytes.Buffer)
+			client := &http.Client{Transport: newTestTransport(tc.NoWait, output)}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.ExpectedStatus {
+				t.Errorf("status: got %d; want %d", resp.StatusCode, tc.ExpectedStatus)
+			}
+
+			if calls.Load() != tc.ExpectedCalls {
+				t.Errorf("calls: got %d; want %d", calls.Load(), tc.ExpectedCalls)
+			}
+
+			if !strings.Contains(output.String(), tc.ExpectedOutput) {
+				t.Errorf("output: got %q; want it to contain %q", output.String(), tc.ExpectedOutput)
+			}
+		})
+	}
+}
+
+func TestRetryTransportResumesDownload(t *testing.T) {
+	const payload = "0123456789abcdef"
+
+	var tests = map[string]struct { // lint:no_dupe
+		// ContentRange returns the Content-Range header for a range request
+		// which starts at start.
+		ContentRange func(start int) string
+		Error        bool
+	}{
+		"resumes": {
+			ContentRange: func(start int) string {
+				return fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload))
+			},
+		},
+		"wrong-start": {
+			ContentRange: func(start int) string {
+				return fmt.Sprintf("bytes %d-%d/%d", start-2, len(payload)-1, len(payload)) // lint:allow_raw_number
+			},
+			Error: true,
+		},
+		"missing-content-range": {
+			ContentRange: func(start int) string {
+				return ""
+			},
+			Error: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") == "" {
+					// Send half of the body, then drop the connection.
+					conn, buf, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Fatalf("failed to hijack connection: %v", err)
+					}
+
+					fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nAccept-Ranges: bytes\r\nContent-Length: %d\r\n\r\n", len(payload))
+					fmt.Fprint(buf, payload[:8])
+					_ = buf.Flush()
+					_ = conn.Close()
+
+					return
+				}
+
+				var start int
+
+				_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+
+				if contentRange := tc.ContentRange(start); contentRange != "" {
+					w.Header().Set("Content-Range", contentRange)
+				}
+
+				w.WriteHeader(http.StatusPartialContent)
+				fmt.Fprint(w, payload[start:])
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newTestTransport(false, io.Discard)}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", string(body))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error reading body: %v", err)
+			}
+
+			if string(body) != payload {
+				t.Errorf("got %q; want %q", string(body), payload)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRewindsBody(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "query" {
+			t.Errorf("attempt %d: got body %q; want %q", attempts.Load()+1, string(body), "query")
+		}
+
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// NewRequest sets GetBody for a strings.Reader.
+	req, err := http.NewRequest(http.MethodGet, server.URL, strings.NewReader("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := newTestTransport(false, io.Discard).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("got HTTP %d after %d attempts; want HTTP 200 after 2", resp.StatusCode, attempts.Load())
+	}
+
+	// Without GetBody, the body can't be sent again.
+	attempts.Store(0)
+
+	req, err = http.NewRequest(http.MethodGet, server.URL, io.NopCloser(strings.NewReader("query")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = newTestTransport(false, io.Discard).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts.Load() != 1 {
+		t.Errorf("got HTTP %d after %d attempts; want HTTP 503 after 1", resp.StatusCode, attempts.Load())
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SchemeSemver orders tags as semantic versions (e.g., `v1.2.3-rc1`). This
+	// is the default.
+	SchemeSemver Scheme = "semver"
+
+	// SchemeCalVer orders tags as dotted numbers (e.g., `2024.10.17` or
+	// `24.04.1`), compared segment by segment.
+	SchemeCalVer Scheme = "calver"
+
+	// SchemeLexical orders tags as plain strings.
+	SchemeLexical Scheme = "lexical"
+
+	// SchemeCommitDate orders tags by the date of the commit they point to
+	// (e.g., for `nightly` or `build-abc123` tags).
+	SchemeCommitDate Scheme = "commit-date"
+
+	// SchemeRegex orders tags by every capture group of the tag regex, in order.
+	// Groups are compared as numbers when they are numbers (e.g., `r123` with
+	// `^r(\d+)$`, or `nightly-2024-10-17` with `^nightly-(\d+)-(\d+)-(\d+)$`).
+	SchemeRegex Scheme = "regex"
+)
+
+// dateLayouts are the formats accepted for dates in a commit-date constraint.
+var (
+	dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+	reSegmentSeparator = regexp.MustCompile(`[.\-_]`)
+)
+
+type (
+	// Scheme is how versions are read from tags, compared and constrained.
+	Scheme string
+
+	// sortKey is the version of a tag, parsed according to a scheme.
+	sortKey struct {
+		ver   *version.Version // semver
+		parts []string         // calver, lexical, regex
+		date  time.Time        // commit-date
+	}
+)
+
+// ParseScheme validates the name of a version scheme. An empty name is semver.
+func ParseScheme(name string) (Scheme, error) {
+	switch s := Scheme(strings.ToLower(strings.TrimSpace(name))); s {
+	case "":
+		return SchemeSemver, nil
+	case SchemeSemver, SchemeCalVer, SchemeLexical, SchemeCommitDate, SchemeRegex:
+		return s, nil
+	default:
+		return "", errors.Errorf(
+			"unknown version scheme %q; expected semver, calver, lexical, commit-date or regex",
+			name,
+		)
+	}
+}
+
+func (s Scheme) String() string {
+	if s == "" {
+		return string(SchemeSemver)
+	}
+
+	return string(s)
+}
+
+// isSemver reports whether the scheme is semver, which is true of the zero value.
+func (s Scheme) isSemver() bool {
+	return s == "" || s == SchemeSemver
+}
+
+// key parses the version of a tag. It returns false if the tag is not a version
+// in this scheme.
+func (s Settings) key(tag Tag) (sortKey, bool) {
+	v, ok := s.TagFormat.Match(tag.Name)
+	if !ok {
+		return sortKey{}, false
+	}
+
+	switch s.Scheme {
+	case SchemeCalVer:
+		parts := splitSegments(v)
+
+		for _, p := range parts {
+			if _, err := strconv.ParseUint(p, 10, 64); err != nil {
+				return sortKey{}, false
+			}
+		}
+
+		return sortKey{parts: parts}, true
+	case SchemeLexical:
+		return sortKey{parts: []string{v}}, true
+	case SchemeCommitDate:
+		return sortKey{date: tag.Date}, !tag.Date.IsZero()
+	case SchemeRegex:
+		if s.TagFormat.Regex == nil {
+			return sortKey{}, false
+		}
+
+		m := s.TagFormat.Regex.FindStringSubmatch(tag.Name)
+		if len(m) > 1 {
+			m = m[1:]
+		}
+
+		return sortKey{parts: m}, true
+	default:
+		ver, err := version.NewVersion(v)
+		if err != nil || ver.String() == "" {
+			return sortKey{}, false
+		}
+
+		return sortKey{ver: ver}, true
+	}
+}
+
+// constraintKey parses a version written in a constraint.
+func (s Scheme) constraintKey(v string) (sortKey, error) {
+	switch s {
+	case SchemeCalVer, SchemeRegex:
+		return sortKey{parts: splitSegments(v)}, nil
+	case SchemeLexical:
+		return sortKey{parts: []string{v}}, nil
+	case SchemeCommitDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return sortKey{date: t}, nil
+			}
+		}
+
+		return sortKey{}, errors.Errorf("invalid date (expected YYYY-MM-DD or RFC 3339): %s", v)
+	default:
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			return sortKey{}, errors.Wrapf(err, "invalid version: %s", v)
+		}
+
+		return sortKey{ver: ver}, nil
+	}
+}
+
+// compare returns -1, 0 or 1 if a is lower than, equal to or higher than b.
+func (s Scheme) compare(a, b sortKey) int {
+	switch s {
+	case SchemeCommitDate:
+		return a.date.Compare(b.date)
+	case SchemeLexical:
+		return strings.Compare(a.parts[0], b.parts[0])
+	case SchemeCalVer, SchemeRegex:
+		return compareParts(a.parts, b.parts)
+	default:
+		return a.ver.Compare(b.ver)
+	}
+}
+
+// compareParts compares segment by segment, as numbers if both segments are
+// numbers, or else as strings. Missing segments are lower than any segment.
+func compareParts(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.ParseUint(a[i], 10, 64)
+		bn, bErr := strconv.ParseUint(b[i], 10, 64)
+
+		var cmp int
+
+		if aErr == nil && bErr == nil {
+			switch {
+			case an < bn:
+				cmp = -1
+			case an > bn:
+				cmp = 1
+			}
+		} else {
+			cmp = strings.Compare(a[i], b[i])
+		}
+
+		if cmp != 0 {
+			return cmp
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+
+	return 0
+}
+
+// splitSegments splits a dotted (or dashed) version into its segments, after
+// removing any leading `v`.
+func splitSegments(v string) []string {
+	return reSegmentSeparator.Split(strings.TrimPrefix(v, "v"), -1)
+}
@@ -0,0 +1,209 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheme(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected Scheme
+		Error    bool
+	}{
+		"empty":       {Input: "", Expected: SchemeSemver},
+		"semver":      {Input: "semver", Expected: SchemeSemver},
+		"calver":      {Input: "CalVer", Expected: SchemeCalVer},
+		"commit-date": {Input: " commit-date ", Expected: SchemeCommitDate},
+		"unknown":     {Input: "date", Error: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseScheme(tc.Input)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLatestTagSchemes(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	calver := []Tag{
+		{Name: "2023.12.31"},
+		{Name: "2024.9.30"},
+		{Name: "2024.10.17"},
+		{Name: "2024.10.1"},
+		{Name: "v1.2.3-rc1"},
+		{Name: "nightly"},
+	}
+
+	lexical := []Tag{{Name: "release-a"}, {Name: "release-c"}, {Name: "release-b"}}
+
+	dated := []Tag{
+		{Name: "nightly", Date: day("2024-10-18")},
+		{Name: "build-abc123", Date: day("2024-10-16")},
+		{Name: "build-def456", Date: day("2024-10-17")},
+		{Name: "no-date"},
+	}
+
+	builds := []Tag{{Name: "r9"}, {Name: "r123"}, {Name: "r45"}, {Name: "x1000"}}
+
+	nightlies := []Tag{
+		{Name: "nightly-2024-9-30"},
+		{Name: "nightly-2024-10-1"},
+		{Name: "nightly-2023-12-31"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Scheme     Scheme
+		Regex      string
+		Tags       []Tag
+		Constraint string
+		Expected   string
+		Error      bool
+	}{
+		"calver": {
+			Scheme:   SchemeCalVer,
+			Tags:     calver,
+			Expected: "2024.10.17",
+		},
+		"calver-constrained": {
+			Scheme:     SchemeCalVer,
+			Tags:       calver,
+			Constraint: "< 2024.10",
+			Expected:   "2024.9.30",
+		},
+		"calver-pessimistic": {
+			Scheme:     SchemeCalVer,
+			Tags:       calver,
+			Constraint: "~> 2023.1",
+			Expected:   "2023.12.31",
+		},
+		"lexical": {
+			Scheme:   SchemeLexical,
+			Tags:     lexical,
+			Expected: "release-c",
+		},
+		"lexical-constrained": {
+			Scheme:     SchemeLexical,
+			Tags:       lexical,
+			Constraint: "< release-c",
+			Expected:   "release-b",
+		},
+		"lexical-pessimistic": {
+			Scheme:     SchemeLexical,
+			Tags:       lexical,
+			Constraint: "~> release-a",
+			Error:      true,
+		},
+		"commit-date": {
+			Scheme:   SchemeCommitDate,
+			Tags:     dated,
+			Expected: "nightly",
+		},
+		"commit-date-constrained": {
+			Scheme:     SchemeCommitDate,
+			Tags:       dated,
+			Constraint: "< 2024-10-17",
+			Expected:   "build-abc123",
+		},
+		"commit-date-invalid": {
+			Scheme:     SchemeCommitDate,
+			Tags:       dated,
+			Constraint: "< yesterday",
+			Error:      true,
+		},
+		"regex": {
+			Scheme:   SchemeRegex,
+			Regex:    `^r(\d+)$`,
+			Tags:     builds,
+			Expected: "r123",
+		},
+		"regex-constrained": {
+			Scheme:     SchemeRegex,
+			Regex:      `^r(\d+)$`,
+			Tags:       builds,
+			Constraint: "< 100",
+			Expected:   "r45",
+		},
+		"regex-groups": {
+			Scheme:   SchemeRegex,
+			Regex:    `^nightly-(\d+)-(\d+)-(\d+)$`,
+			Tags:     nightlies,
+			Expected: "nightly-2024-10-1",
+		},
+		"regex-groups-pessimistic": {
+			Scheme:     SchemeRegex,
+			Regex:      `^nightly-(\d+)-(\d+)-(\d+)$`,
+			Tags:       nightlies,
+			Constraint: "~> 2024.9.1",
+			Expected:   "nightly-2024-9-30",
+		},
+		"semver-default": {
+			Tags:     []Tag{{Name: "nightly"}, {Name: "2024.10.17.1.2"}, {Name: "r1"}},
+			Expected: "2024.10.17.1.2",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewTagFormat("", tc.Regex)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := Settings{Scheme: tc.Scheme, TagFormat: f}.LatestTag(tc.Tags, tc.Constraint)
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected an error; got %q", tag)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ChannelStable only considers stable releases. This is the default.
+	ChannelStable Channel = "stable"
+
+	// ChannelPrerelease also considers pre-releases (e.g., `-rc1`, `-beta.2`).
+	ChannelPrerelease Channel = "prerelease"
+
+	// ChannelAny considers everything, including draft releases (which are only
+	// visible with a token that can see them).
+	ChannelAny Channel = "any"
+)
+
+type (
+	// Channel is the set of releases which can be chosen from.
+	Channel string
+
+	// Settings control how a provider chooses between versions and assets. They
+	// are shared by every provider, and the zero value chooses the highest stable
+	// version and the first matching asset.
+	Settings struct {
+		Channel Channel
+
+		// TagFormat selects the tags which hold versions, and where the version
+		// is in them.
+		TagFormat TagFormat
+
+		// Scheme is how the versions in tags are compared and constrained.
+		Scheme Scheme
+
+		// Libc is the C library of the current system (e.g., platform.LibcMusl),
+		// which picks between assets that match the same pattern.
+		Libc string
+	}
+)
+
+// LatestByVersion reports whether the latest release has to be found by
+// comparing versions, instead of asking the provider which release it considers
+// to be the latest. That only takes stable releases into account, and in a
+// monorepo, it may belong to a different component.
+func (s Settings) LatestByVersion() bool {
+	return s.Channel.AllowsPrereleases() || !s.TagFormat.IsZero()
+}
+
+// ParseChannel validates the name of a channel. An empty name is the stable
+// channel.
+func ParseChannel(name string) (Channel, error) {
+	switch c := Channel(strings.ToLower(strings.TrimSpace(name))); c {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelPrerelease, ChannelAny:
+		return c, nil
+	default:
+		return "", errors.Errorf("unknown channel %q; expected stable, prerelease or any", name)
+	}
+}
+
+func (c Channel) String() string {
+	if c == "" {
+		return string(ChannelStable)
+	}
+
+	return string(c)
+}
+
+// AllowsPrereleases reports whether pre-releases may be chosen.
+func (c Channel) AllowsPrereleases() bool {
+	return c == ChannelPrerelease || c == ChannelAny
+}
+
+// AllowsDrafts reports whether draft releases may be chosen.
+func (c Channel) AllowsDrafts() bool {
+	return c == ChannelAny
+}
+
+// Allows reports whether a release belongs to the channel. A release counts as
+// a pre-release if it is flagged as one, or if the version in its tag is one.
+func (c Channel) Allows(release *Release, format TagFormat) bool {
+	if release.Draft && !c.AllowsDrafts() {
+		return false
+	}
+
+	if (release.Prerelease || format.IsPrerelease(release.TagName)) && !c.AllowsPrereleases() {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,269 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseChannel(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Input    string
+		Expected Channel
+		Error    bool
+	}{
+		"empty":      {Input: "", Expected: ChannelStable},
+		"stable":     {Input: "stable", Expected: ChannelStable},
+		"prerelease": {Input: "Prerelease", Expected: ChannelPrerelease},
+		"any":        {Input: " any ", Expected: ChannelAny},
+		"unknown":    {Input: "nightly", Error: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseChannel(tc.Input)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLatestTag(t *testing.T) {
+	tags := []Tag{
+		{Name: "v1.9.0"},
+		{Name: "v1.10.0"},
+		{Name: "v1.11.0-rc.1"},
+		{Name: "v2.0.0-beta.1"},
+		{Name: "not-a-version"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Channel    Channel
+		Constraint string
+		Expected   string
+		Error      bool
+	}{
+		"stable": {
+			Expected: "v1.10.0",
+		},
+		"prerelease": {
+			Channel:  ChannelPrerelease,
+			Expected: "v2.0.0-beta.1",
+		},
+		"prerelease-constrained": {
+			Channel:    ChannelPrerelease,
+			Constraint: "< 2.0",
+			Expected:   "v2.0.0-beta.1",
+		},
+		"prerelease-pessimistic": {
+			Channel:    ChannelPrerelease,
+			Constraint: "~> 1.9",
+			Expected:   "v1.11.0-rc.1",
+		},
+		"no-match": {
+			Constraint: "> 3.0",
+			Error:      true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := Settings{Channel: tc.Channel}.LatestTag(tags, tc.Constraint)
+			if tc.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tag != tc.Expected {
+				t.Errorf("got %q; want %q", tag, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	releases := []*Release{
+		{TagName: "v1.10.0"},
+		{TagName: "v1.9.0"},
+		{TagName: "v1.11.0", Prerelease: true},
+		{TagName: "v1.12.0-rc1"},
+		{TagName: "v2.0.0", Draft: true},
+		{TagName: "nightly"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Channel  Channel
+		Expected string
+	}{
+		"stable": {
+			Expected: "v1.10.0",
+		},
+		"prerelease": {
+			Channel:  ChannelPrerelease,
+			Expected: "v1.12.0-rc1",
+		},
+		"any": {
+			Channel:  ChannelAny,
+			Expected: "v2.0.0",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			release, err := Settings{Channel: tc.Channel}.LatestRelease(releases)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if release.TagName != tc.Expected {
+				t.Errorf("got %q; want %q", release.TagName, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestSortReleases(t *testing.T) {
+	releases := []*Release{
+		{TagName: "v1.9.0"},
+		{TagName: "v1.10.0"},
+		{TagName: "v1.11.0-rc.1"},
+		{TagName: "v2.0.0", Draft: true},
+		{TagName: "nightly"},
+		{TagName: "v1.2.0"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Channel    Channel
+		Constraint string
+		Expected   []string
+	}{
+		"stable": {
+			Expected: []string{"v1.10.0", "v1.9.0", "v1.2.0"},
+		},
+		"any": {
+			Channel:  ChannelAny,
+			Expected: []string{"v2.0.0", "v1.11.0-rc.1", "v1.10.0", "v1.9.0", "v1.2.0"},
+		},
+		"constrained": {
+			Constraint: ">= 1.5",
+			Expected:   []string{"v1.10.0", "v1.9.0"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			sorted, err := Settings{Channel: tc.Channel}.SortReleases(releases, tc.Constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, 0, len(sorted))
+			for i := range sorted {
+				got = append(got, sorted[i].TagName)
+			}
+
+			if !slices.Equal(got, tc.Expected) {
+				t.Errorf("got %q; want %q", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestMatchAsset(t *testing.T) {
+	assets := []*Asset{
+		{Name: "tool-x86_64-unknown-linux-gnu.tar.gz"},
+		{Name: "tool-x86_64-unknown-linux-musl.tar.gz"},
+		{Name: "tool-x86_64-linux.tar.gz"},
+		{Name: "tool-aarch64-linux-musl.tar.gz"},
+	}
+
+	var tests = map[string]struct { // lint:no_dupe
+		Libc     string
+		Pattern  string
+		Expected string
+		Error    bool
+	}{
+		"no-libc": {
+			Pattern:  `x86_64-.*linux`,
+			Expected: "tool-x86_64-unknown-linux-gnu.tar.gz",
+		},
+		"musl": {
+			Libc:     "musl",
+			Pattern:  `x86_64-.*linux`,
+			Expected: "tool-x86_64-unknown-linux-musl.tar.gz",
+		},
+		"gnu": {
+			Libc:     "gnu",
+			Pattern:  `x86_64-.*linux`,
+			Expected: "tool-x86_64-unknown-linux-gnu.tar.gz",
+		},
+		"musl-without-build": {
+			Libc:     "musl",
+			Pattern:  `x86_64-(unknown-linux-gnu|linux)\.`,
+			Expected: "tool-x86_64-linux.tar.gz",
+		},
+		"gnu-only-musl": {
+			Libc:     "gnu",
+			Pattern:  `aarch64`,
+			Expected: "tool-aarch64-linux-musl.tar.gz",
+		},
+		"no-match": {
+			Pattern: `windows`,
+			Error:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			asset, err := Settings{Libc: tc.Libc}.MatchAsset(assets, tc.Pattern)
+			if tc.Error {
+				if err == nil {
+					t.Fatalf("expected an error; got %s", asset.Name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if asset.Name != tc.Expected {
+				t.Errorf("got %q; want %q", asset.Name, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VersionGroup is the name of the capture group which holds the version in a
+// regular expression (e.g., `^cli/v(?P<version>.+)$`).
+const VersionGroup = "version"
+
+// TagFormat describes how the version is written in a tag, so that monorepos
+// (e.g., `service/s3/v1.2.3` or `cli/v2.0.0`) can select one component's tags.
+// The zero value treats every tag as a version, with an optional `v`.
+type TagFormat struct {
+	// Prefix comes before the version (e.g., `cli/`). A `v` between the prefix
+	// and the version is optional.
+	Prefix string
+
+	// Regex matches the tags which belong to the component, and captures the
+	// version in the group named `version` (or the first group).
+	Regex *regexp.Regexp
+}
+
+// NewTagFormat creates a TagFormat from a prefix or a regular expression. At
+// most one of them may be set.
+func NewTagFormat(prefix, pattern string) (TagFormat, error) {
+	if prefix != "" && pattern != "" {
+		return TagFormat{}, errors.New("only one of tag-prefix and tag-regex may be set")
+	}
+
+	if pattern == "" {
+		return TagFormat{Prefix: prefix}, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return TagFormat{}, errors.Wrapf(err, "invalid tag regex: %s", pattern)
+	}
+
+	return TagFormat{Regex: re}, nil
+}
+
+// IsZero reports whether every tag is treated as a version.
+func (f TagFormat) IsZero() bool {
+	return f.Prefix == "" && f.Regex == nil
+}
+
+// Match returns the version part of a tag (still with any `v`), and whether the
+// tag belongs to the format at all.
+func (f TagFormat) Match(tag string) (string, bool) {
+	switch {
+	case f.Regex != nil:
+		m := f.Regex.FindStringSubmatch(tag)
+		if m == nil {
+			return "", false
+		}
+
+		v := m[captureGroup(f.Regex)]
+
+		return v, v != ""
+	case f.Prefix != "":
+		v, ok := strings.CutPrefix(tag, f.Prefix)
+
+		return v, ok && v != ""
+	default:
+		return tag, true
+	}
+}
+
+// Version returns the version in a tag without a leading `v`, for use as
+// `{{.Ver}}`. Tags which do not belong to the format are returned without the
+// `v`, as-is.
+func (f TagFormat) Version(tag string) string {
+	if v, ok := f.Match(tag); ok {
+		tag = v
+	}
+
+	return strings.TrimPrefix(tag, "v")
+}
+
+// IsPrerelease reports whether the version in a tag is a pre-release (e.g.,
+// `cli/v2.0.0-rc.1` with the `cli/` prefix).
+func (f TagFormat) IsPrerelease(tag string) bool {
+	return IsPrerelease(f.Version(tag))
+}
+
+// Candidates returns the tags to try for a tag or version given by the user,
+// with and without a `v`. If the format is a regex, a version can't be turned
+// back into a tag, so only an exact tag is returned.
+func (f TagFormat) Candidates(tagOrVersion string) []string {
+	if _, ok := f.Match(tagOrVersion); ok && !f.IsZero() {
+		return []string{tagOrVersion}
+	}
+
+	if f.Regex != nil {
+		return nil
+	}
+
+	ver := strings.TrimPrefix(tagOrVersion, "v")
+	candidates := []string{f.Prefix + tagOrVersion}
+
+	if ver == tagOrVersion {
+		return append(candidates, f.Prefix+"v"+ver)
+	}
+
+	return append(candidates, f.Prefix+ver)
+}
+
+// Find returns the tag whose version is the given one, from a list of tags.
+func (f TagFormat) Find(tags []Tag, ver string) (string, bool) {
+	ver = strings.TrimPrefix(ver, "v")
+
+	for i := range tags {
+		if v, ok := f.Match(tags[i].Name); ok && strings.TrimPrefix(v, "v") == ver {
+			return tags[i].Name, true
+		}
+	}
+
+	return "", false
+}
+
+// captureGroup returns the index of the group which holds the version: the
+// group named `version`, or else the first group, or else the whole match.
+func captureGroup(re *regexp.Regexp) int {
+	if i := re.SubexpIndex(VersionGroup); i > 0 {
+		return i
+	}
+
+	if re.NumSubexp() > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,203 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTagFormat(t *testing.T) {
+	var tests = map[string]struct { // lint:no_dupe
+		Prefix     string
+		Regex      string
+		Tag        string
+		Match      bool
+		Version    string
+		Candidates []string
+		Prerelease bool
+	}{
+		"plain": {
+			Tag:        "v1.2.3",
+			Match:      true,
+			Version:    "1.2.3",
+			Candidates: []string{"v1.2.3", "1.2.3"},
+		},
+		"plain-without-v": {
+			Tag:        "1.2.3",
+			Match:      true,
+			Version:    "1.2.3",
+			Candidates: []string{"1.2.3", "v1.2.3"},
+		},
+		"prefix": {
+			Prefix:     "cli/",
+			Tag:        "cli/v2.0.0",
+			Match:      true,
+			Version:    "2.0.0",
+			Candidates: []string{"cli/v2.0.0"},
+		},
+		"prefix-prerelease": {
+			Prefix:     "cli/",
+			Tag:        "cli/v2.0.0-rc.1",
+			Match:      true,
+			Version:    "2.0.0-rc.1",
+			Candidates: []string{"cli/v2.0.0-rc.1"},
+			Prerelease: true,
+		},
+		"prefix-other-component": {
+			Prefix:     "cli/",
+			Tag:        "sdk/v2.0.0",
+			Match:      false,
+			Version:    "sdk/v2.0.0",
+			Candidates: []string{"cli/sdk/v2.0.0", "cli/vsdk/v2.0.0"},
+		},
+		"prefix-version": {
+			Prefix:     "cli/",
+			Tag:        "2.0.0",
+			Match:      false,
+			Version:    "2.0.0",
+			Candidates: []string{"cli/2.0.0", "cli/v2.0.0"},
+		},
+		"go": {
+			Prefix:     "go",
+			Tag:        "go1.22rc1",
+			Match:      true,
+			Version:    "1.22rc1",
+			Candidates: []string{"go1.22rc1"},
+			Prerelease: true,
+		},
+		"regex-named-group": {
+			Regex:      `^service/s3/v(?P<version>[0-9.]+)$`,
+			Tag:        "service/s3/v1.50.0",
+			Match:      true,
+			Version:    "1.50.0",
+			Candidates: []string{"service/s3/v1.50.0"},
+		},
+		"regex-other-component": {
+			Regex:   `^service/s3/v(?P<version>[0-9.]+)$`,
+			Tag:     "service/ec2/v1.50.0",
+			Match:   false,
+			Version: "service/ec2/v1.50.0",
+		},
+		"regex-first-group": {
+			Regex:      `^terraform-provider-aws@(.+)$`,
+			Tag:        "terraform-provider-aws@v5.0.0",
+			Match:      true,
+			Version:    "5.0.0",
+			Candidates: []string{"terraform-provider-aws@v5.0.0"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewTagFormat(tc.Prefix, tc.Regex)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := f.Match(tc.Tag); ok != tc.Match {
+				t.Errorf("match: got %t; want %t", ok, tc.Match)
+			}
+
+			if got := f.Version(tc.Tag); got != tc.Version {
+				t.Errorf("version: got %q; want %q", got, tc.Version)
+			}
+
+			if got := f.Candidates(tc.Tag); !slices.Equal(got, tc.Candidates) {
+				t.Errorf("candidates: got %q; want %q", got, tc.Candidates)
+			}
+
+			if got := f.IsPrerelease(tc.Tag); got != tc.Prerelease {
+				t.Errorf("prerelease: got %t; want %t", got, tc.Prerelease)
+			}
+		})
+	}
+}
+
+func TestTagFormatFind(t *testing.T) {
+	f, err := NewTagFormat("", `^service/s3/v(?P<version>.+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := NewTags([]string{"service/ec2/v1.2.0", "service/s3/v1.1.0", "service/s3/v1.2.0"})
+
+	tag, ok := f.Find(tags, "1.2.0")
+	if !ok || tag != "service/s3/v1.2.0" {
+		t.Errorf("got %q (%t); want %q", tag, ok, "service/s3/v1.2.0")
+	}
+
+	if _, ok := f.Find(tags, "1.3.0"); ok {
+		t.Error("expected no match")
+	}
+}
+
+func TestNewTagFormatInvalid(t *testing.T) {
+	if _, err := NewTagFormat("cli/", "^cli/(.+)$"); err == nil {
+		t.Error("expected an error when both a prefix and a regex are set")
+	}
+
+	if _, err := NewTagFormat("", "(unclosed"); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestLatestTagFormat(t *testing.T) {
+	tags := []Tag{
+		{Name: "cli/v2.1.0"},
+		{Name: "cli/v2.2.0-rc.1"},
+		{Name: "sdk/v3.0.0"},
+		{Name: "v9.9.9"},
+		{Name: "cli/nightly"},
+	}
+
+	f, err := NewTagFormat("cli/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := Settings{TagFormat: f}.LatestTag(tags, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tag != "cli/v2.1.0" {
+		t.Errorf("got %q; want %q", tag, "cli/v2.1.0")
+	}
+
+	releases := []*Release{
+		{TagName: "sdk/v3.0.0"},
+		{TagName: "cli/v2.1.0"},
+		{TagName: "cli/v2.2.0-rc.1"},
+	}
+
+	release, err := Settings{TagFormat: f}.LatestRelease(releases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if release.TagName != "cli/v2.1.0" {
+		t.Errorf("got %q; want %q", release.TagName, "cli/v2.1.0")
+	}
+
+	release, err = Settings{Channel: ChannelPrerelease, TagFormat: f}.LatestRelease(releases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if release.TagName != "cli/v2.2.0-rc.1" {
+		t.Errorf("got %q; want %q", release.TagName, "cli/v2.2.0-rc.1")
+	}
+}
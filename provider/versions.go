@@ -0,0 +1,216 @@
+// Copyright 2023–2024, Northwood Labs
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+	"github.com/northwood-labs/download-asset/platform"
+	"github.com/pkg/errors"
+)
+
+var (
+	reMusl = regexp.MustCompile(`(?i)musl`)
+	reGNU  = regexp.MustCompile(`(?i)gnu|glibc`)
+)
+
+// LatestTag returns the tag in the channel with the highest version which
+// satisfies the constraint. Tags which do not match the tag format, or are not
+// versions in the version scheme, are skipped.
+func (s Settings) LatestTag(tags []Tag, constraint string) (string, error) {
+	constraints, err := s.NewConstraint(constraint)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create new version constraint")
+	}
+
+	i, ok := s.latest(tags, constraints)
+	if !ok {
+		return "", errors.Errorf("no matching versions found using the %s version scheme", s.Scheme)
+	}
+
+	return tags[i].Name, nil
+}
+
+// NewTags creates tags without dates, for providers which only know their names.
+func NewTags(names []string) []Tag {
+	tags := make([]Tag, 0, len(names))
+
+	for i := range names {
+		tags = append(tags, Tag{Name: names[i]})
+	}
+
+	return tags
+}
+
+// LatestRelease returns the release in the channel with the highest version.
+// Releases whose tags do not match the tag format, or are not versions in the
+// version scheme, are skipped. The commit-date scheme uses the date the release
+// was published.
+func (s Settings) LatestRelease(releases []*Release) (*Release, error) {
+	tags := make([]Tag, 0, len(releases))
+	allowed := make([]*Release, 0, len(releases))
+
+	for i := range releases {
+		if !s.Channel.Allows(releases[i], s.TagFormat) {
+			continue
+		}
+
+		tags = append(tags, Tag{Name: releases[i].TagName, Date: releases[i].PublishedAt})
+		allowed = append(allowed, releases[i])
+	}
+
+	constraints, err := s.NewConstraint("")
+	if err != nil {
+		return nil, err
+	}
+
+	i, ok := s.latest(tags, constraints)
+	if !ok {
+		return nil, errors.Errorf("no releases found in the %s channel", s.Channel)
+	}
+
+	return allowed[i], nil
+}
+
+// SortReleases returns the releases in the channel which satisfy the constraint,
+// with the highest version first. Releases whose tags do not match the tag
+// format, or are not versions in the version scheme, are skipped.
+func (s Settings) SortReleases(releases []*Release, constraint string) ([]*Release, error) {
+	constraints, err := s.NewConstraint(constraint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new version constraint")
+	}
+
+	type keyed struct {
+		release *Release
+		key     sortKey
+	}
+
+	matches := make([]keyed, 0, len(releases))
+
+	for i := range releases {
+		if !s.Channel.Allows(releases[i], s.TagFormat) {
+			continue
+		}
+
+		key, ok := s.key(Tag{Name: releases[i].TagName, Date: releases[i].PublishedAt})
+		if !ok || !constraints.check(key) {
+			continue
+		}
+
+		matches = append(matches, keyed{release: releases[i], key: key})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return s.Scheme.compare(matches[i].key, matches[j].key) > 0
+	})
+
+	sorted := make([]*Release, 0, len(matches))
+	for i := range matches {
+		sorted = append(sorted, matches[i].release)
+	}
+
+	return sorted, nil
+}
+
+// latest returns the index of the tag with the highest version which satisfies
+// the constraint. The first of several equal versions wins.
+func (s Settings) latest(tags []Tag, constraint *Constraint) (int, bool) {
+	var (
+		latest    = -1
+		latestKey sortKey
+	)
+
+	for i := range tags {
+		key, ok := s.key(tags[i])
+		if !ok || !constraint.check(key) {
+			continue
+		}
+
+		if latest == -1 || s.Scheme.compare(key, latestKey) > 0 {
+			latest, latestKey = i, key
+		}
+	}
+
+	return latest, latest != -1
+}
+
+// IsPrerelease reports whether a tag is a pre-release version (e.g., `v1.2.0-rc1`).
+// Tags which are not versions are not pre-releases, so tags with a prefix have to
+// be checked with TagFormat.IsPrerelease.
+func IsPrerelease(tag string) bool {
+	ver, err := version.NewVersion(tag)
+
+	return err == nil && ver.Prerelease() != ""
+}
+
+// MatchAsset returns the asset whose name matches the pattern. When several
+// do, the first one built for the current libc is preferred, followed by the
+// first one which isn't built for a different libc.
+func (s Settings) MatchAsset(assets []*Asset, pattern string) (*Asset, error) {
+	if len(assets) == 0 {
+		return nil, errors.New("no release assets found")
+	}
+
+	rePattern, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid asset pattern: %s", pattern)
+	}
+
+	matches := []*Asset{}
+
+	for i := range assets {
+		if rePattern.MatchString(assets[i].Name) {
+			matches = append(matches, assets[i])
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, errors.Errorf("no release asset matches the pattern: %s", pattern)
+	}
+
+	if len(matches) == 1 || s.Libc == "" {
+		return matches[0], nil
+	}
+
+	for i := range matches {
+		if libcOf(matches[i].Name) == s.Libc {
+			return matches[i], nil
+		}
+	}
+
+	for i := range matches {
+		if libcOf(matches[i].Name) == "" {
+			return matches[i], nil
+		}
+	}
+
+	return matches[0], nil
+}
+
+// libcOf returns the libc which an asset name says it was built for, or an
+// empty string if it doesn't say.
+func libcOf(name string) string {
+	switch {
+	case reMusl.MatchString(name):
+		return platform.LibcMusl
+	case reGNU.MatchString(name):
+		return platform.LibcGNU
+	default:
+		return ""
+	}
+}